@@ -1,51 +1,206 @@
 package main
 
 import (
-	"log"
+	"context"
+	"flag"
 	"math/rand"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
 	"time"
 
-	"github.com/gorilla/handlers"
 	"humanitec.io/resources/driver-aws-external/internal/api"
 	"humanitec.io/resources/driver-aws-external/internal/aws"
+	"humanitec.io/resources/driver-aws-external/internal/certs"
+	"humanitec.io/resources/driver-aws-external/internal/config"
+	"humanitec.io/resources/driver-aws-external/internal/logging"
 	"humanitec.io/resources/driver-aws-external/internal/model"
+	"humanitec.io/resources/driver-aws-external/internal/tracing"
 )
 
 func main() {
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "Path of a YAML configuration file. Environment variables override its settings.")
+	printConfig := flag.Bool("print-config", false, "Print the configuration, with secrets masked, and exit.")
+	flag.Parse()
+
 	rand.Seed(time.Now().UnixNano())
 
+	log := logging.Base()
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		log.Fatalf("Unable to load configuration: %v", err)
+	}
+	if *printConfig {
+		out, err := cfg.YAML()
+		if err != nil {
+			log.Fatalf("Unable to print configuration: %v", err)
+		}
+		os.Stdout.Write(out)
+		return
+	}
+
+	if err := logging.Setup(cfg.LogLevel); err != nil {
+		log.Fatalf(`Unable to set log level to "%s": %v`, cfg.LogLevel, err)
+	}
+
 	var s api.Server
 
-	log.Println("Setting up Model")
-	s.Model = model.Setup()
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatalf("Unable to set up tracing: %v", err)
+	}
+	if cfg.Tracing.Enabled() {
+		log.Info("Exporting traces over OTLP")
+	}
+
+	log.Info("Setting up Model")
+	s.Model = model.Setup(cfg.Database)
 
-	log.Println("Setting up Routes")
+	var signer *api.HMACSigner
+	if cfg.Auth.HMACKey != "" {
+		signer = api.NewHMACSigner([]byte(cfg.Auth.HMACKey), cfg.Auth.HMACMaxSkew, s.Model)
+	}
+	s.Auth, err = api.RouteAuth(api.BearerToken(cfg.Auth.BearerToken), signer, cfg.Auth.Routes)
+	if err != nil {
+		log.Fatalf(`Unable to configure authentication: %v`, err)
+	}
+	if len(s.Auth) == 0 {
+		log.Warn("No authentication configured. Anyone who can reach the driver can create and delete resources.")
+	}
+
+	log.Info("Setting up Routes")
 	s.SetupRoutes()
 
 	s.HttpClient = &http.Client{}
 
 	s.NewAwsClient = aws.New
-	if os.Getenv("USE_FAKE_AWS_CLIENT") != "" {
-		s.NewAwsClient = aws.FakeNew
-	}
-	var err error
-	s.TimeoutLimit = 300
-	if os.Getenv("TIMEOUT_LIMIT") != "" {
-		s.TimeoutLimit, err = strconv.Atoi(os.Getenv("TIMEOUT_LIMIT"))
-		if err != nil || s.TimeoutLimit <= 0 {
-			log.Fatalf(`Unable to set timeout limit to "%s"`, os.Getenv("TIMEOUT_LIMIT"))
+	if cfg.AWS.Endpoint != "" {
+		log.Infof("Sending AWS requests to %s", cfg.AWS.Endpoint)
+		s.NewAwsClient = aws.NewWithEndpoint(cfg.AWS.Endpoint)
+	}
+	if cfg.AWS.Fake {
+		log.Info("Using the fake AWS client")
+		s.NewAwsClient = aws.NewFakeAccount(aws.FakeConfig{
+			ClusterCreateDelay: cfg.AWS.FakeAccount.ClusterCreateDelay,
+			ClusterDeleteDelay: cfg.AWS.FakeAccount.ClusterDeleteDelay,
+			MaxBuckets:         cfg.AWS.FakeAccount.MaxBuckets,
+			MaxClusters:        cfg.AWS.FakeAccount.MaxClusters,
+			Errors:             cfg.AWS.FakeAccount.Errors,
+		}).New
+	}
+	s.Wait = aws.WaitConfig{
+		CreateTimeout:  cfg.AWS.Wait.CreateTimeout,
+		DeleteTimeout:  cfg.AWS.Wait.DeleteTimeout,
+		AttemptTimeout: cfg.AWS.Wait.AttemptTimeout,
+		MinDelay:       cfg.AWS.Wait.MinDelay,
+		MaxDelay:       cfg.AWS.Wait.MaxDelay,
+	}
+	log.Infof("Waiting up to %v for clusters to be created and %v for them to be deleted", s.Wait.CreateTimeout, s.Wait.DeleteTimeout)
+
+	s.ServingPort = strconv.Itoa(cfg.Port)
+	s.DefaultRegion = cfg.DefaultRegion
+	s.RegionDefaults = cfg.Regions
+	s.Naming = cfg.Naming
+	s.Deletion = cfg.Deletion
+
+	s.HealthCheckTimeout = cfg.HealthCheck.Timeout
+	if cfg.HealthCheck.STSRegion != "" {
+		s.PingAWS, err = aws.NewSTSPing(cfg.AWS.Endpoint, cfg.HealthCheck.STSRegion)
+		if err != nil {
+			log.Fatalf("Unable to set up AWS health check: %v", err)
 		}
 	}
-	log.Printf("Timeout set to %d", s.TimeoutLimit)
 
-	s.ServingPort = os.Getenv("PORT")
-	if s.ServingPort == "" {
-		s.ServingPort = "8080"
+	// Requests are cancelled if they are still running once the drain timeout has passed.
+	requestCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	srv := &http.Server{
+		Addr:        ":" + s.ServingPort,
+		Handler:     s.Router,
+		BaseContext: func(net.Listener) context.Context { return requestCtx },
 	}
 
-	log.Printf("Listening on Port %s", s.ServingPort)
-	log.Fatal(http.ListenAndServe(":"+s.ServingPort, handlers.LoggingHandler(os.Stdout, s.Router)))
+	if cfg.TLS.CertFile != "" {
+		srv.TLSConfig, err = certs.ServerConfig(cfg.TLS.CertFile, cfg.TLS.KeyFile, cfg.TLS.ClientCAFile, cfg.TLS.ClientAuth)
+		if err != nil {
+			log.Fatalf("Unable to set up TLS: %v", err)
+		}
+	}
+
+	// Stops starting deletions once draining starts. It is cancelled, along with any deletion in progress, once requests
+	// have been drained, and waited for before the model is closed.
+	workerCtx, stopWorker := context.WithCancel(context.Background())
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		s.RunDeletionWorker(workerCtx)
+	}()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			log.Infof("Listening on Port %s with TLS", s.ServingPort)
+			// The certificate is supplied by srv.TLSConfig.
+			serveErr <- srv.ListenAndServeTLS("", "")
+			return
+		}
+		log.Infof("Listening on Port %s", s.ServingPort)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	select {
+	case err = <-serveErr:
+		log.WithError(err).Error("Server stopped")
+	case sig := <-stop:
+		log.Infof("Received %v. Draining in-flight requests for up to %v.", sig, cfg.DrainTimeout)
+		drain(srv, &s, cfg.ShutdownDelay, cfg.DrainTimeout, cancelRequests)
+	}
+
+	stopWorker()
+	<-workerDone
+	if err := s.Model.Close(); err != nil {
+		log.WithError(err).Error("Unable to close the database")
+	}
+
+	if err := shutdownTracing(context.Background()); err != nil {
+		log.WithError(err).Error("Unable to flush traces")
+	}
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// handOffTimeout is how long requests cancelled at the end of the drain timeout have to record unfinished
+// provisioning, so that it can be resumed by another replica.
+const handOffTimeout = 5 * time.Second
+
+// drain stops the server accepting new requests and waits for in-flight requests to complete. /health fails for delay
+// before the server stops accepting requests. Requests still running after timeout are cancelled.
+func drain(srv *http.Server, s *api.Server, delay, timeout time.Duration, cancelRequests context.CancelFunc) {
+	log := logging.Base()
+	s.StartDraining()
+	if delay > 0 {
+		log.Infof("Accepting requests for another %v until load balancers stop sending them.", delay)
+		time.Sleep(delay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err == nil {
+		log.Info("All requests completed.")
+		return
+	}
+
+	log.Warn("Drain timeout passed. Cancelling in-flight requests.")
+	cancelRequests()
+	ctx, cancel = context.WithTimeout(context.Background(), handOffTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.WithError(err).Error("Requests did not stop after being cancelled.")
+	}
 }
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"humanitec.io/resources/driver-aws-external/internal/aws/localaws"
+)
+
+func main() {
+	server := localaws.NewServer()
+
+	for name, d := range map[string]*time.Duration{
+		"CLUSTER_CREATE_DELAY": &server.ClusterCreateDelay,
+		"CLUSTER_DELETE_DELAY": &server.ClusterDeleteDelay,
+	} {
+		if value := os.Getenv(name); value != "" {
+			var err error
+			if *d, err = time.ParseDuration(value); err != nil {
+				log.Fatalf(`Unable to set %s to "%s": %v`, name, value, err)
+			}
+		}
+	}
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "4566"
+	}
+
+	log.Printf("Listening on Port %s", port)
+	log.Fatal(http.ListenAndServe(":"+port, server))
+}
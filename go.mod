@@ -3,11 +3,18 @@ module humanitec.io/resources/driver-aws-external
 go 1.14
 
 require (
-	github.com/aws/aws-sdk-go v1.33.6
+	github.com/aws/aws-sdk-go v1.42.22
 	github.com/golang/mock v1.4.3
-	github.com/google/uuid v1.1.1
-	github.com/gorilla/handlers v1.4.2
+	github.com/google/uuid v1.1.2
 	github.com/gorilla/mux v1.7.4
 	github.com/lib/pq v1.7.0
 	github.com/matryer/is v1.4.0
+	github.com/mattn/go-sqlite3 v1.14.0
+	github.com/prometheus/client_golang v1.7.1
+	github.com/sirupsen/logrus v1.6.0
+	go.opentelemetry.io/otel v1.0.0
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.0.0
+	go.opentelemetry.io/otel/sdk v1.0.0
+	go.opentelemetry.io/otel/trace v1.0.0
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
 )
@@ -0,0 +1,321 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"humanitec.io/resources/driver-aws-external/internal/logging"
+)
+
+// Names of the routes that can be authenticated. /alive and /health are always open so that probes keep working.
+const (
+	RouteCreateOrUpdate = "createOrUpdate"
+	RouteDelete         = "delete"
+	RouteImport         = "import"
+	RouteCancelDeletion = "cancelDeletion"
+	RouteMetrics        = "metrics"
+)
+
+// Routes lists the names of the routes that can be authenticated.
+var Routes = []string{RouteCreateOrUpdate, RouteDelete, RouteImport, RouteCancelDeletion, RouteMetrics}
+
+// Headers carrying HMAC request signatures.
+const (
+	SignatureTimestampHeader = "X-Driver-Timestamp"
+	SignatureHeader          = "X-Driver-Signature"
+)
+
+// signedHeaders lists the request headers covered by HMAC signatures, in the order they are signed in. They carry the
+// parameters and credentials of the resource.
+var signedHeaders = []string{"Humanitec-Driver-Params", "Humanitec-Driver-Secrets"}
+
+// maxBodySize limits the size of the bodies of authenticated requests, which are read in full before the caller is
+// known. Driver requests are small JSON documents.
+const maxBodySize = 1 << 20
+
+// ErrUnauthenticated is returned by an Authenticator if the request does not carry any credentials it understands.
+var ErrUnauthenticated = errors.New("no credentials")
+
+// An Authenticator checks that a request was made by an authorised caller.
+type Authenticator interface {
+	// Authenticate returns an error if the request is not authorised. It may read the body but must leave it in place
+	// for the handler.
+	Authenticate(r *http.Request) error
+}
+
+// BearerToken authenticates requests carrying a shared token in an "Authorization: Bearer <token>" header.
+type BearerToken string
+
+func (t BearerToken) Authenticate(r *http.Request) error {
+	const prefix = "Bearer "
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, prefix) {
+		return ErrUnauthenticated
+	}
+	if subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(header, prefix)), []byte(t)) != 1 {
+		return errors.New("invalid bearer token")
+	}
+	return nil
+}
+
+// HMACSigner authenticates requests signed with a shared key. The signature is the hex encoded HMAC-SHA256 of
+//
+//	<timestamp>\n<method>\n<request URI>\n<hex encoded SHA-256 of the Humanitec-Driver-Params header>\n
+//	<hex encoded SHA-256 of the Humanitec-Driver-Secrets header>\n<hex encoded SHA-256 of the body>
+//
+// where timestamp is the Unix time in seconds at which the request was signed, sent in the X-Driver-Timestamp header.
+// Missing headers are hashed as empty. The signature is sent in the X-Driver-Signature header.
+//
+// Requests signed more than MaxSkew ago (or in the future) are rejected, as are signatures that have already been
+// used, so that captured requests cannot be replayed.
+type HMACSigner struct {
+	Key     []byte
+	MaxSkew time.Duration
+	// Signatures records the signatures that have been used. If it is nil, they are only recorded in memory, which
+	// only stops requests being replayed against the same replica.
+	Signatures SignatureStore
+
+	now  func() time.Time
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// A SignatureStore records the request signatures that have been used. It is implemented by model.Modeler, so that
+// signatures are shared between all replicas using the same database.
+type SignatureStore interface {
+	// UseSignature records that a signature has been accepted, until expiresAt. It returns false if the signature has
+	// already been recorded and has not expired yet.
+	UseSignature(ctx context.Context, signature string, expiresAt time.Time) (bool, error)
+}
+
+// DefaultMaxSkew is used by HMACSigners without a MaxSkew.
+const DefaultMaxSkew = 5 * time.Minute
+
+// NewHMACSigner creates an HMACSigner that accepts requests signed with key up to maxSkew ago, recording the signatures
+// used in signatures.
+func NewHMACSigner(key []byte, maxSkew time.Duration, signatures SignatureStore) *HMACSigner {
+	return &HMACSigner{Key: key, MaxSkew: maxSkew, Signatures: signatures}
+}
+
+// Sign adds the signature headers to a request. The body, if any, is read and replaced. The headers covered by the
+// signature must already be set.
+func (h *HMACSigner) Sign(r *http.Request) error {
+	body, err := readBody(r)
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(h.clock().Unix(), 10)
+	r.Header.Set(SignatureTimestampHeader, timestamp)
+	r.Header.Set(SignatureHeader, hex.EncodeToString(h.signature(timestamp, r, body)))
+	return nil
+}
+
+func (h *HMACSigner) Authenticate(r *http.Request) error {
+	timestamp := r.Header.Get(SignatureTimestampHeader)
+	signature := r.Header.Get(SignatureHeader)
+	if timestamp == "" && signature == "" {
+		return ErrUnauthenticated
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("malformed signature timestamp: %w", err)
+	}
+	signedAt := time.Unix(seconds, 0)
+	now := h.clock()
+	if signedAt.Before(now.Add(-h.maxSkew())) || signedAt.After(now.Add(h.maxSkew())) {
+		return fmt.Errorf("signature timestamp %v is outside the allowed window", signedAt.UTC())
+	}
+	given, err := hex.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("malformed signature: %w", err)
+	}
+	body, err := readBody(r)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(given, h.signature(timestamp, r, body)) {
+		return errors.New("invalid signature")
+	}
+
+	// Replays of expired signatures are rejected by the timestamp check, so they only need to be recorded until then.
+	used, err := h.useSignature(r.Context(), hex.EncodeToString(given), now, signedAt.Add(h.maxSkew()))
+	if err != nil {
+		return fmt.Errorf("recording signature: %w", err)
+	}
+	if !used {
+		return errors.New("signature has already been used")
+	}
+	return nil
+}
+
+// useSignature records that signature has been accepted until expiresAt, in the SignatureStore if there is one. It
+// returns false if it had already been used.
+func (h *HMACSigner) useSignature(ctx context.Context, signature string, now, expiresAt time.Time) (bool, error) {
+	if h.Signatures != nil {
+		return h.Signatures.UseSignature(ctx, signature, expiresAt)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s, expires := range h.seen {
+		if now.After(expires) {
+			delete(h.seen, s)
+		}
+	}
+	if _, replayed := h.seen[signature]; replayed {
+		return false, nil
+	}
+	if h.seen == nil {
+		h.seen = map[string]time.Time{}
+	}
+	h.seen[signature] = expiresAt
+	return true, nil
+}
+
+func (h *HMACSigner) signature(timestamp string, r *http.Request, body []byte) []byte {
+	mac := hmac.New(sha256.New, h.Key)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n", timestamp, r.Method, r.URL.RequestURI())
+	for _, header := range signedHeaders {
+		fmt.Fprintf(mac, "%s\n", hashHex([]byte(r.Header.Get(header))))
+	}
+	fmt.Fprint(mac, hashHex(body))
+	return mac.Sum(nil)
+}
+
+// hashHex returns the hex encoded SHA-256 of b.
+func hashHex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func (h *HMACSigner) clock() time.Time {
+	if h.now != nil {
+		return h.now()
+	}
+	return time.Now()
+}
+
+func (h *HMACSigner) maxSkew() time.Duration {
+	if h.MaxSkew <= 0 {
+		return DefaultMaxSkew
+	}
+	return h.MaxSkew
+}
+
+// readBody reads the request body and replaces it so that it can be read again. Bodies larger than maxBodySize are not
+// read in full.
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, maxBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("reading body: %w", err)
+	}
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// AnyOf authenticates requests accepted by any of the supplied Authenticators.
+type AnyOf []Authenticator
+
+func (a AnyOf) Authenticate(r *http.Request) error {
+	err := ErrUnauthenticated
+	for _, authenticator := range a {
+		switch e := authenticator.Authenticate(r); {
+		case e == nil:
+			return nil
+		case !errors.Is(e, ErrUnauthenticated):
+			// Report credentials that were presented but rejected rather than the ones that were missing.
+			err = e
+		}
+	}
+	return err
+}
+
+// authenticate is middleware checking requests against the Authenticator configured for their route in Server.Auth.
+// Routes without one are open.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var authenticator Authenticator
+		if current := mux.CurrentRoute(r); current != nil {
+			authenticator = s.Auth[current.GetName()]
+		}
+		if authenticator != nil {
+			if r.ContentLength > maxBodySize {
+				writeAsJSON(w, http.StatusRequestEntityTooLarge, "Request body too large")
+				return
+			}
+			// Closes the connection if a body without a declared length turns out to be too large.
+			r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+			if err := authenticator.Authenticate(r); err != nil {
+				logging.FromContext(r.Context()).WithError(err).Warn("Authentication failed")
+				w.Header().Set("WWW-Authenticate", `Bearer realm="driver-aws-external"`)
+				writeAsJSON(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RouteAuth builds the Auth map for a Server from the configured credentials. Routes use any of the configured
+// methods unless overridden in routes, a comma separated list of <route>=<method> pairs where method is "bearer",
+// "hmac", "any" or "none". e.g. "metrics=none,delete=hmac". Without any credentials, all routes are open.
+func RouteAuth(token BearerToken, signer *HMACSigner, routes string) (map[string]Authenticator, error) {
+	methods := map[string]Authenticator{"none": nil}
+	var all AnyOf
+	if token != "" {
+		methods["bearer"] = token
+		all = append(all, token)
+	}
+	if signer != nil {
+		methods["hmac"] = signer
+		all = append(all, signer)
+	}
+	if len(all) == 0 {
+		if strings.TrimSpace(routes) != "" {
+			return nil, errors.New("routes cannot be configured without credentials")
+		}
+		return map[string]Authenticator{}, nil
+	}
+	methods["any"] = all
+
+	auth := map[string]Authenticator{}
+	for _, route := range Routes {
+		auth[route] = all
+	}
+	for _, pair := range strings.Split(routes, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		route := strings.TrimSpace(parts[0])
+		if _, ok := auth[route]; !ok {
+			return nil, fmt.Errorf(`unknown route "%s"`, route)
+		}
+		if len(parts) != 2 {
+			return nil, fmt.Errorf(`no method for route "%s"`, route)
+		}
+		method, ok := methods[strings.TrimSpace(parts[1])]
+		if !ok {
+			return nil, fmt.Errorf(`unknown or unconfigured method "%s" for route "%s"`, strings.TrimSpace(parts[1]), route)
+		}
+		auth[route] = method
+	}
+	return auth, nil
+}
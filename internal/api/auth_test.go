@@ -0,0 +1,258 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/matryer/is"
+	"humanitec.io/resources/driver-aws-external/internal/model/mock_model"
+)
+
+func TestBearerToken(t *testing.T) {
+	is := is.New(t)
+	token := BearerToken("s3cr3t")
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	is.Equal(token.Authenticate(r), ErrUnauthenticated)
+
+	r.Header.Set("Authorization", "Bearer wrong")
+	is.True(token.Authenticate(r) != nil)
+
+	r.Header.Set("Authorization", "Bearer s3cr3t")
+	is.NoErr(token.Authenticate(r))
+}
+
+func TestHMACSigner(t *testing.T) {
+	is := is.New(t)
+	now := time.Unix(1600000000, 0)
+	signer := NewHMACSigner([]byte("key"), time.Minute, nil)
+	signer.now = func() time.Time { return now }
+
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/?x=1", bytes.NewBufferString(`{"id":"abc"}`))
+		r.Header.Set("Humanitec-Driver-Params", "cGFyYW1z")
+		r.Header.Set("Humanitec-Driver-Secrets", "c2VjcmV0cw==")
+		return r
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		is := is.New(t)
+		r := newRequest()
+		is.NoErr(signer.Sign(r))
+		is.NoErr(signer.Authenticate(r))
+
+		// The body is still available to the handler.
+		body, err := readBody(r)
+		is.NoErr(err)
+		is.Equal(string(body), `{"id":"abc"}`)
+	})
+
+	t.Run("Format", func(t *testing.T) {
+		is := is.New(t)
+		r := newRequest()
+		is.NoErr(signer.Sign(r))
+
+		hash := func(s string) string {
+			sum := sha256.Sum256([]byte(s))
+			return hex.EncodeToString(sum[:])
+		}
+		mac := hmac.New(sha256.New, []byte("key"))
+		mac.Write([]byte("1600000000\nPOST\n/?x=1\n" + hash("cGFyYW1z") + "\n" + hash("c2VjcmV0cw==") + "\n" + hash(`{"id":"abc"}`)))
+		is.Equal(r.Header.Get(SignatureTimestampHeader), "1600000000")
+		is.Equal(r.Header.Get(SignatureHeader), hex.EncodeToString(mac.Sum(nil)))
+	})
+
+	t.Run("Unsigned", func(t *testing.T) {
+		is := is.New(t)
+		is.Equal(signer.Authenticate(newRequest()), ErrUnauthenticated)
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		is := is.New(t)
+		r := newRequest()
+		is.NoErr(signer.Sign(r))
+		tampered := httptest.NewRequest(http.MethodPost, "/?x=1", bytes.NewBufferString(`{"id":"xyz"}`))
+		tampered.Header = r.Header
+		is.True(signer.Authenticate(tampered) != nil)
+	})
+
+	t.Run("TamperedHeaders", func(t *testing.T) {
+		for _, header := range signedHeaders {
+			t.Run(header, func(t *testing.T) {
+				is := is.New(t)
+				r := newRequest()
+				is.NoErr(signer.Sign(r))
+				r.Header.Set(header, "b3RoZXI=")
+				is.True(signer.Authenticate(r) != nil) // the parameters and credentials cannot be swapped
+			})
+		}
+	})
+
+	t.Run("BodyTooLarge", func(t *testing.T) {
+		is := is.New(t)
+		newLargeRequest := func() *http.Request {
+			return httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("x", maxBodySize+1)))
+		}
+		is.True(signer.Sign(newLargeRequest()) != nil)
+
+		r := newLargeRequest()
+		r.Header.Set(SignatureTimestampHeader, strconv.FormatInt(now.Unix(), 10))
+		r.Header.Set(SignatureHeader, "00")
+		is.True(strings.Contains(signer.Authenticate(r).Error(), "too large")) // rejected before the signature is checked
+	})
+
+	t.Run("WrongKey", func(t *testing.T) {
+		is := is.New(t)
+		r := newRequest()
+		other := NewHMACSigner([]byte("other"), time.Minute, nil)
+		other.now = signer.now
+		is.NoErr(other.Sign(r))
+		is.True(signer.Authenticate(r) != nil)
+	})
+
+	t.Run("Replayed", func(t *testing.T) {
+		is := is.New(t)
+		r := newRequest()
+		now = now.Add(time.Second)
+		is.NoErr(signer.Sign(r))
+		is.NoErr(signer.Authenticate(r))
+
+		replay := newRequest()
+		replay.Header = r.Header
+		is.True(signer.Authenticate(replay) != nil)
+
+		replay = newRequest()
+		replay.Header = r.Header.Clone()
+		replay.Header.Set(SignatureHeader, strings.ToUpper(r.Header.Get(SignatureHeader)))
+		is.True(signer.Authenticate(replay) != nil) // nor by changing the case of the signature
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		is := is.New(t)
+		r := newRequest()
+		is.NoErr(signer.Sign(r))
+		now = now.Add(2 * time.Minute)
+		is.True(signer.Authenticate(r) != nil)
+	})
+
+	t.Run("MalformedTimestamp", func(t *testing.T) {
+		is := is.New(t)
+		r := newRequest()
+		is.NoErr(signer.Sign(r))
+		r.Header.Set(SignatureTimestampHeader, "yesterday")
+		is.True(signer.Authenticate(r) != nil)
+	})
+
+	// Replays of expired signatures are rejected by the timestamp check, so they are forgotten.
+	r := newRequest()
+	is.NoErr(signer.Sign(r))
+	is.NoErr(signer.Authenticate(r))
+	is.Equal(len(signer.seen), 1)
+}
+
+func TestHMACSigner_SignatureStore(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Unix(1600000000, 0)
+	mockModel := mock_model.NewMockModeler(ctrl)
+	signer := NewHMACSigner([]byte("key"), time.Minute, mockModel)
+	signer.now = func() time.Time { return now }
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{}`))
+	is.NoErr(signer.Sign(r))
+
+	// Signatures are recorded in the store, so that they cannot be replayed against other replicas.
+	mockModel.EXPECT().UseSignature(gomock.Any(), r.Header.Get(SignatureHeader), now.Add(time.Minute)).Return(true, nil)
+	is.NoErr(signer.Authenticate(r))
+	mockModel.EXPECT().UseSignature(gomock.Any(), r.Header.Get(SignatureHeader), now.Add(time.Minute)).Return(false, nil)
+	is.True(signer.Authenticate(r) != nil)
+	is.Equal(len(signer.seen), 0)
+}
+
+func TestAnyOf(t *testing.T) {
+	is := is.New(t)
+	auth := AnyOf{BearerToken("s3cr3t"), NewHMACSigner([]byte("key"), 0, nil)}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	is.Equal(auth.Authenticate(r), ErrUnauthenticated)
+
+	r.Header.Set("Authorization", "Bearer wrong")
+	err := auth.Authenticate(r)
+	is.True(err != nil && err != ErrUnauthenticated) // the rejected token is reported
+
+	r.Header.Set("Authorization", "Bearer s3cr3t")
+	is.NoErr(auth.Authenticate(r))
+}
+
+func TestRouteAuth(t *testing.T) {
+	is := is.New(t)
+	token := BearerToken("s3cr3t")
+	signer := NewHMACSigner([]byte("key"), 0, nil)
+
+	auth, err := RouteAuth("", nil, "")
+	is.NoErr(err)
+	is.Equal(len(auth), 0)
+
+	auth, err = RouteAuth(token, signer, "metrics=none, delete=hmac")
+	is.NoErr(err)
+	is.Equal(auth[RouteCreateOrUpdate], AnyOf{token, signer})
+	is.Equal(auth[RouteDelete], signer)
+	is.Equal(auth[RouteMetrics], nil)
+
+	_, err = RouteAuth(token, nil, "delete=hmac")
+	is.True(err != nil) // hmac is not configured
+
+	_, err = RouteAuth(token, nil, "health=bearer")
+	is.True(err != nil) // health is always open
+
+	_, err = RouteAuth("", nil, "metrics=none")
+	is.True(err != nil)
+}
+
+func TestAuthenticate(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockModel := mock_model.NewMockModeler(ctrl)
+	mockModel.EXPECT().Ping(gomock.Any()).Return(nil)
+	mockModel.EXPECT().CheckSchema(gomock.Any()).Return(nil)
+
+	auth, err := RouteAuth("s3cr3t", nil, "")
+	is.NoErr(err)
+	s := Server{Model: mockModel, Auth: auth}
+
+	// The handler is not reached without credentials.
+	rr := ExecuteRequest(s, http.MethodPost, "/", []byte(`{}`), t)
+	is.Equal(rr.Code, http.StatusUnauthorized)
+	is.True(rr.Header().Get("WWW-Authenticate") != "")
+
+	rr = ExecuteRequest(s, http.MethodDelete, "/abc", nil, t)
+	is.Equal(rr.Code, http.StatusUnauthorized)
+
+	rr = ExecuteRequest(s, http.MethodGet, "/metrics", nil, t)
+	is.Equal(rr.Code, http.StatusUnauthorized)
+
+	rr = ExecuteRequestHeader(s, http.MethodGet, "/metrics", nil, http.Header{"Authorization": {"Bearer s3cr3t"}}, t)
+	is.Equal(rr.Code, http.StatusOK)
+
+	// Bodies are limited before they are read to authenticate the request.
+	rr = ExecuteRequestHeader(s, http.MethodPost, "/", bytes.Repeat([]byte("x"), maxBodySize+1), http.Header{"Authorization": {"Bearer s3cr3t"}}, t)
+	is.Equal(rr.Code, http.StatusRequestEntityTooLarge)
+
+	// Probes are always open.
+	rr = ExecuteRequest(s, http.MethodGet, "/alive", nil, t)
+	is.Equal(rr.Code, http.StatusOK)
+	rr = ExecuteRequest(s, http.MethodGet, "/health", nil, t)
+	is.Equal(rr.Code, http.StatusOK)
+}
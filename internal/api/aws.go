@@ -1,16 +1,27 @@
 package api
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
-	"log"
-
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"humanitec.io/resources/driver-aws-external/internal/aws"
+	"humanitec.io/resources/driver-aws-external/internal/logging"
 	"humanitec.io/resources/driver-aws-external/internal/messages"
+	"humanitec.io/resources/driver-aws-external/internal/metrics"
+	"humanitec.io/resources/driver-aws-external/internal/model"
+	"humanitec.io/resources/driver-aws-external/internal/tracing"
 )
 
 func DecodeSecretsHeader(secretsHeaderValue string) (map[string]interface{}, error) {
@@ -32,8 +43,22 @@ func (s *Server) createOrUpdateAWSResource(w http.ResponseWriter, r *http.Reques
 	if !readAsJSON(w, r, &drd) {
 		return
 	}
+	drd.DriverParams = s.withDefaults(drd.Type, drd.DriverParams)
+
+	ctx, span := tracing.Tracer().Start(r.Context(), "createOrUpdateAWSResource", trace.WithAttributes(
+		attribute.String("resource.id", drd.ID),
+		attribute.String("resource.type", drd.Type),
+	))
+	defer span.End()
+	ctx = logging.NewContext(ctx, logging.FromContext(ctx).WithField("resource_id", drd.ID))
+	l := logging.FromContext(ctx)
+
+	if !s.lockResource(ctx, w, drd.ID) {
+		return
+	}
+	defer s.unlockResource(ctx, drd.ID)
 
-	metadata, metadataExists, err := s.Model.SelectResourceMetadata(drd.ID)
+	metadata, metadataExists, err := s.Model.SelectResourceMetadata(ctx, drd.ID)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -46,49 +71,122 @@ func (s *Server) createOrUpdateAWSResource(w http.ResponseWriter, r *http.Reques
 
 	awsCreds, err := AccountMapToAWSCredentials(drd.DriverSecrets["account"])
 	if err != nil {
-		log.Printf("Reading account: %v", err)
+		l.WithError(err).Error("Reading account")
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	if metadataExists {
+	if metadataExists && metadata.IsPendingDeletion() {
+		l.Info("Resource is pending deletion.")
+		writeAsJSON(w, http.StatusConflict, fmt.Sprintf("Resource is pending deletion. Cancel the deletion with POST /%s/cancel-deletion before updating it.", drd.ID))
+		return
+	}
+	if metadataExists && metadata.IsDeleting() {
+		l.Info("Resource is being deleted.")
+		writeAsJSON(w, http.StatusConflict, "Resource is being deleted. Retry once its deletion has completed to create it again.")
+		return
+	}
+
+	if metadataExists && !metadata.IsDeleted() && !metadata.IsProvisioning() {
 		data.Values = metadata.Data
+		err = s.reconcileDeletionParams(ctx, drd, &metadata)
 		switch drd.Type {
 		case "s3":
 			data.Secrets = map[string]interface{}{
 				"aws_access_key_id":     awsCreds.AccessKeyID,
 				"aws_secret_access_key": awsCreds.SecretAccessKey,
 			}
+			if err == nil {
+				err = s.reconcileS3Bucket(ctx, drd, awsCreds, &metadata)
+			}
+			data.Values = metadata.Data
+		}
+		var perr *invalidParamsError
+		if errors.As(err, &perr) {
+			writeAsJSON(w, http.StatusBadRequest, perr.Error())
+			return
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			l.WithError(err).Errorf("Updating type %s failed", drd.Type)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
 		}
 	} else {
+		var pending map[string]interface{}
+		if metadataExists && metadata.IsDeleted() {
+			l.Infof("Resource was deleted at %v. Provisioning it again.", metadata.DeletedAt.Time)
+			metadata = model.ResourceMetadata{}
+		} else if metadataExists {
+			l.Info("Resuming interrupted provisioning.")
+			pending = metadata.Data
+		}
 		metadata.ID = drd.ID
 		metadata.Type = drd.Type
 		metadata.CreatedAt = time.Now().UTC()
+		metadata.UpdatedAt = metadata.CreatedAt
 		metadata.Params = drd.DriverParams
 
 		if _, exists := drd.DriverSecrets["account"]; !exists {
-			log.Println(`"account" property in driver_secrets is missing`)
+			l.Error(`"account" property in driver_secrets is missing`)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
+		if _, _, err := deletionParams(drd.Type, drd.DriverParams); err != nil {
+			writeAsJSON(w, http.StatusBadRequest, err.Error())
+			return
+		}
 
+		var settings map[string]interface{}
+		provisioningStart := time.Now()
 		switch drd.Type {
 		case "s3":
-			data, err = s.createS3Bucket(drd, awsCreds)
+			data, settings, err = s.createS3Bucket(ctx, drd, awsCreds, pending)
 		case "redis":
-			data, err = s.createRedis(drd, awsCreds)
+			data, err = s.createRedis(ctx, drd, awsCreds, pending)
 		default:
-			log.Printf(`Type "%s" not supported by this driver.`, metadata.Type)
+			l.Errorf(`Type "%s" not supported by this driver.`, metadata.Type)
 			writeAsJSON(w, http.StatusBadRequest, fmt.Sprintf(`Type "%s" not supported by this driver.`, metadata.Type))
 			return
 		}
+		metrics.ProvisioningDuration.WithLabelValues(drd.Type, metrics.Result(err)).Observe(time.Since(provisioningStart).Seconds())
+		var ierr *provisioningInterrupted
+		if errors.As(err, &ierr) {
+			// Record how far provisioning got so that the next request can resume it.
+			metadata.Status = model.StatusProvisioning
+			metadata.Data = ierr.pending
+			if err := s.Model.InsertOrUpdateResourceMetadata(withoutCancel(ctx), metadata); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			l.WithError(err).Warn("Provisioning was interrupted. It will be resumed by the next request for the resource.")
+			writeAsJSON(w, http.StatusServiceUnavailable, "Provisioning was interrupted. Retry the request to resume it.")
+			return
+		}
+		var perr *invalidParamsError
+		if errors.As(err, &perr) {
+			writeAsJSON(w, http.StatusBadRequest, perr.Error())
+			return
+		}
+		if errors.Is(err, aws.ErrNotOwned) {
+			// Never taken over, as it may still be in use by another resource or have been retained on purpose.
+			l.WithError(err).Warn("The bucket or cluster for the resource already exists.")
+			writeAsJSON(w, http.StatusConflict, fmt.Sprintf("Cannot create resource: %v", err))
+			return
+		}
 		if err != nil {
-			log.Printf("Handling type %s failed: %v", drd.Type, err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			l.WithError(err).Errorf("Handling type %s failed", drd.Type)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
+		metadata.Status = model.StatusReady
 		metadata.Data = data.Values
-		err = s.Model.InsertOrUpdateResourceMetadata(metadata)
+		metadata.Settings = settings
+		// The resource now exists in AWS so it must be recorded, even if the caller has gone away.
+		err = s.Model.InsertOrUpdateResourceMetadata(withoutCancel(ctx), metadata)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			return
@@ -110,36 +208,50 @@ func (s *Server) deleteAWSResource(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ctx, span := tracing.Tracer().Start(r.Context(), "deleteAWSResource", trace.WithAttributes(
+		attribute.String("resource.id", params["resourceId"]),
+	))
+	defer span.End()
+	ctx = logging.NewContext(ctx, logging.FromContext(ctx).WithField("resource_id", params["resourceId"]))
+	l := logging.FromContext(ctx)
+
 	if r.Header.Get("Humanitec-Driver-Params") == "" {
-		log.Print(`Missing HTTP header "Humanitec-Driver-Params"`)
+		l.Error(`Missing HTTP header "Humanitec-Driver-Params"`)
 		writeAsJSON(w, http.StatusBadRequest, `Missing HTTP header "Humanitec-Driver-Params"`)
 		return
 	}
 	driverParams, err := DecodeSecretsHeader(r.Header.Get("Humanitec-Driver-Params"))
 	if err != nil {
-		log.Printf(`Unable to decode "Humanitec-Driver-Params" header: %v`, err)
+		l.WithError(err).Error(`Unable to decode "Humanitec-Driver-Params" header`)
 		writeAsJSON(w, http.StatusBadRequest, `Malformed HTTP header "Humanitec-Driver-Params"`)
 		return
 	}
+	driverParams = s.withDefaults("", driverParams)
 
 	if r.Header.Get("Humanitec-Driver-Secrets") == "" {
-		log.Print(`Missing HTTP header "Humanitec-Driver-Secrets"`)
+		l.Error(`Missing HTTP header "Humanitec-Driver-Secrets"`)
 		writeAsJSON(w, http.StatusBadRequest, `Missing HTTP header "Humanitec-Driver-Secrets"`)
 		return
 	}
 	driverSecrets, err := DecodeSecretsHeader(r.Header.Get("Humanitec-Driver-Secrets"))
 	if err != nil {
-		log.Printf(`Unable to decode "Humanitec-Driver-Secrets" header: %v`, err)
+		l.WithError(err).Error(`Unable to decode "Humanitec-Driver-Secrets" header`)
 		writeAsJSON(w, http.StatusBadRequest, `Malformed HTTP header "Humanitec-Driver-Secrets"`)
 		return
 	}
 	if _, exists := driverSecrets["account"]; !exists {
-		log.Print(`Decoded "Humanitec-Driver-Secrets" header is missing "account" key`)
+		l.Error(`Decoded "Humanitec-Driver-Secrets" header is missing "account" key`)
 		writeAsJSON(w, http.StatusBadRequest, `Decoded "Humanitec-Driver-Secrets" header is missing "account" key`)
 		return
 	}
 	awsCreds, err := AccountMapToAWSCredentials(driverSecrets["account"])
-	metadata, metadataExists, err := s.Model.SelectResourceMetadata(params["resourceId"])
+
+	if !s.lockResource(ctx, w, params["resourceId"]) {
+		return
+	}
+	defer s.unlockResource(ctx, params["resourceId"])
+
+	metadata, metadataExists, err := s.Model.SelectResourceMetadata(ctx, params["resourceId"])
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -148,30 +260,134 @@ func (s *Server) deleteAWSResource(w http.ResponseWriter, r *http.Request) {
 		writeAsJSON(w, http.StatusNotFound, fmt.Sprintf("Resource not found: %s", params["resourceId"]))
 		return
 	}
-	switch metadata.Type {
-	case "s3":
-		err = s.deleteS3Bucket(metadata.Data["bucket"].(string), metadata.Params["region"].(string), awsCreds)
-		if err != nil {
-			log.Printf(`Error deleting bucket "%s": %v`, metadata.Data["bucket"], err)
-			writeAsJSON(w, http.StatusBadRequest, fmt.Sprintf(`Error deleting bucket "%s": %v`, metadata.Data["bucket"], err))
+	if metadata.IsDeleted() {
+		// Deleting is idempotent: the resource is already gone so there is nothing left to do.
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	// The deletion policy and protection recorded for the resource apply, not those in the request's headers, so that
+	// protection can only be lifted by updating the resource.
+	policy, protected, err := deletionParams(metadata.Type, metadata.Params)
+	if err != nil {
+		l.WithError(err).Error("Reading deletion policy")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if protected {
+		l.Info("Resource has deletion protection.")
+		writeAsJSON(w, http.StatusConflict, `Resource has deletion protection. Update it with "deletion_protection" set to false in driver_params before deleting it.`)
+		return
+	}
+	if metadata.IsPendingDeletion() {
+		// Deleting is idempotent: the resource is already scheduled for deletion.
+		writeDeletionScheduled(w, metadata)
+		return
+	}
+	// Resources whose provisioning was interrupted were never handed out, so there is nothing to undo.
+	if s.Deletion.GracePeriod > 0 && !metadata.IsProvisioning() && !metadata.IsDeleting() {
+		s.scheduleDeletion(ctx, w, metadata, driverParams, awsCreds)
+		return
+	}
+	err = s.deleteInfrastructure(ctx, &metadata, policy, driverParams, driverSecrets, awsCreds)
+	if err != nil {
+		writeAsJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if metadata.IsDeleting() {
+		clusterId := redisClusterID(metadata)
+		// Unless the cluster is gone, the deletion is confirmed in the background. Retrying the request waits for it
+		// again.
+		switch err := s.waitForRedisDeletion(ctx, clusterId, driverParams, awsCreds); {
+		case err == nil:
+		case awsErrorCode(err) == request.WaiterResourceNotReadyErrorCode:
+			writeAsJSON(w, http.StatusAccepted, fmt.Sprintf(`Cluster "%s" is being deleted. The resource is deleted once AWS has removed it.`, clusterId))
 			return
-		}
-	case "redis":
-		err = s.deleteRedis(metadata.Data["host"].(string), driverParams, driverSecrets, awsCreds)
-		if err != nil {
-			log.Printf(`Error deleting bucket "%s": %v`, metadata.Data["bucket"], err)
-			writeAsJSON(w, http.StatusBadRequest, fmt.Sprintf(`Error deleting bucket "%s": %v`, metadata.Data["bucket"], err))
+		case errors.Is(err, aws.ErrDeletionAbandoned):
+			l.WithError(err).Error("AWS stopped deleting the cluster.")
+			writeAsJSON(w, http.StatusInternalServerError, fmt.Sprintf(`Cluster "%s" is available again and was not deleted. Retry the request to delete it again.`, clusterId))
+			return
+		case ctx.Err() != nil:
+			l.WithError(err).Warn("Waiting for the cluster to be deleted was interrupted.")
+			writeAsJSON(w, http.StatusServiceUnavailable, "Waiting for the cluster to be deleted was interrupted. Retry the request to wait for it again.")
+			return
+		default:
+			l.WithError(err).Error("Waiting for the cluster to be deleted failed.")
+			writeAsJSON(w, http.StatusInternalServerError, fmt.Sprintf(`Unable to check on the deletion of cluster "%s": %v`, clusterId, err))
 			return
 		}
-	default:
-		log.Printf(`Type "%s" not supported by this driver.`, metadata.Type)
-		writeAsJSON(w, http.StatusBadRequest, fmt.Sprintf(`Type "%s" not supported by this driver.`, metadata.Type))
-		return
 	}
 
-	err = s.Model.DeleteResourceMetadata(params["resourceId"], time.Now().UTC())
+	// The resource has been removed from AWS so that must be recorded, even if the caller has gone away.
+	err = s.Model.DeleteResourceMetadata(withoutCancel(ctx), params["resourceId"], time.Now().UTC())
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// deleteInfrastructure deletes, or retains, the bucket or cluster of a resource according to its deletion policy. The
+// error returned describes the failure to the caller. Clusters are deleted in the background by AWS: once it has accepted
+// their deletion, the resource is recorded as being deleted and its status updated. The deletion of clusters of
+// resources being deleted is only requested again if AWS stopped deleting them.
+func (s *Server) deleteInfrastructure(ctx context.Context, metadata *model.ResourceMetadata, policy string, driverParams, driverSecrets map[string]interface{}, awsCreds AWSCredentials) error {
+	l := logging.FromContext(ctx).WithField("deletion_policy", policy)
+
+	var err error
+	switch metadata.Type {
+	case "s3":
+		if policy == deletionPolicyRetain {
+			err = s.retainS3Bucket(ctx, metadata.ID, metadata.Data["bucket"].(string), metadata.Params["region"].(string), awsCreds)
+		} else {
+			err = s.deleteS3Bucket(ctx, metadata.Data["bucket"].(string), metadata.Params["region"].(string), awsCreds)
+		}
+		// Interrupted provisioning may not have got as far as creating the bucket, and an earlier attempt at a scheduled
+		// deletion may have deleted it before being interrupted.
+		if (metadata.IsProvisioning() || metadata.IsPendingDeletion()) && awsErrorCode(err) == s3.ErrCodeNoSuchBucket {
+			err = nil
+		}
+		if err != nil {
+			l.WithError(err).Errorf(`Error deleting bucket "%s"`, metadata.Data["bucket"])
+			return fmt.Errorf(`Error deleting bucket "%s": %w`, metadata.Data["bucket"], err)
+		}
+	case "redis":
+		clusterId := redisClusterID(*metadata)
+		if metadata.IsDeleting() {
+			// AWS has already accepted the deletion of the cluster, unless it is available again.
+			available, err := s.redisAvailable(ctx, clusterId, driverParams, awsCreds)
+			if err != nil {
+				l.WithError(err).Errorf(`Error checking on cluster "%s"`, clusterId)
+				return fmt.Errorf(`Error checking on cluster "%s": %w`, clusterId, err)
+			}
+			if !available {
+				return nil
+			}
+			l.Warnf(`Cluster "%s" is available again. Deleting it again.`, clusterId)
+		}
+		switch {
+		case policy == deletionPolicyRetain:
+			err = s.retainRedis(ctx, metadata.ID, clusterId, driverParams, awsCreds)
+		case policy == deletionPolicySnapshot && !metadata.IsProvisioning():
+			err = s.deleteRedis(ctx, clusterId, finalSnapshotName(clusterId, time.Now()), driverParams, driverSecrets, awsCreds)
+		default:
+			// Clusters whose provisioning was interrupted hold no data worth a snapshot.
+			err = s.deleteRedis(ctx, clusterId, "", driverParams, driverSecrets, awsCreds)
+		}
+		// Interrupted provisioning may not have got as far as creating the cluster, and an earlier attempt at a scheduled
+		// deletion may have deleted it before being interrupted. A cluster deleted again may be gone already.
+		if (metadata.IsProvisioning() || metadata.IsPendingDeletion() || metadata.IsDeleting()) && awsErrorCode(err) == elasticache.ErrCodeCacheClusterNotFoundFault {
+			return nil
+		}
+		if err != nil {
+			l.WithError(err).Errorf(`Error deleting cluster "%s"`, clusterId)
+			return fmt.Errorf(`Error deleting cluster "%s": %w`, clusterId, err)
+		}
+		if policy != deletionPolicyRetain {
+			return s.markDeleting(ctx, metadata, driverParams, awsCreds)
+		}
+	default:
+		l.Errorf(`Type "%s" not supported by this driver.`, metadata.Type)
+		return fmt.Errorf(`Type "%s" not supported by this driver.`, metadata.Type)
+	}
+	return nil
+}
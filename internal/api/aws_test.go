@@ -92,11 +92,22 @@ func TestCreateAWSResource_Existing(t *testing.T) {
 		DeletedAt: sql.NullTime{Valid: false},
 		Params:    params,
 		Data:      data,
+		Settings:  defaultBucketSettings(),
 	}
 
 	m.
 		EXPECT().
-		SelectResourceMetadata(resourceID).
+		LockResource(gomock.Any(), resourceID).
+		Return(true, nil).
+		Times(1)
+	m.
+		EXPECT().
+		UnlockResource(gomock.Any(), resourceID).
+		Return(nil).
+		Times(1)
+	m.
+		EXPECT().
+		SelectResourceMetadata(gomock.Any(), resourceID).
 		Return(metadata, true, nil).
 		Times(1)
 
@@ -121,7 +132,7 @@ func TestCreateAWSResource_New(t *testing.T) {
 	a := mock_aws.NewMockClient(ctrl)
 	s := Server{
 		Model: m,
-		NewAwsClient: func(key, secret, reg string, timeout int) (aws.Client, error) {
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
 			is.Equal(key, accessKeyId)
 			is.Equal(secret, secretAccessKey)
 			is.Equal(reg, region)
@@ -168,30 +179,47 @@ func TestCreateAWSResource_New(t *testing.T) {
 	metadata := model.ResourceMetadata{
 		ID:        resourceID,
 		Type:      resType,
+		Status:    model.StatusReady,
 		CreatedAt: time.Date(2020, 07, 16, 18, 12, 20, 0, time.UTC),
 		UpdatedAt: time.Date(2020, 07, 16, 18, 12, 20, 0, time.UTC),
 		DeletedAt: sql.NullTime{Valid: false},
 		Params:    params,
 		Data:      data,
+		Settings:  defaultBucketSettings(),
 	}
 
 	m.
 		EXPECT().
-		SelectResourceMetadata(resourceID).
+		LockResource(gomock.Any(), resourceID).
+		Return(true, nil).
+		Times(1)
+	m.
+		EXPECT().
+		UnlockResource(gomock.Any(), resourceID).
+		Return(nil).
+		Times(1)
+	m.
+		EXPECT().
+		SelectResourceMetadata(gomock.Any(), resourceID).
 		Return(model.ResourceMetadata{}, false, nil).
 		Times(1)
 	a.
 		EXPECT().
-		CreateBucket(gomock.AssignableToTypeOf("")).
-		Do(func(bn interface{}) {
+		CreateBucket(gomock.Any(), gomock.AssignableToTypeOf(""), drd.ID).
+		Do(func(ctx, bn, resourceID interface{}) {
 			data["bucket"] = bn.(string)
 		}).
 		Return(region, nil).
 		Times(1)
+	a.
+		EXPECT().
+		SecureBucket(gomock.Any(), gomock.AssignableToTypeOf(""), gomock.Any()).
+		Return(nil).
+		Times(1)
 
 	m.
 		EXPECT().
-		InsertOrUpdateResourceMetadata(IgnoreDateResourceMetadata(metadata)).
+		InsertOrUpdateResourceMetadata(gomock.Any(), IgnoreDateResourceMetadata(metadata)).
 		Return(nil).
 		Times(1)
 
@@ -216,7 +244,7 @@ func TestDeleteAWSResource_Exists(t *testing.T) {
 	a := mock_aws.NewMockClient(ctrl)
 	s := Server{
 		Model: m,
-		NewAwsClient: func(key, secret, reg string, timeout int) (aws.Client, error) {
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
 			is.Equal(key, accessKeyId)
 			is.Equal(secret, secretAccessKey)
 			is.Equal(reg, region)
@@ -246,22 +274,33 @@ func TestDeleteAWSResource_Exists(t *testing.T) {
 		DeletedAt: sql.NullTime{Valid: false},
 		Params:    params,
 		Data:      data,
+		Settings:  defaultBucketSettings(),
 	}
 
 	m.
 		EXPECT().
-		SelectResourceMetadata(resourceID).
+		LockResource(gomock.Any(), resourceID).
+		Return(true, nil).
+		Times(1)
+	m.
+		EXPECT().
+		UnlockResource(gomock.Any(), resourceID).
+		Return(nil).
+		Times(1)
+	m.
+		EXPECT().
+		SelectResourceMetadata(gomock.Any(), resourceID).
 		Return(metadata, true, nil).
 		Times(1)
 	a.
 		EXPECT().
-		DeleteBucket(bucketName).
+		DeleteBucket(gomock.Any(), bucketName).
 		Return(nil).
 		Times(1)
 
 	m.
 		EXPECT().
-		DeleteResourceMetadata(resourceID, gomock.AssignableToTypeOf(time.Now())).
+		DeleteResourceMetadata(gomock.Any(), resourceID, gomock.AssignableToTypeOf(time.Now())).
 		Return(nil).
 		Times(1)
 
@@ -289,7 +328,7 @@ func TestDeleteAWSResource_DoesNotExist(t *testing.T) {
 	a := mock_aws.NewMockClient(ctrl)
 	s := Server{
 		Model: m,
-		NewAwsClient: func(key, secret, reg string, timeout int) (aws.Client, error) {
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
 			is.Equal(key, accessKeyId)
 			is.Equal(secret, secretAccessKey)
 			is.Equal(reg, region)
@@ -307,7 +346,17 @@ func TestDeleteAWSResource_DoesNotExist(t *testing.T) {
 
 	m.
 		EXPECT().
-		SelectResourceMetadata(resourceID).
+		LockResource(gomock.Any(), resourceID).
+		Return(true, nil).
+		Times(1)
+	m.
+		EXPECT().
+		UnlockResource(gomock.Any(), resourceID).
+		Return(nil).
+		Times(1)
+	m.
+		EXPECT().
+		SelectResourceMetadata(gomock.Any(), resourceID).
 		Return(model.ResourceMetadata{}, false, nil).
 		Times(1)
 
@@ -321,3 +370,169 @@ func TestDeleteAWSResource_DoesNotExist(t *testing.T) {
 
 	is.Equal(res.Code, http.StatusNotFound)
 }
+
+func TestCreateAWSResource_PreviouslyDeleted(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	accessKeyId := "AWS_ACCESS_KEY_ID-value"
+	secretAccessKey := "AWS_SECRET_ACCESS_KEY-value"
+	region := "eu-west-1"
+
+	m := mock_model.NewMockModeler(ctrl)
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			return a, nil
+		},
+	}
+	resourceID := "test-db-id"
+	resType := "s3"
+	params := map[string]interface{}{
+		"region": "eu-west-1",
+	}
+	data := map[string]interface{}{
+		"region": "eu-west-1",
+		"bucket": "",
+	}
+	drd := messages.DriverResourceDefinition{
+		ID:             resourceID,
+		Type:           resType,
+		ResourceParams: map[string]interface{}{},
+		DriverParams:   params,
+		DriverSecrets: map[string]interface{}{
+			"account": map[string]interface{}{
+				"aws_access_key_id":     accessKeyId,
+				"aws_secret_access_key": secretAccessKey,
+			},
+		},
+	}
+
+	deletedMetadata := model.ResourceMetadata{
+		ID:        resourceID,
+		Type:      resType,
+		CreatedAt: time.Date(2020, 07, 16, 18, 12, 20, 0, time.UTC),
+		UpdatedAt: time.Date(2020, 07, 16, 18, 12, 20, 0, time.UTC),
+		DeletedAt: sql.NullTime{Time: time.Date(2020, 07, 17, 9, 0, 0, 0, time.UTC), Valid: true},
+		Params:    params,
+		Data: map[string]interface{}{
+			"region": "eu-west-1",
+			"bucket": "old-s3-bucket",
+		},
+	}
+	metadata := model.ResourceMetadata{
+		ID:        resourceID,
+		Type:      resType,
+		Status:    model.StatusReady,
+		DeletedAt: sql.NullTime{Valid: false},
+		Params:    params,
+		Data:      data,
+		Settings:  defaultBucketSettings(),
+	}
+
+	m.
+		EXPECT().
+		LockResource(gomock.Any(), resourceID).
+		Return(true, nil).
+		Times(1)
+	m.
+		EXPECT().
+		UnlockResource(gomock.Any(), resourceID).
+		Return(nil).
+		Times(1)
+	m.
+		EXPECT().
+		SelectResourceMetadata(gomock.Any(), resourceID).
+		Return(deletedMetadata, true, nil).
+		Times(1)
+	a.
+		EXPECT().
+		CreateBucket(gomock.Any(), gomock.AssignableToTypeOf(""), drd.ID).
+		Do(func(ctx, bn, resourceID interface{}) {
+			data["bucket"] = bn.(string)
+		}).
+		Return(region, nil).
+		Times(1)
+	a.
+		EXPECT().
+		SecureBucket(gomock.Any(), gomock.AssignableToTypeOf(""), gomock.Any()).
+		Return(nil).
+		Times(1)
+	m.
+		EXPECT().
+		InsertOrUpdateResourceMetadata(gomock.Any(), IgnoreDateResourceMetadata(metadata)).
+		Return(nil).
+		Times(1)
+
+	res := ExecuteRequest(s, http.MethodPost, "/", drd, t)
+
+	is.Equal(res.Code, http.StatusOK)
+	var returnedResourceData messages.ResourceData
+	json.Unmarshal(res.Body.Bytes(), &returnedResourceData)
+	is.True(returnedResourceData.Data.Values["bucket"] != "old-s3-bucket") // a new bucket is provisioned
+	is.Equal(returnedResourceData.Data.Values["bucket"], data["bucket"])
+}
+
+func TestDeleteAWSResource_AlreadyDeleted(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			return a, nil
+		},
+	}
+	resourceID := "test-db-id"
+	params := map[string]interface{}{
+		"region": "eu-west-1",
+	}
+	account := AWSCredentials{
+		AccessKeyID:     "AWS_ACCESS_KEY_ID-value",
+		SecretAccessKey: "AWS_SECRET_ACCESS_KEY-value",
+	}
+
+	metadata := model.ResourceMetadata{
+		ID:        resourceID,
+		Type:      "s3",
+		CreatedAt: time.Date(2020, 07, 16, 18, 12, 20, 0, time.UTC),
+		UpdatedAt: time.Date(2020, 07, 16, 18, 12, 20, 0, time.UTC),
+		DeletedAt: sql.NullTime{Time: time.Date(2020, 07, 17, 9, 0, 0, 0, time.UTC), Valid: true},
+		Params:    params,
+		Data: map[string]interface{}{
+			"region": "eu-west-1",
+			"bucket": "s3-bucket-name",
+		},
+	}
+
+	m.
+		EXPECT().
+		LockResource(gomock.Any(), resourceID).
+		Return(true, nil).
+		Times(1)
+	m.
+		EXPECT().
+		UnlockResource(gomock.Any(), resourceID).
+		Return(nil).
+		Times(1)
+	m.
+		EXPECT().
+		SelectResourceMetadata(gomock.Any(), resourceID).
+		Return(metadata, true, nil).
+		Times(1)
+
+	header := http.Header{}
+	jsonSecrets, _ := json.Marshal(map[string]interface{}{"account": account})
+	header.Add("Humanitec-Driver-Secrets", base64.StdEncoding.EncodeToString(jsonSecrets))
+	jsonParams, _ := json.Marshal(params)
+	header.Add("Humanitec-Driver-Params", base64.StdEncoding.EncodeToString(jsonParams))
+
+	res := ExecuteRequestHeader(s, http.MethodDelete, "/"+resourceID, nil, header, t)
+
+	is.Equal(res.Code, http.StatusNoContent)
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"context"
+	"time"
+)
+
+// detachedContext carries the values of the context it wraps, such as the logger and span, but is never cancelled and
+// has no deadline.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+// withoutCancel returns a context for work that must complete even if the request is cancelled, such as recording
+// that a resource has been created in AWS.
+func withoutCancel(ctx context.Context) context.Context {
+	return detachedContext{ctx}
+}
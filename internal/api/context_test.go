@@ -0,0 +1,80 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"humanitec.io/resources/driver-aws-external/internal/aws"
+	"humanitec.io/resources/driver-aws-external/internal/aws/mock_aws"
+	"humanitec.io/resources/driver-aws-external/internal/messages"
+	"humanitec.io/resources/driver-aws-external/internal/model"
+	"humanitec.io/resources/driver-aws-external/internal/model/mock_model"
+
+	"github.com/golang/mock/gomock"
+	"github.com/matryer/is"
+)
+
+func TestCreateAWSResource_CallerGoneAway(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			return a, nil
+		},
+	}
+	s.SetupRoutes()
+	resourceID := "test-db-id"
+	drd := messages.DriverResourceDefinition{
+		ID:   resourceID,
+		Type: "s3",
+		DriverParams: map[string]interface{}{
+			"region": "eu-west-1",
+		},
+		DriverSecrets: map[string]interface{}{
+			"account": map[string]interface{}{
+				"aws_access_key_id":     "AWS_ACCESS_KEY_ID-value",
+				"aws_secret_access_key": "AWS_SECRET_ACCESS_KEY-value",
+			},
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m.EXPECT().LockResource(gomock.Any(), resourceID).Return(true, nil)
+	m.EXPECT().SelectResourceMetadata(gomock.Any(), resourceID).Return(model.ResourceMetadata{}, false, nil)
+	// The caller disconnects once the bucket has been created.
+	a.
+		EXPECT().
+		CreateBucket(gomock.Any(), gomock.AssignableToTypeOf(""), drd.ID).
+		Do(func(ctx, bn, resourceID interface{}) { cancel() }).
+		Return("eu-west-1", nil)
+	a.
+		EXPECT().
+		SecureBucket(gomock.Any(), gomock.AssignableToTypeOf(""), gomock.Any()).
+		Return(nil)
+	m.
+		EXPECT().
+		InsertOrUpdateResourceMetadata(gomock.Any(), gomock.Any()).
+		Do(func(ctx, m interface{}) {
+			is.NoErr(ctx.(context.Context).Err()) // the bucket is recorded regardless
+		}).
+		Return(nil)
+	m.EXPECT().UnlockResource(gomock.Any(), resourceID).Return(nil)
+
+	body, err := json.Marshal(drd)
+	is.NoErr(err)
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)).WithContext(ctx)
+	rr := httptest.NewRecorder()
+	s.Router.ServeHTTP(rr, req)
+
+	is.Equal(rr.Code, http.StatusOK)
+}
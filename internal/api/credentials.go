@@ -0,0 +1,58 @@
+package api
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// sealCredentials encrypts AWS credentials with AES-256-GCM so that they can be kept in the metadata database. The id of
+// the resource they are kept for is authenticated along with them, so that they cannot be moved to another resource.
+func sealCredentials(key []byte, id string, creds AWSCredentials) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := json.Marshal(creds)
+	if err != nil {
+		return "", fmt.Errorf("sealing credentials: %w", err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("sealing credentials: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, []byte(id))), nil
+}
+
+// openCredentials decrypts credentials sealed with sealCredentials for the resource with the supplied id.
+func openCredentials(key []byte, id, sealed string) (AWSCredentials, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return AWSCredentials{}, err
+	}
+	b, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(b) < aead.NonceSize() {
+		return AWSCredentials{}, fmt.Errorf("opening credentials: malformed")
+	}
+	plaintext, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], []byte(id))
+	if err != nil {
+		return AWSCredentials{}, fmt.Errorf("opening credentials: %w", err)
+	}
+	var creds AWSCredentials
+	if err := json.Unmarshal(plaintext, &creds); err != nil {
+		return AWSCredentials{}, fmt.Errorf("opening credentials: %w", err)
+	}
+	return creds, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("credentials key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestSealCredentials(t *testing.T) {
+	is := is.New(t)
+	key := []byte("0123456789abcdef0123456789abcdef")
+	creds := AWSCredentials{AccessKeyID: "AKIAEXAMPLE", SecretAccessKey: "secret-access-key"}
+
+	sealed, err := sealCredentials(key, "test-db-id", creds)
+	is.NoErr(err)
+	is.True(!strings.Contains(sealed, "AKIAEXAMPLE"))
+
+	opened, err := openCredentials(key, "test-db-id", sealed)
+	is.NoErr(err)
+	is.Equal(opened, creds)
+
+	_, err = openCredentials(key, "other-id", sealed)
+	is.True(err != nil) // bound to the resource they were kept for
+	_, err = openCredentials([]byte("fedcba9876543210fedcba9876543210"), "test-db-id", sealed)
+	is.True(err != nil) // needs the same key
+	_, err = openCredentials(key, "test-db-id", "not-sealed")
+	is.True(err != nil)
+}
@@ -0,0 +1,26 @@
+package api
+
+// withDefaults returns a copy of the driver_params of a resource of the supplied type, with the configured defaults
+// filled in for any that are missing. The region defaults to DefaultRegion and the rest to the defaults for that
+// region in RegionDefaults.
+func (s *Server) withDefaults(resourceType string, params map[string]interface{}) map[string]interface{} {
+	withDefaults := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		withDefaults[k] = v
+	}
+	setDefault := func(name, value string) {
+		if _, ok := withDefaults[name]; !ok && value != "" {
+			withDefaults[name] = value
+		}
+	}
+
+	setDefault("region", s.DefaultRegion)
+	region, _ := withDefaults["region"].(string)
+	defaults := s.RegionDefaults[region]
+	switch resourceType {
+	case "redis":
+		setDefault("cache_node_type", defaults.CacheNodeType)
+		setDefault("cache_az", defaults.CacheAZ)
+	}
+	return withDefaults
+}
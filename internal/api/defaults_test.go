@@ -0,0 +1,42 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+	"humanitec.io/resources/driver-aws-external/internal/config"
+)
+
+func TestWithDefaults(t *testing.T) {
+	is := is.New(t)
+	s := Server{
+		DefaultRegion: "eu-west-1",
+		RegionDefaults: map[string]config.RegionDefaults{
+			"eu-west-1":    {CacheNodeType: "cache.t3.micro", CacheAZ: "eu-west-1a"},
+			"eu-central-1": {CacheNodeType: "cache.t3.small"},
+		},
+	}
+
+	params := map[string]interface{}{}
+	is.Equal(s.withDefaults("redis", params), map[string]interface{}{
+		"region":          "eu-west-1",
+		"cache_node_type": "cache.t3.micro",
+		"cache_az":        "eu-west-1a",
+	})
+	is.Equal(len(params), 0) // the params are not modified
+
+	is.Equal(s.withDefaults("redis", map[string]interface{}{
+		"region":   "eu-central-1",
+		"cache_az": "eu-central-1b",
+	}), map[string]interface{}{
+		"region":          "eu-central-1",
+		"cache_node_type": "cache.t3.small",
+		"cache_az":        "eu-central-1b",
+	})
+
+	// Only redis resources use the cache settings.
+	is.Equal(s.withDefaults("s3", nil), map[string]interface{}{"region": "eu-west-1"})
+
+	// Without defaults, params are unchanged.
+	is.Equal((&Server{}).withDefaults("redis", map[string]interface{}{"region": "us-east-1"}), map[string]interface{}{"region": "us-east-1"})
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"humanitec.io/resources/driver-aws-external/internal/logging"
+	"humanitec.io/resources/driver-aws-external/internal/messages"
+	"humanitec.io/resources/driver-aws-external/internal/model"
+)
+
+// Deletion policies decide what happens in AWS when a resource is deleted.
+const (
+	// deletionPolicyDelete deletes the bucket or cluster.
+	deletionPolicyDelete = "delete"
+	// deletionPolicyRetain leaves the bucket or cluster in AWS, marked with aws.RetainedTag, and stops managing it.
+	deletionPolicyRetain = "retain"
+	// deletionPolicySnapshot deletes the cluster after taking a final snapshot of it.
+	deletionPolicySnapshot = "snapshot"
+)
+
+var deletionPolicies = []string{deletionPolicyDelete, deletionPolicyRetain, deletionPolicySnapshot}
+
+// deletionParamNames are the driver_params that control deletion. Unlike the rest of the driver_params, they can be
+// changed after a resource has been created.
+var deletionParamNames = []string{"deletion_policy", "deletion_protection"}
+
+// deletionParams reads the deletion policy of a resource of the supplied type and whether it is protected from
+// deletion from its driver_params. Resources are deleted and unprotected unless stated otherwise.
+func deletionParams(resourceType string, params map[string]interface{}) (string, bool, error) {
+	policy, err := stringParam(params, "deletion_policy", deletionPolicyDelete)
+	if err != nil {
+		return "", false, err
+	}
+	if !oneOf(policy, deletionPolicies...) {
+		return "", false, invalidParams(`"deletion_policy" property in driver_params: expected one of %q, got "%s"`, deletionPolicies, policy)
+	}
+	if policy == deletionPolicySnapshot && resourceType != "redis" {
+		return "", false, invalidParams(`"deletion_policy" property in driver_params: "%s" is only supported for redis`, policy)
+	}
+	protected, err := boolParam(params, "deletion_protection", false)
+	if err != nil {
+		return "", false, err
+	}
+	return policy, protected, nil
+}
+
+// reconcileDeletionParams records changes to the deletion policy and deletion protection in driver_params in the
+// metadata of an existing resource.
+func (s *Server) reconcileDeletionParams(ctx context.Context, drd messages.DriverResourceDefinition, metadata *model.ResourceMetadata) error {
+	if _, _, err := deletionParams(metadata.Type, drd.DriverParams); err != nil {
+		logging.FromContext(ctx).WithError(err).Error("Invalid deletion policy")
+		return err
+	}
+
+	params := copyParams(metadata.Params)
+	changed := false
+	for _, name := range deletionParamNames {
+		value, set := drd.DriverParams[name]
+		if !set || value == nil {
+			if _, recorded := params[name]; recorded {
+				delete(params, name)
+				changed = true
+			}
+		} else if !reflect.DeepEqual(params[name], value) {
+			params[name] = value
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	logging.FromContext(ctx).Info("Updating deletion policy.")
+	metadata.Params = params
+	metadata.UpdatedAt = time.Now().UTC()
+	return s.Model.InsertOrUpdateResourceMetadata(withoutCancel(ctx), *metadata)
+}
+
+// finalSnapshotName names the snapshot taken of a cluster deleted with the "snapshot" policy. The time of deletion is
+// included so that clusters recreated under the same id can be snapshotted again.
+func finalSnapshotName(clusterId string, deletedAt time.Time) string {
+	return clusterId + "-final-" + deletedAt.UTC().Format("20060102-150405")
+}
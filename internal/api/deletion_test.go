@@ -0,0 +1,557 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"humanitec.io/resources/driver-aws-external/internal/aws"
+	"humanitec.io/resources/driver-aws-external/internal/aws/mock_aws"
+	"humanitec.io/resources/driver-aws-external/internal/config"
+	"humanitec.io/resources/driver-aws-external/internal/messages"
+	"humanitec.io/resources/driver-aws-external/internal/model"
+	"humanitec.io/resources/driver-aws-external/internal/model/mock_model"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/golang/mock/gomock"
+	"github.com/matryer/is"
+)
+
+// deleteHeader returns the headers of a DELETE request for the supplied resource definition.
+func deleteHeader(drd messages.DriverResourceDefinition) http.Header {
+	header := http.Header{}
+	jsonSecrets, _ := json.Marshal(drd.DriverSecrets)
+	header.Add("Humanitec-Driver-Secrets", base64.StdEncoding.EncodeToString(jsonSecrets))
+	jsonParams, _ := json.Marshal(drd.DriverParams)
+	header.Add("Humanitec-Driver-Params", base64.StdEncoding.EncodeToString(jsonParams))
+	return header
+}
+
+func TestDeletionParams(t *testing.T) {
+	is := is.New(t)
+
+	policy, protected, err := deletionParams("s3", map[string]interface{}{})
+	is.NoErr(err)
+	is.Equal(policy, "delete")
+	is.True(!protected)
+
+	policy, protected, err = deletionParams("redis", map[string]interface{}{"deletion_policy": "snapshot", "deletion_protection": true})
+	is.NoErr(err)
+	is.Equal(policy, "snapshot")
+	is.True(protected)
+
+	for name, tc := range map[string]struct {
+		resourceType string
+		params       map[string]interface{}
+	}{
+		"unknown policy":    {"s3", map[string]interface{}{"deletion_policy": "archive"}},
+		"policy not string": {"s3", map[string]interface{}{"deletion_policy": true}},
+		"snapshot of s3":    {"s3", map[string]interface{}{"deletion_policy": "snapshot"}},
+		"protection string": {"redis", map[string]interface{}{"deletion_protection": "yes"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			_, _, err := deletionParams(tc.resourceType, tc.params)
+			var perr *invalidParamsError
+			is.True(errors.As(err, &perr))
+		})
+	}
+}
+
+func TestFinalSnapshotName(t *testing.T) {
+	is := is.New(t)
+	is.Equal(finalSnapshotName("redis-1", time.Date(2020, 07, 16, 18, 12, 20, 0, time.UTC)), "redis-1-final-20200716-181220")
+}
+
+func TestDeleteAWSResource_Protected(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			t.Fatal("AWS must not be called for protected resources")
+			return nil, nil
+		},
+	}
+	resourceID := "test-db-id"
+	drd := newRedisDriverResourceDefinition(resourceID)
+	params := copyParams(drd.DriverParams)
+	params["deletion_protection"] = true
+
+	m.EXPECT().LockResource(gomock.Any(), resourceID).Return(true, nil)
+	m.EXPECT().UnlockResource(gomock.Any(), resourceID).Return(nil)
+	m.
+		EXPECT().
+		SelectResourceMetadata(gomock.Any(), resourceID).
+		Return(model.ResourceMetadata{
+			ID:     resourceID,
+			Type:   "redis",
+			Status: model.StatusReady,
+			Params: params,
+			Data:   map[string]interface{}{"host": "redis-1.abc123.0001.euw1.cache.amazonaws.com", "port": 6379},
+		}, true, nil)
+
+	// Protection recorded for the resource applies whatever the headers say.
+	res := ExecuteRequestHeader(s, http.MethodDelete, "/"+resourceID, nil, deleteHeader(drd), t)
+
+	is.Equal(res.Code, http.StatusConflict)
+}
+
+func TestDeleteAWSResource_Retain(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			is.Equal(reg, "eu-west-1")
+			return a, nil
+		},
+	}
+	resourceID := "test-db-id"
+	drd := messages.DriverResourceDefinition{
+		ID:           resourceID,
+		Type:         "s3",
+		DriverParams: map[string]interface{}{"region": "eu-west-1", "deletion_policy": "retain"},
+		DriverSecrets: map[string]interface{}{
+			"account": map[string]interface{}{
+				"aws_access_key_id":     "AWS_ACCESS_KEY_ID-value",
+				"aws_secret_access_key": "AWS_SECRET_ACCESS_KEY-value",
+			},
+		},
+	}
+
+	m.EXPECT().LockResource(gomock.Any(), resourceID).Return(true, nil)
+	m.EXPECT().UnlockResource(gomock.Any(), resourceID).Return(nil)
+	m.
+		EXPECT().
+		SelectResourceMetadata(gomock.Any(), resourceID).
+		Return(model.ResourceMetadata{
+			ID:     resourceID,
+			Type:   "s3",
+			Status: model.StatusReady,
+			Params: drd.DriverParams,
+			Data:   map[string]interface{}{"region": "eu-west-1", "bucket": "my-s3-bucket"},
+		}, true, nil)
+	a.
+		EXPECT().
+		TagBucket(gomock.Any(), "my-s3-bucket", map[string]string{aws.RetainedTag: resourceID}).
+		Return(nil).
+		Times(1)
+	m.EXPECT().DeleteResourceMetadata(gomock.Any(), resourceID, gomock.Any()).Return(nil)
+
+	res := ExecuteRequestHeader(s, http.MethodDelete, "/"+resourceID, nil, deleteHeader(drd), t)
+
+	is.Equal(res.Code, http.StatusNoContent)
+}
+
+func TestCreateAWSResource_Retained(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			return a, nil
+		},
+	}
+	resourceID := "test-db-id"
+	drd := newRedisDriverResourceDefinition(resourceID)
+
+	m.EXPECT().LockResource(gomock.Any(), resourceID).Return(true, nil)
+	m.EXPECT().UnlockResource(gomock.Any(), resourceID).Return(nil)
+	m.
+		EXPECT().
+		SelectResourceMetadata(gomock.Any(), resourceID).
+		Return(model.ResourceMetadata{
+			ID:        resourceID,
+			Type:      "redis",
+			Status:    model.StatusReady,
+			Params:    drd.DriverParams,
+			DeletedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		}, true, nil)
+	a.
+		EXPECT().
+		CreateElastiCacheRedis(gomock.Any(), gomock.AssignableToTypeOf(""), resourceID, "cache.t3.micro", "eu-west-1a").
+		Return("", fmt.Errorf(`Elasticache cluster "redis-1" %w`, aws.ErrNotOwned))
+
+	res := ExecuteRequest(s, http.MethodPost, "/", drd, t)
+
+	is.Equal(res.Code, http.StatusConflict) // the cluster retained when the resource was deleted is not taken over
+}
+
+func TestDeleteAWSResource_Snapshot(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			return a, nil
+		},
+	}
+	resourceID := "test-db-id"
+	drd := newRedisDriverResourceDefinition(resourceID)
+	drd.DriverParams["deletion_policy"] = "snapshot"
+
+	m.EXPECT().LockResource(gomock.Any(), resourceID).Return(true, nil)
+	m.EXPECT().UnlockResource(gomock.Any(), resourceID).Return(nil)
+	m.
+		EXPECT().
+		SelectResourceMetadata(gomock.Any(), resourceID).
+		Return(model.ResourceMetadata{
+			ID:     resourceID,
+			Type:   "redis",
+			Status: model.StatusReady,
+			Params: drd.DriverParams,
+			Data:   map[string]interface{}{"host": "redis-1.abc123.0001.euw1.cache.amazonaws.com", "port": 6379},
+		}, true, nil)
+	a.
+		EXPECT().
+		DeleteElastiCacheRedis(gomock.Any(), "redis-1", gomock.Any()).
+		Do(func(ctx, clusterId, finalSnapshotId interface{}) {
+			is.True(strings.HasPrefix(finalSnapshotId.(string), "redis-1-final-"))
+		}).
+		Return(nil).
+		Times(1)
+	m.EXPECT().InsertOrUpdateResourceMetadata(gomock.Any(), gomock.Any()).Return(nil)
+	a.EXPECT().WaitForElastiCacheRedisDeleted(gomock.Any(), "redis-1").Return(nil)
+	m.EXPECT().DeleteResourceMetadata(gomock.Any(), resourceID, gomock.Any()).Return(nil)
+
+	res := ExecuteRequestHeader(s, http.MethodDelete, "/"+resourceID, nil, deleteHeader(drd), t)
+
+	is.Equal(res.Code, http.StatusNoContent)
+}
+
+func TestDeleteAWSResource_WaitForCluster(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			return a, nil
+		},
+	}
+	resourceID := "test-db-id"
+	drd := newRedisDriverResourceDefinition(resourceID)
+
+	m.EXPECT().LockResource(gomock.Any(), resourceID).Return(true, nil)
+	m.EXPECT().UnlockResource(gomock.Any(), resourceID).Return(nil)
+	m.
+		EXPECT().
+		SelectResourceMetadata(gomock.Any(), resourceID).
+		Return(model.ResourceMetadata{
+			ID:     resourceID,
+			Type:   "redis",
+			Status: model.StatusReady,
+			Params: drd.DriverParams,
+			Data:   map[string]interface{}{"host": "redis-1.abc123.0001.euw1.cache.amazonaws.com", "port": 6379},
+		}, true, nil)
+	a.EXPECT().DeleteElastiCacheRedis(gomock.Any(), "redis-1", "").Return(nil)
+	m.
+		EXPECT().
+		InsertOrUpdateResourceMetadata(gomock.Any(), gomock.Any()).
+		Do(func(ctx context.Context, metadata model.ResourceMetadata) {
+			is.True(metadata.IsDeleting())
+			is.True(metadata.DeleteAfter.Valid) // picked up by the deletion worker
+			is.Equal(metadata.DeletionRequest, map[string]interface{}{
+				"driver_params": drd.DriverParams, // no credentials are kept without a credentials key
+			})
+		}).
+		Return(nil)
+	// AWS takes longer to remove the cluster than the request waits for it.
+	a.EXPECT().WaitForElastiCacheRedisDeleted(gomock.Any(), "redis-1").
+		Return(awserr.New(request.WaiterResourceNotReadyErrorCode, `gave up waiting for cluster "redis-1" to be deleted after 5m0s`, nil))
+
+	res := ExecuteRequestHeader(s, http.MethodDelete, "/"+resourceID, nil, deleteHeader(drd), t)
+
+	is.Equal(res.Code, http.StatusAccepted) // not recorded as deleted until the cluster is gone
+}
+
+func TestDeleteAWSResource_WaitForClusterFails(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			return a, nil
+		},
+		Deletion: config.Deletion{GracePeriod: time.Hour},
+	}
+	resourceID := "test-db-id"
+	drd := newRedisDriverResourceDefinition(resourceID)
+
+	m.EXPECT().LockResource(gomock.Any(), resourceID).Return(true, nil)
+	m.EXPECT().UnlockResource(gomock.Any(), resourceID).Return(nil)
+	m.
+		EXPECT().
+		SelectResourceMetadata(gomock.Any(), resourceID).
+		Return(model.ResourceMetadata{
+			ID:     resourceID,
+			Type:   "redis",
+			Status: model.StatusDeleting,
+			Params: drd.DriverParams,
+			Data:   map[string]interface{}{"host": "redis-1.abc123.0001.euw1.cache.amazonaws.com", "port": 6379},
+		}, true, nil)
+	a.EXPECT().DescribeElastiCacheRedis(gomock.Any(), "redis-1").Return(aws.CacheCluster{ID: "redis-1", Status: "deleting"}, nil)
+	// Only giving up on waiting means that the cluster is still being deleted.
+	a.EXPECT().WaitForElastiCacheRedisDeleted(gomock.Any(), "redis-1").
+		Return(awserr.NewRequestFailure(awserr.New("AccessDenied", "not authorized", nil), 403, "request-1"))
+
+	res := ExecuteRequestHeader(s, http.MethodDelete, "/"+resourceID, nil, deleteHeader(drd), t)
+
+	is.Equal(res.Code, http.StatusInternalServerError)
+}
+
+func TestDeleteAWSResource_Deleting(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			return a, nil
+		},
+		Deletion: config.Deletion{GracePeriod: time.Hour},
+	}
+	resourceID := "test-db-id"
+	drd := newRedisDriverResourceDefinition(resourceID)
+
+	m.EXPECT().LockResource(gomock.Any(), resourceID).Return(true, nil)
+	m.EXPECT().UnlockResource(gomock.Any(), resourceID).Return(nil)
+	m.
+		EXPECT().
+		SelectResourceMetadata(gomock.Any(), resourceID).
+		Return(model.ResourceMetadata{
+			ID:     resourceID,
+			Type:   "redis",
+			Status: model.StatusDeleting,
+			Params: drd.DriverParams,
+			Data:   map[string]interface{}{"host": "redis-1.abc123.0001.euw1.cache.amazonaws.com", "port": 6379},
+		}, true, nil)
+	// The retried request waits for the deletion AWS has already accepted, rather than deleting the cluster again or
+	// scheduling its deletion.
+	a.EXPECT().DescribeElastiCacheRedis(gomock.Any(), "redis-1").Return(aws.CacheCluster{ID: "redis-1", Status: "deleting"}, nil)
+	a.EXPECT().WaitForElastiCacheRedisDeleted(gomock.Any(), "redis-1").Return(nil)
+	m.EXPECT().DeleteResourceMetadata(gomock.Any(), resourceID, gomock.Any()).Return(nil)
+
+	res := ExecuteRequestHeader(s, http.MethodDelete, "/"+resourceID, nil, deleteHeader(drd), t)
+
+	is.Equal(res.Code, http.StatusNoContent)
+}
+
+func TestDeleteAWSResource_DeletingAvailable(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			return a, nil
+		},
+		Deletion: config.Deletion{GracePeriod: time.Hour},
+	}
+	resourceID := "test-db-id"
+	drd := newRedisDriverResourceDefinition(resourceID)
+	metadata := model.ResourceMetadata{
+		ID:     resourceID,
+		Type:   "redis",
+		Status: model.StatusDeleting,
+		Params: drd.DriverParams,
+		Data:   map[string]interface{}{"host": "redis-1.abc123.0001.euw1.cache.amazonaws.com", "port": 6379},
+	}
+
+	// AWS stops deleting the cluster while the first request waits for it, e.g. because its final snapshot failed.
+	m.EXPECT().LockResource(gomock.Any(), resourceID).Return(true, nil).Times(2)
+	m.EXPECT().UnlockResource(gomock.Any(), resourceID).Return(nil).Times(2)
+	m.EXPECT().SelectResourceMetadata(gomock.Any(), resourceID).Return(metadata, true, nil).Times(2)
+	a.EXPECT().DescribeElastiCacheRedis(gomock.Any(), "redis-1").Return(aws.CacheCluster{ID: "redis-1", Status: "deleting"}, nil)
+	a.EXPECT().WaitForElastiCacheRedisDeleted(gomock.Any(), "redis-1").Return(fmt.Errorf(`cluster "redis-1" %w`, aws.ErrDeletionAbandoned))
+
+	res := ExecuteRequestHeader(s, http.MethodDelete, "/"+resourceID, nil, deleteHeader(drd), t)
+	is.Equal(res.Code, http.StatusInternalServerError)
+
+	// Retrying the request deletes the cluster again.
+	a.EXPECT().DescribeElastiCacheRedis(gomock.Any(), "redis-1").Return(aws.CacheCluster{ID: "redis-1", Status: "available"}, nil)
+	a.EXPECT().DeleteElastiCacheRedis(gomock.Any(), "redis-1", "").Return(nil)
+	m.
+		EXPECT().
+		InsertOrUpdateResourceMetadata(gomock.Any(), gomock.Any()).
+		Do(func(ctx context.Context, metadata model.ResourceMetadata) {
+			is.True(metadata.IsDeleting())
+		}).
+		Return(nil)
+	a.EXPECT().WaitForElastiCacheRedisDeleted(gomock.Any(), "redis-1").Return(nil)
+	m.EXPECT().DeleteResourceMetadata(gomock.Any(), resourceID, gomock.Any()).Return(nil)
+
+	res = ExecuteRequestHeader(s, http.MethodDelete, "/"+resourceID, nil, deleteHeader(drd), t)
+	is.Equal(res.Code, http.StatusNoContent)
+}
+
+func TestDeleteAWSResource_RecordingFails(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			return a, nil
+		},
+	}
+	resourceID := "test-db-id"
+	drd := newRedisDriverResourceDefinition(resourceID)
+
+	m.EXPECT().LockResource(gomock.Any(), resourceID).Return(true, nil)
+	m.EXPECT().UnlockResource(gomock.Any(), resourceID).Return(nil)
+	m.
+		EXPECT().
+		SelectResourceMetadata(gomock.Any(), resourceID).
+		Return(model.ResourceMetadata{
+			ID:     resourceID,
+			Type:   "redis",
+			Status: model.StatusDeleting,
+			Params: drd.DriverParams,
+			Data:   map[string]interface{}{"host": "redis-1.abc123.0001.euw1.cache.amazonaws.com", "port": 6379},
+		}, true, nil)
+	a.EXPECT().DescribeElastiCacheRedis(gomock.Any(), "redis-1").Return(aws.CacheCluster{ID: "redis-1", Status: "deleting"}, nil)
+	a.EXPECT().WaitForElastiCacheRedisDeleted(gomock.Any(), "redis-1").Return(nil)
+	m.EXPECT().DeleteResourceMetadata(gomock.Any(), resourceID, gomock.Any()).Return(errors.New("connection refused"))
+
+	res := ExecuteRequestHeader(s, http.MethodDelete, "/"+resourceID, nil, deleteHeader(drd), t)
+
+	is.Equal(res.Code, http.StatusInternalServerError) // retrying the request records the deletion
+}
+
+func TestCreateAWSResource_Deleting(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	s := Server{Model: m}
+	resourceID := "test-db-id"
+	drd := newRedisDriverResourceDefinition(resourceID)
+
+	m.EXPECT().LockResource(gomock.Any(), resourceID).Return(true, nil)
+	m.EXPECT().UnlockResource(gomock.Any(), resourceID).Return(nil)
+	m.
+		EXPECT().
+		SelectResourceMetadata(gomock.Any(), resourceID).
+		Return(model.ResourceMetadata{
+			ID:     resourceID,
+			Type:   "redis",
+			Status: model.StatusDeleting,
+			Params: drd.DriverParams,
+			Data:   map[string]interface{}{"host": "redis-1.abc123.0001.euw1.cache.amazonaws.com", "port": 6379},
+		}, true, nil)
+
+	res := ExecuteRequest(s, http.MethodPost, "/", drd, t)
+
+	is.Equal(res.Code, http.StatusConflict) // the cluster's name is not free until it is gone
+}
+
+func TestCreateAWSResource_UpdateDeletionProtection(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			t.Fatal("AWS must not be called to change the deletion policy")
+			return nil, nil
+		},
+	}
+	resourceID := "test-db-id"
+	drd := newRedisDriverResourceDefinition(resourceID)
+	drd.DriverParams["deletion_policy"] = "retain"
+	recorded := copyParams(drd.DriverParams)
+	recorded["deletion_protection"] = true
+	delete(recorded, "deletion_policy")
+	metadata := model.ResourceMetadata{
+		ID:     resourceID,
+		Type:   "redis",
+		Status: model.StatusReady,
+		Params: recorded,
+		Data:   map[string]interface{}{"host": "redis-1.abc123.0001.euw1.cache.amazonaws.com", "port": float64(6379)},
+	}
+	updated := metadata
+	updated.Params = drd.DriverParams
+
+	m.EXPECT().LockResource(gomock.Any(), resourceID).Return(true, nil)
+	m.EXPECT().UnlockResource(gomock.Any(), resourceID).Return(nil)
+	m.EXPECT().SelectResourceMetadata(gomock.Any(), resourceID).Return(metadata, true, nil)
+	m.
+		EXPECT().
+		InsertOrUpdateResourceMetadata(gomock.Any(), IgnoreDateResourceMetadata(updated)).
+		Return(nil).
+		Times(1)
+
+	res := ExecuteRequest(s, http.MethodPost, "/", drd, t)
+
+	is.Equal(res.Code, http.StatusOK)
+}
+
+func TestCreateAWSResource_InvalidDeletionPolicy(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			t.Fatal("AWS must not be called for invalid params")
+			return nil, nil
+		},
+	}
+	resourceID := "test-db-id"
+	drd := newRedisDriverResourceDefinition(resourceID)
+	drd.DriverParams["deletion_policy"] = "archive"
+
+	m.EXPECT().LockResource(gomock.Any(), resourceID).Return(true, nil)
+	m.EXPECT().UnlockResource(gomock.Any(), resourceID).Return(nil)
+	m.EXPECT().SelectResourceMetadata(gomock.Any(), resourceID).Return(model.ResourceMetadata{}, false, nil)
+
+	res := ExecuteRequest(s, http.MethodPost, "/", drd, t)
+
+	is.Equal(res.Code, http.StatusBadRequest)
+}
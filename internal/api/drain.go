@@ -0,0 +1,15 @@
+package api
+
+import (
+	"sync/atomic"
+)
+
+// StartDraining marks the server as shutting down. From then on readiness checks fail so that no new requests are
+// routed to it while in-flight requests complete.
+func (s *Server) StartDraining() {
+	atomic.StoreInt32(&s.draining, 1)
+}
+
+func (s *Server) isDraining() bool {
+	return atomic.LoadInt32(&s.draining) == 1
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"humanitec.io/resources/driver-aws-external/internal/logging"
+)
+
+const defaultHealthCheckTimeout = 2 * time.Second
+
+// Statuses reported by /health.
+const (
+	healthOK          = "ok"
+	healthUnavailable = "unavailable"
+	healthDraining    = "draining"
+)
+
+// componentHealth is the result of checking one dependency.
+type componentHealth struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// healthReport is the body returned by /health.
+type healthReport struct {
+	Status     string                     `json:"status"`
+	Components map[string]componentHealth `json:"components,omitempty"`
+}
+
+// isReady checks that the dependencies needed to handle requests are available. The checks run concurrently, each
+// limited by HealthCheckTimeout.
+func (s *Server) isReady(w http.ResponseWriter, r *http.Request) {
+	if s.isDraining() {
+		writeAsJSON(w, http.StatusServiceUnavailable, healthReport{Status: healthDraining})
+		return
+	}
+
+	checks := map[string]func(context.Context) error{
+		"database":   s.Model.Ping,
+		"migrations": s.Model.CheckSchema,
+	}
+	if s.PingAWS != nil {
+		checks["aws_sts"] = s.PingAWS
+	}
+
+	timeout := s.HealthCheckTimeout
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+
+	report := healthReport{Status: healthOK, Components: map[string]componentHealth{}}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(context.Context) error) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			result := componentHealth{Status: healthOK}
+			if err := check(ctx); err != nil {
+				logging.FromContext(r.Context()).WithError(err).Warnf("Health check %s failed", name)
+				result = componentHealth{Status: healthUnavailable, Error: err.Error()}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[name] = result
+			if result.Status != healthOK {
+				report.Status = healthUnavailable
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	if report.Status != healthOK {
+		status = http.StatusServiceUnavailable
+	}
+	writeAsJSON(w, status, report)
+}
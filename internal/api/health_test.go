@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"humanitec.io/resources/driver-aws-external/internal/model"
+	"humanitec.io/resources/driver-aws-external/internal/model/mock_model"
+
+	"github.com/golang/mock/gomock"
+	"github.com/matryer/is"
+)
+
+func checkHealth(is *is.I, s *Server) (int, healthReport) {
+	rr := httptest.NewRecorder()
+	s.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var report healthReport
+	is.NoErr(json.Unmarshal(rr.Body.Bytes(), &report))
+	return rr.Code, report
+}
+
+func TestIsReady(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockModel := mock_model.NewMockModeler(ctrl)
+	mockModel.EXPECT().Ping(gomock.Any()).Return(nil)
+	mockModel.EXPECT().CheckSchema(gomock.Any()).Return(nil)
+
+	s := Server{
+		Model:   mockModel,
+		PingAWS: func(ctx context.Context) error { return nil },
+	}
+	s.SetupRoutes()
+
+	code, report := checkHealth(is, &s)
+	is.Equal(code, http.StatusOK)
+	is.Equal(report, healthReport{
+		Status: healthOK,
+		Components: map[string]componentHealth{
+			"database":   {Status: healthOK},
+			"migrations": {Status: healthOK},
+			"aws_sts":    {Status: healthOK},
+		},
+	})
+}
+
+func TestIsReady_Unavailable(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockModel := mock_model.NewMockModeler(ctrl)
+	mockModel.EXPECT().Ping(gomock.Any()).DoAndReturn(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	mockModel.EXPECT().CheckSchema(gomock.Any()).Return(model.ErrMigrationsPending)
+
+	s := Server{
+		Model:              mockModel,
+		HealthCheckTimeout: 10 * time.Millisecond,
+	}
+	s.SetupRoutes()
+
+	code, report := checkHealth(is, &s)
+	is.Equal(code, http.StatusServiceUnavailable)
+	is.Equal(report, healthReport{
+		Status: healthUnavailable,
+		Components: map[string]componentHealth{
+			"database":   {Status: healthUnavailable, Error: context.DeadlineExceeded.Error()},
+			"migrations": {Status: healthUnavailable, Error: model.ErrMigrationsPending.Error()},
+		},
+	})
+}
+
+func TestIsReady_AWSUnavailable(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockModel := mock_model.NewMockModeler(ctrl)
+	mockModel.EXPECT().Ping(gomock.Any()).Return(nil)
+	mockModel.EXPECT().CheckSchema(gomock.Any()).Return(nil)
+
+	s := Server{
+		Model:   mockModel,
+		PingAWS: func(ctx context.Context) error { return errors.New("no route to host") },
+	}
+	s.SetupRoutes()
+
+	code, report := checkHealth(is, &s)
+	is.Equal(code, http.StatusServiceUnavailable)
+	is.Equal(report.Status, healthUnavailable)
+	is.Equal(report.Components["aws_sts"], componentHealth{Status: healthUnavailable, Error: "no route to host"})
+	is.Equal(report.Components["database"].Status, healthOK)
+}
+
+func TestIsReady_Draining(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Dependencies are not checked once draining.
+	s := Server{Model: mock_model.NewMockModeler(ctrl)}
+	s.SetupRoutes()
+	s.StartDraining()
+
+	code, report := checkHealth(is, &s)
+	is.Equal(code, http.StatusServiceUnavailable)
+	is.Equal(report, healthReport{Status: healthDraining})
+}
+
+func TestIsAlive(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Liveness does not touch the database.
+	s := Server{Model: mock_model.NewMockModeler(ctrl)}
+	s.SetupRoutes()
+
+	rr := httptest.NewRecorder()
+	s.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/alive", nil))
+	is.Equal(rr.Code, http.StatusOK)
+}
@@ -0,0 +1,251 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"humanitec.io/resources/driver-aws-external/internal/aws"
+	"humanitec.io/resources/driver-aws-external/internal/logging"
+	"humanitec.io/resources/driver-aws-external/internal/messages"
+	"humanitec.io/resources/driver-aws-external/internal/model"
+	"humanitec.io/resources/driver-aws-external/internal/tracing"
+)
+
+// lookupRegion is the region used to look up buckets whose region is not known. S3 reports the location of buckets in
+// any region.
+const lookupRegion = "us-east-1"
+
+// importAWSResource takes over a bucket or cluster that was created outside the driver. Once it has been checked to
+// exist, it is recorded like a resource the driver created and managed the same way from then on.
+func (s *Server) importAWSResource(w http.ResponseWriter, r *http.Request) {
+	var req messages.ImportRequest
+	if !readAsJSON(w, r, &req) {
+		return
+	}
+	if !isValidAsID(req.ID) {
+		writeAsJSON(w, http.StatusBadRequest, fmt.Sprintf("Invalid resource ID: %s", req.ID))
+		return
+	}
+	if req.Identifier == "" {
+		writeAsJSON(w, http.StatusBadRequest, `"identifier" is required`)
+		return
+	}
+	req.DriverParams = s.withDefaults(req.Type, req.DriverParams)
+	if _, _, err := deletionParams(req.Type, req.DriverParams); err != nil {
+		writeAsJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, span := tracing.Tracer().Start(r.Context(), "importAWSResource", trace.WithAttributes(
+		attribute.String("resource.id", req.ID),
+		attribute.String("resource.type", req.Type),
+	))
+	defer span.End()
+	ctx = logging.NewContext(ctx, logging.FromContext(ctx).WithField("resource_id", req.ID))
+	l := logging.FromContext(ctx)
+
+	awsCreds, err := AccountMapToAWSCredentials(req.DriverSecrets["account"])
+	if err != nil {
+		l.WithError(err).Error("Reading account")
+		writeAsJSON(w, http.StatusBadRequest, fmt.Sprintf(`"account" property in driver_secrets: %v`, err))
+		return
+	}
+
+	if !s.lockResource(ctx, w, req.ID) {
+		return
+	}
+	defer s.unlockResource(ctx, req.ID)
+
+	metadata, metadataExists, err := s.Model.SelectResourceMetadata(ctx, req.ID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if metadataExists && !metadata.IsDeleted() {
+		writeAsJSON(w, http.StatusConflict, fmt.Sprintf("Resource is already managed by the driver: %s", req.ID))
+		return
+	}
+
+	var data messages.ValuesSecrets
+	var params, settings map[string]interface{}
+	switch req.Type {
+	case "s3":
+		data, params, settings, err = s.importS3Bucket(ctx, req, awsCreds)
+	case "redis":
+		data, params, settings, err = s.importRedis(ctx, req, awsCreds)
+	default:
+		l.Errorf(`Type "%s" not supported by this driver.`, req.Type)
+		writeAsJSON(w, http.StatusBadRequest, fmt.Sprintf(`Type "%s" not supported by this driver.`, req.Type))
+		return
+	}
+	var perr *invalidParamsError
+	switch {
+	case errors.As(err, &perr):
+		writeAsJSON(w, http.StatusBadRequest, perr.Error())
+		return
+	case awsErrorCode(err) == s3.ErrCodeNoSuchBucket || awsErrorCode(err) == elasticache.ErrCodeCacheClusterNotFoundFault:
+		writeAsJSON(w, http.StatusNotFound, fmt.Sprintf(`No %s resource "%s" found in AWS.`, req.Type, req.Identifier))
+		return
+	case errors.Is(err, aws.ErrNotOwned):
+		writeAsJSON(w, http.StatusConflict, fmt.Sprintf(`The %s resource "%s" is managed by the driver as another resource.`, req.Type, req.Identifier))
+		return
+	case err != nil:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		l.WithError(err).Errorf(`Importing %s "%s" failed`, req.Type, req.Identifier)
+		writeAsJSON(w, http.StatusBadRequest, fmt.Sprintf(`Error importing %s "%s": %v`, req.Type, req.Identifier, err))
+		return
+	}
+	settings["imported"] = true
+
+	now := time.Now().UTC()
+	metadata = model.ResourceMetadata{
+		ID:        req.ID,
+		Type:      req.Type,
+		Status:    model.StatusReady,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Params:    params,
+		Data:      data.Values,
+		Settings:  settings,
+	}
+	if err := s.Model.InsertOrUpdateResourceMetadata(withoutCancel(ctx), metadata); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	l.Infof(`Imported %s "%s".`, req.Type, req.Identifier)
+	writeAsJSON(w, http.StatusOK, messages.ResourceData{
+		Type:       metadata.Type,
+		Data:       data,
+		DriverType: "aws",
+	})
+}
+
+// importS3Bucket looks up an existing bucket and tags it with aws.OwnerTag. The driver_params returned for it hold the
+// bucket's actual region, and the settings its configuration as it was found.
+func (s *Server) importS3Bucket(ctx context.Context, req messages.ImportRequest, awsCreds AWSCredentials) (messages.ValuesSecrets, map[string]interface{}, map[string]interface{}, error) {
+	region, err := stringParam(req.DriverParams, "region", "")
+	if err != nil {
+		return messages.ValuesSecrets{}, nil, nil, err
+	}
+	clientRegion := region
+	if clientRegion == "" {
+		clientRegion = lookupRegion
+	}
+	client, err := s.NewAwsClient(awsCreds.AccessKeyID, awsCreds.SecretAccessKey, clientRegion, s.Wait)
+	if err != nil {
+		return messages.ValuesSecrets{}, nil, nil, err
+	}
+
+	bucketRegion, err := client.BucketRegion(ctx, req.Identifier)
+	if err != nil {
+		return messages.ValuesSecrets{}, nil, nil, err
+	}
+	if region != "" && region != bucketRegion {
+		return messages.ValuesSecrets{}, nil, nil, invalidParams(`"region" property in driver_params: bucket "%s" is in "%s", not "%s"`,
+			req.Identifier, bucketRegion, region)
+	}
+
+	// The configuration of a bucket can only be read in its region.
+	if bucketRegion != clientRegion {
+		if client, err = s.NewAwsClient(awsCreds.AccessKeyID, awsCreds.SecretAccessKey, bucketRegion, s.Wait); err != nil {
+			return messages.ValuesSecrets{}, nil, nil, err
+		}
+	}
+	bucket, err := client.DescribeBucket(ctx, req.Identifier)
+	if err != nil {
+		return messages.ValuesSecrets{}, nil, nil, err
+	}
+	if aws.ManagedByOther(bucket.Tags, req.ID) {
+		return messages.ValuesSecrets{}, nil, nil, fmt.Errorf(`s3 bucket "%s" %w`, req.Identifier, aws.ErrNotOwned)
+	}
+	if len(bucket.Unsupported) > 0 {
+		return messages.ValuesSecrets{}, nil, nil, invalidParams(`bucket "%s" has configuration the driver cannot manage: %s`,
+			req.Identifier, strings.Join(bucket.Unsupported, ", "))
+	}
+	settings, err := importedBucketSettings(bucket)
+	if err != nil {
+		return messages.ValuesSecrets{}, nil, nil, err
+	}
+	if err := client.TagBucket(ctx, req.Identifier, map[string]string{aws.OwnerTag: req.ID}); err != nil {
+		return messages.ValuesSecrets{}, nil, nil, err
+	}
+
+	params := copyParams(req.DriverParams)
+	params["region"] = bucketRegion
+	values := map[string]interface{}{
+		"region": bucketRegion,
+		"bucket": req.Identifier,
+	}
+	if bucket.Config.Website != nil {
+		values["website_endpoint"] = aws.WebsiteEndpoint(req.Identifier, bucketRegion)
+	}
+	return messages.ValuesSecrets{
+		Values: values,
+		Secrets: map[string]interface{}{
+			"aws_access_key_id":     awsCreds.AccessKeyID,
+			"aws_secret_access_key": awsCreds.SecretAccessKey,
+		},
+	}, params, settings, nil
+}
+
+// importRedis looks up an existing cluster, which must be an available Redis cluster, and tags it with aws.OwnerTag.
+// The driver_params returned for it hold the cluster's node type and availability zone.
+func (s *Server) importRedis(ctx context.Context, req messages.ImportRequest, awsCreds AWSCredentials) (messages.ValuesSecrets, map[string]interface{}, map[string]interface{}, error) {
+	region, err := stringParam(req.DriverParams, "region", "")
+	if err != nil {
+		return messages.ValuesSecrets{}, nil, nil, err
+	}
+	if region == "" {
+		return messages.ValuesSecrets{}, nil, nil, invalidParams(`"region" property in driver_params is required to import a cluster`)
+	}
+	client, err := s.NewAwsClient(awsCreds.AccessKeyID, awsCreds.SecretAccessKey, region, s.Wait)
+	if err != nil {
+		return messages.ValuesSecrets{}, nil, nil, err
+	}
+
+	cluster, err := client.DescribeElastiCacheRedis(ctx, req.Identifier)
+	if err != nil {
+		return messages.ValuesSecrets{}, nil, nil, err
+	}
+	if aws.ManagedByOther(cluster.Tags, req.ID) {
+		return messages.ValuesSecrets{}, nil, nil, fmt.Errorf(`Elasticache cluster "%s" %w`, req.Identifier, aws.ErrNotOwned)
+	}
+	if cluster.Engine != "redis" {
+		return messages.ValuesSecrets{}, nil, nil, invalidParams(`cluster "%s" runs %s, not redis`, req.Identifier, cluster.Engine)
+	}
+	if cluster.Status != "available" || cluster.Host == "" {
+		return messages.ValuesSecrets{}, nil, nil, invalidParams(`cluster "%s" is %s. Only available clusters can be imported.`, req.Identifier, cluster.Status)
+	}
+	if err := client.TagElastiCacheRedis(ctx, req.Identifier, map[string]string{aws.OwnerTag: req.ID}); err != nil {
+		return messages.ValuesSecrets{}, nil, nil, err
+	}
+
+	params := copyParams(req.DriverParams)
+	params["cache_node_type"] = cluster.CacheNodeType
+	params["cache_az"] = cluster.AvailabilityZone
+	return messages.ValuesSecrets{
+		Values: map[string]interface{}{
+			"host": cluster.Host,
+			"port": cluster.Port,
+		},
+		Secrets: map[string]interface{}{},
+	}, params, map[string]interface{}{}, nil
+}
+
+func copyParams(params map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,458 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"humanitec.io/resources/driver-aws-external/internal/aws"
+	"humanitec.io/resources/driver-aws-external/internal/aws/mock_aws"
+	"humanitec.io/resources/driver-aws-external/internal/messages"
+	"humanitec.io/resources/driver-aws-external/internal/model"
+	"humanitec.io/resources/driver-aws-external/internal/model/mock_model"
+
+	"github.com/golang/mock/gomock"
+	"github.com/matryer/is"
+)
+
+func newImportRequest(resType, identifier string, driverParams map[string]interface{}) messages.ImportRequest {
+	return messages.ImportRequest{
+		ID:           "test-import-id",
+		Type:         resType,
+		Identifier:   identifier,
+		DriverParams: driverParams,
+		DriverSecrets: map[string]interface{}{
+			"account": map[string]interface{}{
+				"aws_access_key_id":     "AWS_ACCESS_KEY_ID-value",
+				"aws_secret_access_key": "AWS_SECRET_ACCESS_KEY-value",
+			},
+		},
+	}
+}
+
+func expectImportLock(m *mock_model.MockModeler, existing model.ResourceMetadata, exists bool) {
+	m.EXPECT().LockResource(gomock.Any(), "test-import-id").Return(true, nil).Times(1)
+	m.EXPECT().UnlockResource(gomock.Any(), "test-import-id").Return(nil).Times(1)
+	m.EXPECT().SelectResourceMetadata(gomock.Any(), "test-import-id").Return(existing, exists, nil).Times(1)
+}
+
+func TestImportAWSResource_S3(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	a := mock_aws.NewMockClient(ctrl)
+	var regions []string
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			regions = append(regions, reg)
+			return a, nil
+		},
+	}
+
+	expectImportLock(m, model.ResourceMetadata{}, false)
+	a.EXPECT().BucketRegion(gomock.Any(), "existing-bucket").Return("eu-west-1", nil).Times(1)
+	a.
+		EXPECT().
+		DescribeBucket(gomock.Any(), "existing-bucket").
+		Return(aws.Bucket{
+			Config: aws.BucketConfig{
+				Versioning:     s3.BucketVersioningStatusEnabled,
+				LifecycleRules: []aws.LifecycleRule{{ID: "expire-tmp", Prefix: "tmp/", ExpirationDays: 7}},
+				CORSRules:      []aws.CORSRule{{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET"}}},
+				Website:        &aws.BucketWebsite{IndexDocument: "index.html"},
+			},
+			SSEAlgorithm: s3.ServerSideEncryptionAwsKms,
+			KMSKeyID:     "key-1",
+		}, nil).
+		Times(1)
+	// The bucket is untagged, so it is tagged with the resource ID.
+	a.EXPECT().TagBucket(gomock.Any(), "existing-bucket", map[string]string{aws.OwnerTag: "test-import-id"}).Return(nil).Times(1)
+	m.
+		EXPECT().
+		InsertOrUpdateResourceMetadata(gomock.Any(), IgnoreDateResourceMetadata(model.ResourceMetadata{
+			ID:     "test-import-id",
+			Type:   "s3",
+			Status: model.StatusReady,
+			Params: map[string]interface{}{"region": "eu-west-1"},
+			Data: map[string]interface{}{
+				"region":           "eu-west-1",
+				"bucket":           "existing-bucket",
+				"website_endpoint": "http://existing-bucket.s3-website-eu-west-1.amazonaws.com",
+			},
+			Settings: map[string]interface{}{
+				"imported":   true,
+				"versioning": "Enabled",
+				"lifecycle_rules": []interface{}{
+					map[string]interface{}{"id": "expire-tmp", "prefix": "tmp/", "enabled": true, "expiration_days": float64(7)},
+				},
+				"cors_rules": []interface{}{
+					map[string]interface{}{"allowed_origins": []interface{}{"*"}, "allowed_methods": []interface{}{"GET"}},
+				},
+				"website":    map[string]interface{}{"index_document": "index.html"},
+				"encryption": "sse-kms",
+				"kms_key_id": "key-1",
+			},
+		})).
+		Return(nil).
+		Times(1)
+
+	res := ExecuteRequest(s, http.MethodPost, "/import", newImportRequest("s3", "existing-bucket", map[string]interface{}{}), t)
+
+	is.Equal(res.Code, http.StatusOK)
+	var data messages.ResourceData
+	is.NoErr(json.Unmarshal(res.Body.Bytes(), &data))
+	is.Equal(data.Type, "s3")
+	is.Equal(data.DriverType, "aws")
+	is.Equal(data.Data.Values["bucket"], "existing-bucket")
+	is.Equal(data.Data.Values["region"], "eu-west-1")
+	is.Equal(data.Data.Secrets["aws_access_key_id"], "AWS_ACCESS_KEY_ID-value")
+	is.Equal(regions, []string{lookupRegion, "eu-west-1"}) // no region given, so the bucket is looked up from the default one
+}
+
+func TestImportAWSResource_S3Unsupported(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			return a, nil
+		},
+	}
+
+	expectImportLock(m, model.ResourceMetadata{}, false)
+	a.EXPECT().BucketRegion(gomock.Any(), "existing-bucket").Return("eu-west-1", nil).Times(1)
+	a.
+		EXPECT().
+		DescribeBucket(gomock.Any(), "existing-bucket").
+		Return(aws.Bucket{Unsupported: []string{`lifecycle rule "by-tag": filters other than a prefix`}}, nil).
+		Times(1)
+
+	req := newImportRequest("s3", "existing-bucket", map[string]interface{}{"region": "eu-west-1"})
+	res := ExecuteRequest(s, http.MethodPost, "/import", req, t)
+
+	is.Equal(res.Code, http.StatusBadRequest) // rather than losing the rule on the next update
+}
+
+func TestImportAWSResource_S3Update(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			return a, nil
+		},
+	}
+	lifecycleRules := []aws.LifecycleRule{{ID: "expire-tmp", Prefix: "tmp/", ExpirationDays: 7}}
+
+	var recorded model.ResourceMetadata
+	expectImportLock(m, model.ResourceMetadata{}, false)
+	a.EXPECT().BucketRegion(gomock.Any(), "existing-bucket").Return("eu-west-1", nil).Times(1)
+	a.
+		EXPECT().
+		DescribeBucket(gomock.Any(), "existing-bucket").
+		Return(aws.Bucket{Config: aws.BucketConfig{LifecycleRules: lifecycleRules}}, nil).
+		Times(1)
+	a.EXPECT().TagBucket(gomock.Any(), "existing-bucket", gomock.Any()).Return(nil).Times(1)
+	m.
+		EXPECT().
+		InsertOrUpdateResourceMetadata(gomock.Any(), gomock.Any()).
+		Do(func(ctx context.Context, metadata model.ResourceMetadata) { recorded = metadata }).
+		Return(nil).
+		Times(2)
+
+	req := newImportRequest("s3", "existing-bucket", map[string]interface{}{"region": "eu-west-1"})
+	res := ExecuteRequest(s, http.MethodPost, "/import", req, t)
+	is.Equal(res.Code, http.StatusOK)
+
+	// The resource is then updated with CORS rules, but no lifecycle rules.
+	m.EXPECT().LockResource(gomock.Any(), "test-import-id").Return(true, nil).Times(1)
+	m.EXPECT().UnlockResource(gomock.Any(), "test-import-id").Return(nil).Times(1)
+	m.EXPECT().SelectResourceMetadata(gomock.Any(), "test-import-id").Return(recorded, true, nil).Times(1)
+	a.
+		EXPECT().
+		ConfigureBucket(gomock.Any(), "existing-bucket", aws.BucketConfig{
+			LifecycleRules: lifecycleRules,
+			CORSRules:      []aws.CORSRule{{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET"}}},
+		}).
+		Return(nil).
+		Times(1)
+
+	drd := messages.DriverResourceDefinition{
+		ID:            "test-import-id",
+		Type:          "s3",
+		DriverParams:  map[string]interface{}{"region": "eu-west-1"},
+		DriverSecrets: req.DriverSecrets,
+		ResourceParams: map[string]interface{}{
+			"cors_rules": []interface{}{map[string]interface{}{"allowed_origins": []interface{}{"*"}, "allowed_methods": []interface{}{"GET"}}},
+		},
+	}
+	res = ExecuteRequest(s, http.MethodPost, "/", drd, t)
+
+	is.Equal(res.Code, http.StatusOK)
+	is.Equal(len(recorded.Settings["lifecycle_rules"].([]interface{})), 1) // the imported lifecycle rules are kept
+	is.Equal(recorded.Settings["imported"], true)
+}
+
+func TestImportAWSResource_S3WrongRegion(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			return a, nil
+		},
+	}
+
+	expectImportLock(m, model.ResourceMetadata{}, false)
+	a.EXPECT().BucketRegion(gomock.Any(), "existing-bucket").Return("us-east-2", nil).Times(1)
+
+	req := newImportRequest("s3", "existing-bucket", map[string]interface{}{"region": "eu-west-1"})
+	res := ExecuteRequest(s, http.MethodPost, "/import", req, t)
+
+	is.Equal(res.Code, http.StatusBadRequest)
+}
+
+func TestImportAWSResource_Redis(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			is.Equal(reg, "eu-west-1")
+			return a, nil
+		},
+	}
+
+	expectImportLock(m, model.ResourceMetadata{}, false)
+	a.
+		EXPECT().
+		DescribeElastiCacheRedis(gomock.Any(), "existing-cluster").
+		Return(aws.CacheCluster{
+			ID:               "existing-cluster",
+			Status:           "available",
+			Engine:           "redis",
+			CacheNodeType:    "cache.t3.small",
+			AvailabilityZone: "eu-west-1b",
+			Host:             "existing-cluster.abc123.0001.euw1.cache.amazonaws.com",
+			Port:             6379,
+			Tags:             map[string]string{"team": "platform"},
+		}, nil).
+		Times(1)
+	a.EXPECT().TagElastiCacheRedis(gomock.Any(), "existing-cluster", map[string]string{aws.OwnerTag: "test-import-id"}).Return(nil).Times(1)
+	m.
+		EXPECT().
+		InsertOrUpdateResourceMetadata(gomock.Any(), IgnoreDateResourceMetadata(model.ResourceMetadata{
+			ID:     "test-import-id",
+			Type:   "redis",
+			Status: model.StatusReady,
+			Params: map[string]interface{}{
+				"region":          "eu-west-1",
+				"cache_node_type": "cache.t3.small",
+				"cache_az":        "eu-west-1b",
+			},
+			Data: map[string]interface{}{
+				"host": "existing-cluster.abc123.0001.euw1.cache.amazonaws.com",
+				"port": int64(6379),
+			},
+			Settings: map[string]interface{}{"imported": true},
+		})).
+		Return(nil).
+		Times(1)
+
+	req := newImportRequest("redis", "existing-cluster", map[string]interface{}{"region": "eu-west-1"})
+	res := ExecuteRequest(s, http.MethodPost, "/import", req, t)
+
+	is.Equal(res.Code, http.StatusOK)
+	var data messages.ResourceData
+	is.NoErr(json.Unmarshal(res.Body.Bytes(), &data))
+	is.Equal(data.Data.Values["host"], "existing-cluster.abc123.0001.euw1.cache.amazonaws.com")
+	is.Equal(data.Data.Values["port"], float64(6379))
+}
+
+func TestImportAWSResource_RedisUnavailable(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			return a, nil
+		},
+	}
+
+	expectImportLock(m, model.ResourceMetadata{}, false)
+	a.
+		EXPECT().
+		DescribeElastiCacheRedis(gomock.Any(), "existing-cluster").
+		Return(aws.CacheCluster{ID: "existing-cluster", Status: "modifying", Engine: "redis"}, nil).
+		Times(1)
+
+	req := newImportRequest("redis", "existing-cluster", map[string]interface{}{"region": "eu-west-1"})
+	res := ExecuteRequest(s, http.MethodPost, "/import", req, t)
+
+	is.Equal(res.Code, http.StatusBadRequest)
+}
+
+func TestImportAWSResource_ManagedByOther(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			return a, nil
+		},
+	}
+	foreign := map[string]string{aws.OwnerTag: "other-resource"}
+
+	// Neither is tagged nor recorded.
+	expectImportLock(m, model.ResourceMetadata{}, false)
+	expectImportLock(m, model.ResourceMetadata{}, false)
+	a.EXPECT().BucketRegion(gomock.Any(), "existing-bucket").Return("eu-west-1", nil).Times(1)
+	a.EXPECT().DescribeBucket(gomock.Any(), "existing-bucket").Return(aws.Bucket{Tags: foreign}, nil).Times(1)
+	a.
+		EXPECT().
+		DescribeElastiCacheRedis(gomock.Any(), "existing-cluster").
+		Return(aws.CacheCluster{ID: "existing-cluster", Status: "available", Engine: "redis", Host: "existing-cluster.cache", Tags: foreign}, nil).
+		Times(1)
+
+	req := newImportRequest("s3", "existing-bucket", map[string]interface{}{"region": "eu-west-1"})
+	res := ExecuteRequest(s, http.MethodPost, "/import", req, t)
+	is.Equal(res.Code, http.StatusConflict)
+
+	req = newImportRequest("redis", "existing-cluster", map[string]interface{}{"region": "eu-west-1"})
+	res = ExecuteRequest(s, http.MethodPost, "/import", req, t)
+	is.Equal(res.Code, http.StatusConflict)
+}
+
+func TestImportAWSResource_Retained(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			return a, nil
+		},
+	}
+
+	// Buckets retained when their resource was deleted can be imported as another one.
+	expectImportLock(m, model.ResourceMetadata{}, false)
+	a.EXPECT().BucketRegion(gomock.Any(), "existing-bucket").Return("eu-west-1", nil).Times(1)
+	a.
+		EXPECT().
+		DescribeBucket(gomock.Any(), "existing-bucket").
+		Return(aws.Bucket{Tags: map[string]string{aws.OwnerTag: "other-resource", aws.RetainedTag: "other-resource"}}, nil).
+		Times(1)
+	a.EXPECT().TagBucket(gomock.Any(), "existing-bucket", map[string]string{aws.OwnerTag: "test-import-id"}).Return(nil).Times(1)
+	m.EXPECT().InsertOrUpdateResourceMetadata(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+	req := newImportRequest("s3", "existing-bucket", map[string]interface{}{"region": "eu-west-1"})
+	res := ExecuteRequest(s, http.MethodPost, "/import", req, t)
+	is.Equal(res.Code, http.StatusOK)
+}
+
+func TestImportAWSResource_NotFound(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			return a, nil
+		},
+	}
+
+	expectImportLock(m, model.ResourceMetadata{}, false)
+	expectImportLock(m, model.ResourceMetadata{}, false)
+	a.
+		EXPECT().
+		BucketRegion(gomock.Any(), "missing-bucket").
+		Return("", awserr.New(s3.ErrCodeNoSuchBucket, "The specified bucket does not exist", nil)).
+		Times(1)
+	a.
+		EXPECT().
+		DescribeElastiCacheRedis(gomock.Any(), "missing-cluster").
+		Return(aws.CacheCluster{}, awserr.New(elasticache.ErrCodeCacheClusterNotFoundFault, "CacheCluster not found", nil)).
+		Times(1)
+
+	res := ExecuteRequest(s, http.MethodPost, "/import", newImportRequest("s3", "missing-bucket", map[string]interface{}{}), t)
+	is.Equal(res.Code, http.StatusNotFound)
+
+	req := newImportRequest("redis", "missing-cluster", map[string]interface{}{"region": "eu-west-1"})
+	res = ExecuteRequest(s, http.MethodPost, "/import", req, t)
+	is.Equal(res.Code, http.StatusNotFound)
+}
+
+func TestImportAWSResource_AlreadyManaged(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	s := Server{
+		Model: m,
+	}
+
+	expectImportLock(m, model.ResourceMetadata{
+		ID:     "test-import-id",
+		Type:   "s3",
+		Status: model.StatusReady,
+		Params: map[string]interface{}{"region": "eu-west-1"},
+		Data:   map[string]interface{}{"region": "eu-west-1", "bucket": "existing-bucket"},
+	}, true)
+
+	res := ExecuteRequest(s, http.MethodPost, "/import", newImportRequest("s3", "existing-bucket", map[string]interface{}{}), t)
+
+	is.Equal(res.Code, http.StatusConflict)
+}
+
+func TestImportAWSResource_Invalid(t *testing.T) {
+	s := Server{}
+	for name, req := range map[string]messages.ImportRequest{
+		"invalid id":         {ID: "Not_An_ID", Type: "s3", Identifier: "existing-bucket"},
+		"missing identifier": newImportRequest("s3", "", map[string]interface{}{}),
+		"missing account":    {ID: "test-import-id", Type: "s3", Identifier: "existing-bucket"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			res := ExecuteRequest(s, http.MethodPost, "/import", req, t)
+			is.Equal(res.Code, http.StatusBadRequest)
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"humanitec.io/resources/driver-aws-external/internal/logging"
+)
+
+// lockResource takes the lock for the resource with the supplied id so that no other request can modify it at the
+// same time. If the lock cannot be taken, an appropriate response is written and false is returned.
+func (s *Server) lockResource(ctx context.Context, w http.ResponseWriter, id string) bool {
+	locked, err := s.Model.LockResource(ctx, id)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return false
+	}
+	if !locked {
+		logging.FromContext(ctx).Warn("Resource is locked by another request.")
+		writeAsJSON(w, http.StatusConflict, fmt.Sprintf("Resource is being modified by another request: %s", id))
+		return false
+	}
+	return true
+}
+
+// unlockResource releases a lock taken with lockResource.
+func (s *Server) unlockResource(ctx context.Context, id string) {
+	if err := s.Model.UnlockResource(ctx, id); err != nil {
+		logging.FromContext(ctx).WithError(err).Error("Unable to unlock resource")
+	}
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"humanitec.io/resources/driver-aws-external/internal/aws"
+	"humanitec.io/resources/driver-aws-external/internal/aws/mock_aws"
+	"humanitec.io/resources/driver-aws-external/internal/messages"
+	"humanitec.io/resources/driver-aws-external/internal/model"
+	"humanitec.io/resources/driver-aws-external/internal/model/mock_model"
+
+	"github.com/golang/mock/gomock"
+	"github.com/matryer/is"
+)
+
+func TestCreateAWSResource_ConcurrentCreate(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	accessKeyId := "AWS_ACCESS_KEY_ID-value"
+	secretAccessKey := "AWS_SECRET_ACCESS_KEY-value"
+	region := "eu-west-1"
+
+	m := mock_model.NewMockModeler(ctrl)
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			return a, nil
+		},
+	}
+	resourceID := "test-db-id"
+	drd := messages.DriverResourceDefinition{
+		ID:             resourceID,
+		Type:           "s3",
+		ResourceParams: map[string]interface{}{},
+		DriverParams: map[string]interface{}{
+			"region": region,
+		},
+		DriverSecrets: map[string]interface{}{
+			"account": map[string]interface{}{
+				"aws_access_key_id":     accessKeyId,
+				"aws_secret_access_key": secretAccessKey,
+			},
+		},
+	}
+
+	// The first request takes the lock, the second one arrives while the bucket is being created.
+	gomock.InOrder(
+		m.
+			EXPECT().
+			LockResource(gomock.Any(), resourceID).
+			Return(true, nil),
+		m.
+			EXPECT().
+			LockResource(gomock.Any(), resourceID).
+			Return(false, nil),
+	)
+	m.
+		EXPECT().
+		SelectResourceMetadata(gomock.Any(), resourceID).
+		Return(model.ResourceMetadata{}, false, nil).
+		Times(1)
+
+	var concurrentCode int
+	a.
+		EXPECT().
+		CreateBucket(gomock.Any(), gomock.AssignableToTypeOf(""), drd.ID).
+		Do(func(ctx, bn, resourceID interface{}) {
+			concurrentCode = ExecuteRequest(s, http.MethodPost, "/", drd, t).Code
+		}).
+		Return(region, nil).
+		Times(1)
+	a.
+		EXPECT().
+		SecureBucket(gomock.Any(), gomock.AssignableToTypeOf(""), gomock.Any()).
+		Return(nil).
+		Times(1)
+
+	m.
+		EXPECT().
+		InsertOrUpdateResourceMetadata(gomock.Any(), gomock.Any()).
+		Return(nil).
+		Times(1)
+	m.
+		EXPECT().
+		UnlockResource(gomock.Any(), resourceID).
+		Return(nil).
+		Times(1)
+
+	res := ExecuteRequest(s, http.MethodPost, "/", drd, t)
+
+	is.Equal(res.Code, http.StatusOK)
+	is.Equal(concurrentCode, http.StatusConflict)
+}
+
+func TestCreateAWSResource_LockError(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	s := Server{
+		Model: m,
+	}
+	resourceID := "test-db-id"
+	drd := messages.DriverResourceDefinition{
+		ID:   resourceID,
+		Type: "s3",
+	}
+
+	m.
+		EXPECT().
+		LockResource(gomock.Any(), resourceID).
+		Return(false, errors.New("connection refused")).
+		Times(1)
+
+	res := ExecuteRequest(s, http.MethodPost, "/", drd, t)
+
+	is.Equal(res.Code, http.StatusInternalServerError)
+}
+
+func TestDeleteAWSResource_Locked(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	s := Server{
+		Model: m,
+	}
+	resourceID := "test-db-id"
+	params := map[string]interface{}{
+		"region": "eu-west-1",
+	}
+	account := AWSCredentials{
+		AccessKeyID:     "AWS_ACCESS_KEY_ID-value",
+		SecretAccessKey: "AWS_SECRET_ACCESS_KEY-value",
+	}
+
+	m.
+		EXPECT().
+		LockResource(gomock.Any(), resourceID).
+		Return(false, nil).
+		Times(1)
+
+	header := http.Header{}
+	jsonSecrets, _ := json.Marshal(map[string]interface{}{"account": account})
+	header.Add("Humanitec-Driver-Secrets", base64.StdEncoding.EncodeToString(jsonSecrets))
+	jsonParams, _ := json.Marshal(params)
+	header.Add("Humanitec-Driver-Params", base64.StdEncoding.EncodeToString(jsonParams))
+
+	res := ExecuteRequestHeader(s, http.MethodDelete, "/"+resourceID, nil, header, t)
+
+	is.Equal(res.Code, http.StatusConflict)
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"humanitec.io/resources/driver-aws-external/internal/logging"
+)
+
+// validRequestID restricts the request IDs accepted from clients so that arbitrary data cannot be injected into logs.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// requestLogging is middleware that assigns each request an ID and makes a logger carrying it available via
+// logging.FromContext. An ID supplied by the caller in the X-Request-ID header is reused, otherwise a new one is
+// generated. Either way it is returned in the response header and a line is logged once the request completes.
+func requestLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(logging.RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+		w.Header().Set(logging.RequestIDHeader, requestID)
+
+		l := logging.FromContext(r.Context()).WithFields(map[string]interface{}{
+			"request_id": requestID,
+			"method":     r.Method,
+			"path":       r.URL.Path,
+		})
+		// Allow log lines and traces to be correlated with each other.
+		if span := trace.SpanFromContext(r.Context()); span.SpanContext().IsValid() {
+			span.SetAttributes(attribute.String("request_id", requestID))
+			l = l.WithField("trace_id", span.SpanContext().TraceID().String())
+		}
+
+		rec := &statusRecorder{ResponseWriter: w}
+		start := time.Now()
+		next.ServeHTTP(rec, r.WithContext(logging.NewContext(r.Context(), l)))
+		if rec.statusCode == 0 {
+			rec.statusCode = http.StatusOK
+		}
+
+		l.WithFields(map[string]interface{}{
+			"status":      rec.statusCode,
+			"duration_ms": time.Since(start).Milliseconds(),
+		}).Info("Request completed")
+	})
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/matryer/is"
+	"humanitec.io/resources/driver-aws-external/internal/logging"
+)
+
+func TestRequestLogging_HonoursRequestID(t *testing.T) {
+	is := is.New(t)
+	var loggedID interface{}
+	handler := requestLogging(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		loggedID = logging.FromContext(r.Context()).Data["request_id"]
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/alive", nil)
+	req.Header.Set(logging.RequestIDHeader, "abc-123")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	is.Equal(rr.Header().Get(logging.RequestIDHeader), "abc-123")
+	is.Equal(loggedID, "abc-123")
+}
+
+func TestRequestLogging_GeneratesRequestID(t *testing.T) {
+	for name, header := range map[string]string{
+		"Missing": "",
+		"Invalid": "not\nvalid",
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			var loggedID interface{}
+			handler := requestLogging(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				loggedID = logging.FromContext(r.Context()).Data["request_id"]
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/alive", nil)
+			if header != "" {
+				req.Header.Set(logging.RequestIDHeader, header)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			requestID := rr.Header().Get(logging.RequestIDHeader)
+			is.True(requestID != "")
+			is.True(requestID != header)
+			is.Equal(loggedID, requestID)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"humanitec.io/resources/driver-aws-external/internal/metrics"
+)
+
+func TestInstrumentRoutes(t *testing.T) {
+	is := is.New(t)
+	s := Server{}
+	requests := metrics.HTTPRequests.WithLabelValues(http.MethodGet, "/alive", "200")
+	before := testutil.ToFloat64(requests)
+
+	res := ExecuteRequest(s, http.MethodGet, "/alive", nil, t)
+
+	is.Equal(res.Code, http.StatusOK)
+	is.Equal(testutil.ToFloat64(requests), before+1)
+}
+
+func TestInstrumentRoutes_RouteTemplate(t *testing.T) {
+	is := is.New(t)
+	s := Server{}
+	requests := metrics.HTTPRequests.WithLabelValues(http.MethodDelete, "/{resourceId}", "404")
+	before := testutil.ToFloat64(requests)
+
+	res := ExecuteRequest(s, http.MethodDelete, "/Not-A-Valid-ID", nil, t)
+
+	is.Equal(res.Code, http.StatusNotFound)
+	is.Equal(testutil.ToFloat64(requests), before+1) // labelled with the template, not the resource ID
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	is := is.New(t)
+	s := Server{}
+	ExecuteRequest(s, http.MethodGet, "/alive", nil, t)
+
+	res := ExecuteRequest(s, http.MethodGet, "/metrics", nil, t)
+
+	is.Equal(res.Code, http.StatusOK)
+	is.True(strings.Contains(res.Body.String(), `driver_http_requests_total{method="GET",route="/alive",status="200"}`))
+}
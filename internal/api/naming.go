@@ -0,0 +1,78 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+
+	"humanitec.io/resources/driver-aws-external/internal/config"
+	"humanitec.io/resources/driver-aws-external/internal/messages"
+)
+
+// nameRules are the constraints AWS places on a kind of name.
+type nameRules struct {
+	maxLength int
+	// startWithLetter requires names to start with a letter rather than with a letter or digit.
+	startWithLetter bool
+}
+
+var (
+	// bucketNameRules follow S3's rules for bucket names that are valid DNS labels. Dots are allowed by S3 but break
+	// TLS for virtual-hosted-style requests, so they are not used.
+	bucketNameRules = nameRules{maxLength: 63}
+	// clusterIDRules follow ElastiCache's rules for cluster ids, which also forbid consecutive hyphens.
+	clusterIDRules = nameRules{maxLength: 40, startWithLetter: true}
+)
+
+// shortHashLength is the number of hex digits of the hash of the resource id substituted for {short-hash}. 64 bits keep
+// collisions between the names of different resources unlikely even for many resources.
+const shortHashLength = 16
+
+var (
+	namePlaceholder  = regexp.MustCompile(`\{([a-z0-9_-]+)\}`)
+	nameInvalidChars = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// physicalName renders the naming template for the resource, so that the same resource always gets the same name.
+// The placeholders are {prefix}, {id}, {type}, {short-hash} and the names of string resource_params, e.g. {app}.
+// Unknown placeholders are left empty. The result is lower-cased, any other characters not allowed by rules are replaced
+// by hyphens, and names that are too long are truncated, keeping the hash at the end so that they stay unique.
+func (s *Server) physicalName(drd messages.DriverResourceDefinition, rules nameRules) string {
+	template := s.Naming.Template
+	if template == "" {
+		template = config.DefaultNamingTemplate
+	}
+	sum := sha256.Sum256([]byte(drd.ID))
+	hash := hex.EncodeToString(sum[:])[:shortHashLength]
+
+	name := namePlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		switch key := placeholder[1 : len(placeholder)-1]; key {
+		case "prefix":
+			return s.Naming.Prefix
+		case "id":
+			return drd.ID
+		case "type":
+			return drd.Type
+		case "short-hash":
+			return hash
+		default:
+			value, _ := drd.ResourceParams[key].(string)
+			return value
+		}
+	})
+	name = sanitizeName(name)
+	if rules.startWithLetter && (name == "" || name[0] < 'a' || name[0] > 'z') {
+		name = sanitizeName(drd.Type + "-" + name)
+	}
+	if len(name) > rules.maxLength {
+		name = strings.TrimRight(name[:rules.maxLength-len(hash)-1], "-") + "-" + hash
+	}
+	return name
+}
+
+// sanitizeName lower-cases name and replaces runs of anything other than letters and digits with a single hyphen.
+func sanitizeName(name string) string {
+	name = nameInvalidChars.ReplaceAllString(strings.ToLower(name), "-")
+	return strings.Trim(name, "-")
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+	"humanitec.io/resources/driver-aws-external/internal/config"
+	"humanitec.io/resources/driver-aws-external/internal/messages"
+)
+
+func TestPhysicalName(t *testing.T) {
+	// The first 16 hex digits of the SHA-256 of "my-resource".
+	hash := "1a6d8517d24e6747"
+	drd := messages.DriverResourceDefinition{
+		ID:   "my-resource",
+		Type: "s3",
+		ResourceParams: map[string]interface{}{
+			"app":  "Shop_Frontend",
+			"env":  "staging",
+			"size": 3.0,
+		},
+	}
+	for _, tc := range []struct {
+		name     string
+		naming   config.Naming
+		rules    nameRules
+		resource messages.DriverResourceDefinition
+		expected string
+	}{
+		{
+			name:     "DefaultTemplate",
+			naming:   config.Naming{Prefix: "acme"},
+			rules:    bucketNameRules,
+			resource: drd,
+			expected: "acme-shop-frontend-staging-" + hash,
+		},
+		{
+			name:     "MissingFields",
+			naming:   config.Naming{Template: "{prefix}-{team}-{size}-{short-hash}", Prefix: "acme"},
+			rules:    bucketNameRules,
+			resource: drd,
+			expected: "acme-" + hash,
+		},
+		{
+			name:     "IDAndType",
+			naming:   config.Naming{Template: "{type}.{id}.{short-hash}"},
+			rules:    bucketNameRules,
+			resource: drd,
+			expected: "s3-my-resource-" + hash,
+		},
+		{
+			name:     "Truncated",
+			naming:   config.Naming{Template: "{prefix}-{short-hash}", Prefix: strings.Repeat("long-", 20)},
+			rules:    bucketNameRules,
+			resource: drd,
+			expected: strings.TrimRight(strings.Repeat("long-", 20)[:46], "-") + "-" + hash,
+		},
+		{
+			name:     "ClusterStartsWithLetter",
+			naming:   config.Naming{Template: "{short-hash}"},
+			rules:    clusterIDRules,
+			resource: messages.DriverResourceDefinition{ID: "my-resource", Type: "redis"},
+			expected: "redis-" + hash,
+		},
+		{
+			name:     "ClusterTruncated",
+			naming:   config.Naming{Prefix: "acme-production"},
+			rules:    clusterIDRules,
+			resource: messages.DriverResourceDefinition{ID: "my-resource", Type: "redis", ResourceParams: drd.ResourceParams},
+			expected: "acme-production-shop-fr-" + hash,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			s := Server{Naming: tc.naming}
+
+			name := s.physicalName(tc.resource, tc.rules)
+
+			is.Equal(name, tc.expected)
+			is.True(len(name) <= tc.rules.maxLength)
+			is.Equal(s.physicalName(tc.resource, tc.rules), name) // names are deterministic
+		})
+	}
+}
+
+func TestPhysicalName_Unique(t *testing.T) {
+	is := is.New(t)
+	s := Server{}
+
+	a := s.physicalName(messages.DriverResourceDefinition{ID: "resource-a", Type: "s3"}, bucketNameRules)
+	b := s.physicalName(messages.DriverResourceDefinition{ID: "resource-b", Type: "s3"}, bucketNameRules)
+
+	is.True(a != b)
+}
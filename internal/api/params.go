@@ -0,0 +1,45 @@
+package api
+
+import (
+	"fmt"
+)
+
+// invalidParamsError reports driver_params or resource_params that cannot be used. It is returned to the caller as
+// 400 Bad Request rather than as a failure of the driver.
+type invalidParamsError struct {
+	msg string
+}
+
+func (e *invalidParamsError) Error() string {
+	return e.msg
+}
+
+func invalidParams(format string, args ...interface{}) error {
+	return &invalidParamsError{fmt.Sprintf(format, args...)}
+}
+
+// stringParam returns the named string parameter, or defaultValue if it is not set.
+func stringParam(params map[string]interface{}, name, defaultValue string) (string, error) {
+	value, ok := params[name]
+	if !ok || value == nil {
+		return defaultValue, nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", invalidParams(`"%s" property in driver_params: expected string, got %T`, name, value)
+	}
+	return s, nil
+}
+
+// boolParam returns the named boolean parameter, or defaultValue if it is not set.
+func boolParam(params map[string]interface{}, name string, defaultValue bool) (bool, error) {
+	value, ok := params[name]
+	if !ok || value == nil {
+		return defaultValue, nil
+	}
+	b, ok := value.(bool)
+	if !ok {
+		return false, invalidParams(`"%s" property in driver_params: expected boolean, got %T`, name, value)
+	}
+	return b, nil
+}
@@ -0,0 +1,245 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"humanitec.io/resources/driver-aws-external/internal/logging"
+	"humanitec.io/resources/driver-aws-external/internal/model"
+	"humanitec.io/resources/driver-aws-external/internal/tracing"
+)
+
+// deletionRequest returns what is kept of a request deleting a resource, so that the deletion can be carried out, or
+// checked on, later: its driver_params and, if a credentials key is configured, its AWS credentials encrypted with the
+// key. No other driver_secrets are kept.
+func (s *Server) deletionRequest(id string, driverParams map[string]interface{}, awsCreds AWSCredentials) (map[string]interface{}, error) {
+	request := map[string]interface{}{
+		"driver_params": driverParams,
+	}
+	key, err := s.Deletion.Key()
+	if err != nil || key == nil {
+		return request, err
+	}
+	sealed, err := sealCredentials(key, id, awsCreds)
+	if err != nil {
+		return nil, err
+	}
+	request["credentials"] = sealed
+	return request, nil
+}
+
+// deletionCredentials returns the AWS credentials kept by deletionRequest. ok is false if none were kept.
+func (s *Server) deletionCredentials(metadata model.ResourceMetadata) (awsCreds AWSCredentials, ok bool, err error) {
+	sealed, _ := metadata.DeletionRequest["credentials"].(string)
+	key, err := s.Deletion.Key()
+	if err != nil || key == nil || sealed == "" {
+		return AWSCredentials{}, false, err
+	}
+	awsCreds, err = openCredentials(key, metadata.ID, sealed)
+	if err != nil {
+		return AWSCredentials{}, false, err
+	}
+	return awsCreds, true, nil
+}
+
+// markDeleting records that AWS has accepted the deletion of the cluster of a resource, along with what is needed to
+// confirm that it has been removed.
+func (s *Server) markDeleting(ctx context.Context, metadata *model.ResourceMetadata, driverParams map[string]interface{}, awsCreds AWSCredentials) error {
+	request, err := s.deletionRequest(metadata.ID, driverParams, awsCreds)
+	if err != nil {
+		return fmt.Errorf("recording deletion of cluster: %w", err)
+	}
+	now := time.Now().UTC()
+	metadata.Status = model.StatusDeleting
+	if !metadata.DeleteAfter.Valid {
+		metadata.DeleteAfter.Time = now
+		metadata.DeleteAfter.Valid = true
+	}
+	metadata.DeletionRequest = request
+	metadata.UpdatedAt = now
+	// The cluster is being deleted in AWS so that must be recorded, even if the caller has gone away.
+	if err := s.Model.InsertOrUpdateResourceMetadata(withoutCancel(ctx), *metadata); err != nil {
+		return fmt.Errorf("recording deletion of cluster: %w", err)
+	}
+	return nil
+}
+
+// scheduleDeletion marks a resource as pending deletion until the grace period has passed. What is needed to delete its
+// infrastructure then is kept with deletionRequest.
+func (s *Server) scheduleDeletion(ctx context.Context, w http.ResponseWriter, metadata model.ResourceMetadata, driverParams map[string]interface{}, awsCreds AWSCredentials) {
+	request, err := s.deletionRequest(metadata.ID, driverParams, awsCreds)
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).Error("Keeping deletion request")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	now := time.Now().UTC()
+	metadata.Status = model.StatusPendingDeletion
+	metadata.DeleteAfter.Time = now.Add(s.Deletion.GracePeriod)
+	metadata.DeleteAfter.Valid = true
+	metadata.DeletionRequest = request
+	metadata.UpdatedAt = now
+	if err := s.Model.InsertOrUpdateResourceMetadata(ctx, metadata); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	logging.FromContext(ctx).Infof("Resource will be deleted after %v.", metadata.DeleteAfter.Time)
+	writeDeletionScheduled(w, metadata)
+}
+
+// writeDeletionScheduled responds to a request to delete a resource that is pending deletion.
+func writeDeletionScheduled(w http.ResponseWriter, metadata model.ResourceMetadata) {
+	writeAsJSON(w, http.StatusAccepted, fmt.Sprintf("Resource will be deleted after %s. Until then, the deletion can be cancelled with POST /%s/cancel-deletion.",
+		metadata.DeleteAfter.Time.Format(time.RFC3339), metadata.ID))
+}
+
+// cancelDeletion restores a resource that is pending deletion.
+func (s *Server) cancelDeletion(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	if !isValidAsID(params["resourceId"]) {
+		writeAsJSON(w, http.StatusNotFound, fmt.Sprintf("Resource not found: %s", params["resourceId"]))
+		return
+	}
+
+	ctx, span := tracing.Tracer().Start(r.Context(), "cancelDeletion", trace.WithAttributes(
+		attribute.String("resource.id", params["resourceId"]),
+	))
+	defer span.End()
+	ctx = logging.NewContext(ctx, logging.FromContext(ctx).WithField("resource_id", params["resourceId"]))
+
+	if !s.lockResource(ctx, w, params["resourceId"]) {
+		return
+	}
+	defer s.unlockResource(ctx, params["resourceId"])
+
+	metadata, metadataExists, err := s.Model.SelectResourceMetadata(ctx, params["resourceId"])
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !metadataExists || metadata.IsDeleted() {
+		writeAsJSON(w, http.StatusNotFound, fmt.Sprintf("Resource not found: %s", params["resourceId"]))
+		return
+	}
+	if !metadata.IsPendingDeletion() {
+		writeAsJSON(w, http.StatusConflict, fmt.Sprintf("Resource is not pending deletion: %s", params["resourceId"]))
+		return
+	}
+
+	metadata.Status = model.StatusReady
+	metadata.DeleteAfter = sql.NullTime{}
+	metadata.DeletionRequest = map[string]interface{}{}
+	metadata.UpdatedAt = time.Now().UTC()
+	if err := s.Model.InsertOrUpdateResourceMetadata(ctx, metadata); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	logging.FromContext(ctx).Info("Deletion cancelled.")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RunDeletionWorker deletes the infrastructure of resources pending deletion once their grace period has passed, and
+// records resources being deleted as deleted once AWS has removed their clusters. It checks for them every
+// Deletion.CheckInterval until ctx is done or the server starts draining. Deletions that fail are retried at the next
+// check.
+func (s *Server) RunDeletionWorker(ctx context.Context) {
+	ticker := time.NewTicker(s.Deletion.CheckInterval)
+	defer ticker.Stop()
+	for {
+		s.deleteDueResources(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deleteDueResources deletes the infrastructure of all resources whose grace period has passed, and checks on all those
+// being deleted.
+func (s *Server) deleteDueResources(ctx context.Context) {
+	ids, err := s.Model.SelectResourcesDueForDeletion(ctx, time.Now().UTC())
+	if err != nil {
+		return
+	}
+	for _, id := range ids {
+		if ctx.Err() != nil || s.isDraining() {
+			return
+		}
+		s.deleteDueResource(ctx, id)
+	}
+}
+
+// deleteDueResource deletes the infrastructure of a resource pending deletion, or checks on that of a resource being
+// deleted, using the request that deleted it.
+func (s *Server) deleteDueResource(ctx context.Context, id string) {
+	ctx, span := tracing.Tracer().Start(ctx, "deleteDueResource", trace.WithAttributes(
+		attribute.String("resource.id", id),
+	))
+	defer span.End()
+	ctx = logging.NewContext(ctx, logging.FromContext(ctx).WithField("resource_id", id))
+	l := logging.FromContext(ctx)
+
+	locked, err := s.Model.LockResource(ctx, id)
+	if err != nil || !locked {
+		// Another replica or request is handling the resource. If it is still due, it is tried again at the next check.
+		return
+	}
+	defer s.unlockResource(ctx, id)
+
+	// The deletion may have been cancelled, or completed, since the resource was found to be due.
+	metadata, metadataExists, err := s.Model.SelectResourceMetadata(ctx, id)
+	if err != nil || !metadataExists || metadata.IsDeleted() || !(metadata.IsPendingDeletion() || metadata.IsDeleting()) ||
+		time.Now().Before(metadata.DeleteAfter.Time) {
+		return
+	}
+
+	driverParams, _ := metadata.DeletionRequest["driver_params"].(map[string]interface{})
+	awsCreds, ok, err := s.deletionCredentials(metadata)
+	if err != nil {
+		l.WithError(err).Error("Reading credentials of deletion request")
+		return
+	}
+	if !ok {
+		// Without a credentials key, the deletion is completed by retrying the request that deleted the resource.
+		l.Debug("No credentials were kept for the deletion.")
+		return
+	}
+	policy, _, err := deletionParams(metadata.Type, metadata.Params)
+	if err != nil {
+		l.WithError(err).Error("Reading deletion policy")
+		return
+	}
+	if metadata.IsPendingDeletion() {
+		l.Info("Grace period has passed. Deleting resource.")
+	}
+	// For resources being deleted, this only requests the deletion of clusters AWS stopped deleting again.
+	if err := s.deleteInfrastructure(ctx, &metadata, policy, driverParams, nil, awsCreds); err != nil {
+		l.WithError(err).Warn("Deleting resource failed. It will be retried.")
+		return
+	}
+	// Rather than holding up other deletions by waiting for the cluster to be removed, it is checked on again at the
+	// next check.
+	if metadata.IsDeleting() {
+		deleted, err := s.redisDeleted(ctx, redisClusterID(metadata), driverParams, awsCreds)
+		if err != nil {
+			l.WithError(err).Warn("Checking on deletion of cluster failed. It will be retried.")
+			return
+		}
+		if !deleted {
+			l.Debug("Cluster is still being deleted.")
+			return
+		}
+	}
+	// The resource has been removed from AWS so that must be recorded, even if the driver is shutting down.
+	if err := s.Model.DeleteResourceMetadata(withoutCancel(ctx), id, time.Now().UTC()); err != nil {
+		// The resource is still being deleted, so recording it is retried at the next check.
+		l.WithError(err).Error("Recording deletion failed. It will be retried.")
+	}
+}
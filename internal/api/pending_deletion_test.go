@@ -0,0 +1,395 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"humanitec.io/resources/driver-aws-external/internal/aws"
+	"humanitec.io/resources/driver-aws-external/internal/aws/mock_aws"
+	"humanitec.io/resources/driver-aws-external/internal/config"
+	"humanitec.io/resources/driver-aws-external/internal/model"
+	"humanitec.io/resources/driver-aws-external/internal/model/mock_model"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/golang/mock/gomock"
+	"github.com/matryer/is"
+)
+
+// testDeletion is the deletion configuration of tests whose deletion requests are carried out later.
+var testDeletion = config.Deletion{CredentialsKey: "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="}
+
+// newPendingRedisMetadata returns the metadata of a redis resource deleted with a grace period that ended at
+// deleteAfter.
+func newPendingRedisMetadata(t *testing.T, resourceID string, deleteAfter time.Time) model.ResourceMetadata {
+	drd := newRedisDriverResourceDefinition(resourceID)
+	s := Server{Deletion: testDeletion}
+	awsCreds, err := AccountMapToAWSCredentials(drd.DriverSecrets["account"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, err := s.deletionRequest(resourceID, drd.DriverParams, awsCreds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return model.ResourceMetadata{
+		ID:              resourceID,
+		Type:            "redis",
+		Status:          model.StatusPendingDeletion,
+		Params:          drd.DriverParams,
+		Data:            map[string]interface{}{"host": "redis-1.abc123.0001.euw1.cache.amazonaws.com", "port": 6379},
+		DeleteAfter:     sql.NullTime{Time: deleteAfter, Valid: true},
+		DeletionRequest: request,
+	}
+}
+
+func TestDeleteAWSResource_GracePeriod(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			t.Fatal("AWS must not be called until the grace period has passed")
+			return nil, nil
+		},
+		Deletion: config.Deletion{GracePeriod: time.Hour, CredentialsKey: testDeletion.CredentialsKey},
+	}
+	resourceID := "test-db-id"
+	drd := newRedisDriverResourceDefinition(resourceID)
+
+	m.EXPECT().LockResource(gomock.Any(), resourceID).Return(true, nil)
+	m.EXPECT().UnlockResource(gomock.Any(), resourceID).Return(nil)
+	m.
+		EXPECT().
+		SelectResourceMetadata(gomock.Any(), resourceID).
+		Return(model.ResourceMetadata{
+			ID:     resourceID,
+			Type:   "redis",
+			Status: model.StatusReady,
+			Params: drd.DriverParams,
+			Data:   map[string]interface{}{"host": "redis-1.abc123.0001.euw1.cache.amazonaws.com", "port": 6379},
+		}, true, nil)
+	m.
+		EXPECT().
+		InsertOrUpdateResourceMetadata(gomock.Any(), gomock.Any()).
+		Do(func(ctx context.Context, metadata model.ResourceMetadata) {
+			is.True(metadata.IsPendingDeletion())
+			is.True(metadata.DeleteAfter.Valid)
+			is.True(metadata.DeleteAfter.Time.After(time.Now().Add(59 * time.Minute)))
+			is.Equal(metadata.DeletionRequest["driver_params"], drd.DriverParams)
+			_, kept := metadata.DeletionRequest["driver_secrets"]
+			is.True(!kept) // secrets are not kept in the clear
+			awsCreds, ok, err := s.deletionCredentials(metadata)
+			is.NoErr(err)
+			is.True(ok) // the credentials are kept encrypted to delete the cluster later
+			is.Equal(awsCreds.AccessKeyID, "AWS_ACCESS_KEY_ID-value")
+		}).
+		Return(nil)
+
+	res := ExecuteRequestHeader(s, http.MethodDelete, "/"+resourceID, nil, deleteHeader(drd), t)
+
+	is.Equal(res.Code, http.StatusAccepted)
+	is.True(strings.Contains(res.Body.String(), "/test-db-id/cancel-deletion"))
+}
+
+func TestDeleteAWSResource_PendingDeletion(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	s := Server{
+		Model:    m,
+		Deletion: config.Deletion{GracePeriod: time.Hour},
+	}
+	resourceID := "test-db-id"
+	drd := newRedisDriverResourceDefinition(resourceID)
+
+	m.EXPECT().LockResource(gomock.Any(), resourceID).Return(true, nil)
+	m.EXPECT().UnlockResource(gomock.Any(), resourceID).Return(nil)
+	m.
+		EXPECT().
+		SelectResourceMetadata(gomock.Any(), resourceID).
+		Return(newPendingRedisMetadata(t, resourceID, time.Now().Add(time.Hour)), true, nil)
+
+	res := ExecuteRequestHeader(s, http.MethodDelete, "/"+resourceID, nil, deleteHeader(drd), t)
+
+	is.Equal(res.Code, http.StatusAccepted) // the deletion is not rescheduled
+}
+
+func TestCreateAWSResource_PendingDeletion(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	s := Server{Model: m}
+	resourceID := "test-db-id"
+	drd := newRedisDriverResourceDefinition(resourceID)
+
+	m.EXPECT().LockResource(gomock.Any(), resourceID).Return(true, nil)
+	m.EXPECT().UnlockResource(gomock.Any(), resourceID).Return(nil)
+	m.
+		EXPECT().
+		SelectResourceMetadata(gomock.Any(), resourceID).
+		Return(newPendingRedisMetadata(t, resourceID, time.Now().Add(time.Hour)), true, nil)
+
+	res := ExecuteRequest(s, http.MethodPost, "/", drd, t)
+
+	is.Equal(res.Code, http.StatusConflict)
+}
+
+func TestCancelDeletion(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	s := Server{Model: m}
+	resourceID := "test-db-id"
+	pending := newPendingRedisMetadata(t, resourceID, time.Now().Add(time.Hour))
+
+	m.EXPECT().LockResource(gomock.Any(), resourceID).Return(true, nil)
+	m.EXPECT().UnlockResource(gomock.Any(), resourceID).Return(nil)
+	m.EXPECT().SelectResourceMetadata(gomock.Any(), resourceID).Return(pending, true, nil)
+	m.
+		EXPECT().
+		InsertOrUpdateResourceMetadata(gomock.Any(), gomock.Any()).
+		Do(func(ctx context.Context, metadata model.ResourceMetadata) {
+			is.Equal(metadata.Status, model.StatusReady)
+			is.True(!metadata.DeleteAfter.Valid)
+			is.Equal(len(metadata.DeletionRequest), 0) // the credentials are no longer needed
+			is.Equal(metadata.Data, pending.Data)
+		}).
+		Return(nil)
+
+	res := ExecuteRequest(s, http.MethodPost, "/"+resourceID+"/cancel-deletion", nil, t)
+
+	is.Equal(res.Code, http.StatusNoContent)
+}
+
+func TestCancelDeletion_NotPending(t *testing.T) {
+	for name, tc := range map[string]struct {
+		metadata model.ResourceMetadata
+		exists   bool
+		code     int
+	}{
+		"Missing": {model.ResourceMetadata{}, false, http.StatusNotFound},
+		"Deleted": {model.ResourceMetadata{
+			ID:        "test-db-id",
+			Type:      "redis",
+			DeletedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		}, true, http.StatusNotFound},
+		"Ready": {model.ResourceMetadata{ID: "test-db-id", Type: "redis", Status: model.StatusReady}, true, http.StatusConflict},
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			m := mock_model.NewMockModeler(ctrl)
+			s := Server{Model: m}
+
+			m.EXPECT().LockResource(gomock.Any(), "test-db-id").Return(true, nil)
+			m.EXPECT().UnlockResource(gomock.Any(), "test-db-id").Return(nil)
+			m.EXPECT().SelectResourceMetadata(gomock.Any(), "test-db-id").Return(tc.metadata, tc.exists, nil)
+
+			res := ExecuteRequest(s, http.MethodPost, "/test-db-id/cancel-deletion", nil, t)
+
+			is.Equal(res.Code, tc.code)
+		})
+	}
+}
+
+func TestDeleteDueResources(t *testing.T) {
+	notFound := awserr.New(elasticache.ErrCodeCacheClusterNotFoundFault, "cache cluster not found", nil)
+	for name, tc := range map[string]struct {
+		status      string
+		available   bool
+		deleteErr   error
+		describeErr error
+		deleted     bool
+	}{
+		"Deleted":        {model.StatusPendingDeletion, false, nil, notFound, true},
+		"AlreadyDeleted": {model.StatusPendingDeletion, false, notFound, nil, true},
+		"StillDeleting":  {model.StatusPendingDeletion, false, nil, nil, false},
+		"DeletingDone":   {model.StatusDeleting, false, nil, notFound, true},
+		// AWS stopped deleting the cluster, e.g. because its final snapshot failed.
+		"DeletingAvailable": {model.StatusDeleting, true, nil, nil, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			m := mock_model.NewMockModeler(ctrl)
+			a := mock_aws.NewMockClient(ctrl)
+			s := Server{
+				Model:    m,
+				Deletion: testDeletion,
+				NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+					is.Equal(key, "AWS_ACCESS_KEY_ID-value") // from the request that deleted the resource
+					is.Equal(reg, "eu-west-1")
+					return a, nil
+				},
+			}
+			resourceID := "test-db-id"
+			metadata := newPendingRedisMetadata(t, resourceID, time.Now().Add(-time.Minute))
+			metadata.Status = tc.status
+
+			m.EXPECT().SelectResourcesDueForDeletion(gomock.Any(), gomock.Any()).Return([]string{resourceID}, nil)
+			m.EXPECT().LockResource(gomock.Any(), resourceID).Return(true, nil)
+			m.EXPECT().UnlockResource(gomock.Any(), resourceID).Return(nil)
+			m.EXPECT().SelectResourceMetadata(gomock.Any(), resourceID).Return(metadata, true, nil)
+			if tc.status == model.StatusDeleting {
+				status := "deleting"
+				if tc.available {
+					status = "available"
+				}
+				a.EXPECT().DescribeElastiCacheRedis(gomock.Any(), "redis-1").Return(aws.CacheCluster{ID: "redis-1", Status: status}, tc.describeErr)
+			}
+			deleting := tc.status == model.StatusPendingDeletion || tc.available
+			if deleting {
+				a.EXPECT().DeleteElastiCacheRedis(gomock.Any(), "redis-1", "").Return(tc.deleteErr)
+			}
+			if deleting && tc.deleteErr == nil {
+				m.
+					EXPECT().
+					InsertOrUpdateResourceMetadata(gomock.Any(), gomock.Any()).
+					Do(func(ctx context.Context, metadata model.ResourceMetadata) {
+						is.True(metadata.IsDeleting())
+					}).
+					Return(nil)
+			}
+			if tc.deleteErr == nil {
+				// The worker does not wait for the cluster to be removed.
+				a.EXPECT().DescribeElastiCacheRedis(gomock.Any(), "redis-1").Return(aws.CacheCluster{ID: "redis-1", Status: "deleting"}, tc.describeErr)
+			}
+			if tc.deleted {
+				m.EXPECT().DeleteResourceMetadata(gomock.Any(), resourceID, gomock.Any()).Return(nil)
+			}
+
+			s.deleteDueResources(context.Background())
+		})
+	}
+}
+
+func TestDeleteDueResources_Failed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		Model:    m,
+		Deletion: testDeletion,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			return a, nil
+		},
+	}
+	resourceID := "test-db-id"
+
+	m.EXPECT().SelectResourcesDueForDeletion(gomock.Any(), gomock.Any()).Return([]string{resourceID, "other-id"}, nil)
+	m.EXPECT().LockResource(gomock.Any(), resourceID).Return(true, nil)
+	m.EXPECT().UnlockResource(gomock.Any(), resourceID).Return(nil)
+	m.
+		EXPECT().
+		SelectResourceMetadata(gomock.Any(), resourceID).
+		Return(newPendingRedisMetadata(t, resourceID, time.Now().Add(-time.Minute)), true, nil)
+	a.EXPECT().DeleteElastiCacheRedis(gomock.Any(), "redis-1", "").Return(errors.New("throttled"))
+	// The resource stays pending deletion so that it is retried. Others are still deleted.
+	m.EXPECT().LockResource(gomock.Any(), "other-id").Return(false, nil) // being handled elsewhere
+
+	s.deleteDueResources(context.Background())
+}
+
+func TestDeleteDueResources_RecordingFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		Model:    m,
+		Deletion: testDeletion,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			return a, nil
+		},
+	}
+	resourceID := "test-db-id"
+	metadata := newPendingRedisMetadata(t, resourceID, time.Now().Add(-time.Minute))
+	metadata.Status = model.StatusDeleting
+	notFound := awserr.New(elasticache.ErrCodeCacheClusterNotFoundFault, "cache cluster not found", nil)
+
+	m.EXPECT().SelectResourcesDueForDeletion(gomock.Any(), gomock.Any()).Return([]string{resourceID, "other-id"}, nil)
+	m.EXPECT().LockResource(gomock.Any(), resourceID).Return(true, nil)
+	m.EXPECT().SelectResourceMetadata(gomock.Any(), resourceID).Return(metadata, true, nil)
+	a.EXPECT().DescribeElastiCacheRedis(gomock.Any(), "redis-1").Return(aws.CacheCluster{}, notFound).Times(2)
+	deleteMetadata := m.EXPECT().DeleteResourceMetadata(gomock.Any(), resourceID, gomock.Any()).Return(errors.New("connection refused"))
+	// Nothing else is done with the resource, which stays deleting so that recording it is retried. Others are still
+	// deleted.
+	unlock := m.EXPECT().UnlockResource(gomock.Any(), resourceID).Return(nil).After(deleteMetadata)
+	m.EXPECT().LockResource(gomock.Any(), "other-id").Return(false, nil).After(unlock)
+
+	s.deleteDueResources(context.Background())
+}
+
+func TestDeleteDueResources_Cancelled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			t.Fatal("AWS must not be called for cancelled deletions")
+			return nil, nil
+		},
+	}
+	resourceID := "test-db-id"
+
+	m.EXPECT().SelectResourcesDueForDeletion(gomock.Any(), gomock.Any()).Return([]string{resourceID}, nil)
+	m.EXPECT().LockResource(gomock.Any(), resourceID).Return(true, nil)
+	m.EXPECT().UnlockResource(gomock.Any(), resourceID).Return(nil)
+	// The deletion was cancelled after the resource was found to be due.
+	m.
+		EXPECT().
+		SelectResourceMetadata(gomock.Any(), resourceID).
+		Return(model.ResourceMetadata{ID: resourceID, Type: "redis", Status: model.StatusReady}, true, nil)
+
+	s.deleteDueResources(context.Background())
+}
+
+func TestDeleteDueResources_NoCredentials(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			t.Fatal("AWS must not be called without the credentials of the deletion request")
+			return nil, nil
+		},
+	}
+	resourceID := "test-db-id"
+	metadata := newPendingRedisMetadata(t, resourceID, time.Now().Add(-time.Minute))
+	metadata.Status = model.StatusDeleting
+	delete(metadata.DeletionRequest, "credentials")
+
+	m.EXPECT().SelectResourcesDueForDeletion(gomock.Any(), gomock.Any()).Return([]string{resourceID}, nil)
+	m.EXPECT().LockResource(gomock.Any(), resourceID).Return(true, nil)
+	m.EXPECT().UnlockResource(gomock.Any(), resourceID).Return(nil)
+	// Left for the request that deleted the resource to be retried.
+	m.EXPECT().SelectResourceMetadata(gomock.Any(), resourceID).Return(metadata, true, nil)
+
+	s.deleteDueResources(context.Background())
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// provisioningInterrupted is returned when provisioning a resource stopped before it completed because the request was
+// cancelled, e.g. because the driver is shutting down. The resource may already partly exist in AWS.
+type provisioningInterrupted struct {
+	// pending holds what is needed to resume provisioning, such as the name of the bucket or cluster. It is recorded
+	// as the resource's data so that the next request for the resource, possibly handled by another replica, can
+	// pick up where this one left off.
+	pending map[string]interface{}
+	err     error
+}
+
+func (e *provisioningInterrupted) Error() string {
+	return "provisioning interrupted: " + e.err.Error()
+}
+
+func (e *provisioningInterrupted) Unwrap() error {
+	return e.err
+}
+
+// interrupted returns err wrapped in a provisioningInterrupted if it was caused by ctx being cancelled. Otherwise err
+// is returned unchanged.
+func interrupted(ctx context.Context, err error, pending map[string]interface{}) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	return &provisioningInterrupted{pending, err}
+}
+
+// awsErrorCode returns the AWS error code of err, or "" if it is not an AWS error.
+func awsErrorCode(err error) string {
+	var aerr awserr.Error
+	if errors.As(err, &aerr) {
+		return aerr.Code()
+	}
+	return ""
+}
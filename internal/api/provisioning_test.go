@@ -0,0 +1,194 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"humanitec.io/resources/driver-aws-external/internal/aws"
+	"humanitec.io/resources/driver-aws-external/internal/aws/mock_aws"
+	"humanitec.io/resources/driver-aws-external/internal/messages"
+	"humanitec.io/resources/driver-aws-external/internal/model"
+	"humanitec.io/resources/driver-aws-external/internal/model/mock_model"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/golang/mock/gomock"
+	"github.com/matryer/is"
+)
+
+func newRedisDriverResourceDefinition(resourceID string) messages.DriverResourceDefinition {
+	return messages.DriverResourceDefinition{
+		ID:   resourceID,
+		Type: "redis",
+		DriverParams: map[string]interface{}{
+			"region":          "eu-west-1",
+			"cache_node_type": "cache.t3.micro",
+			"cache_az":        "eu-west-1a",
+		},
+		DriverSecrets: map[string]interface{}{
+			"account": map[string]interface{}{
+				"aws_access_key_id":     "AWS_ACCESS_KEY_ID-value",
+				"aws_secret_access_key": "AWS_SECRET_ACCESS_KEY-value",
+			},
+		},
+	}
+}
+
+func TestCreateAWSResource_Interrupted(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			return a, nil
+		},
+	}
+	s.SetupRoutes()
+	resourceID := "test-db-id"
+	drd := newRedisDriverResourceDefinition(resourceID)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m.EXPECT().LockResource(gomock.Any(), resourceID).Return(true, nil)
+	m.EXPECT().SelectResourceMetadata(gomock.Any(), resourceID).Return(model.ResourceMetadata{}, false, nil)
+	// The driver shuts down while waiting for the cluster to become available.
+	var clusterId string
+	a.
+		EXPECT().
+		CreateElastiCacheRedis(gomock.Any(), gomock.AssignableToTypeOf(""), drd.ID, "cache.t3.micro", "eu-west-1a").
+		DoAndReturn(func(ctx context.Context, id, resourceID, nodeType, az string) (string, error) {
+			clusterId = id
+			cancel()
+			return "", ctx.Err()
+		})
+	m.
+		EXPECT().
+		InsertOrUpdateResourceMetadata(gomock.Any(), gomock.Any()).
+		Do(func(ctx context.Context, metadata model.ResourceMetadata) {
+			is.True(metadata.IsProvisioning())
+			is.Equal(metadata.Data, map[string]interface{}{"cluster_id": clusterId}) // enough to resume
+		}).
+		Return(nil)
+	m.EXPECT().UnlockResource(gomock.Any(), resourceID).Return(nil)
+
+	body, err := json.Marshal(drd)
+	is.NoErr(err)
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)).WithContext(ctx)
+	rr := httptest.NewRecorder()
+	s.Router.ServeHTTP(rr, req)
+
+	is.Equal(rr.Code, http.StatusServiceUnavailable)
+}
+
+func TestCreateAWSResource_Resume(t *testing.T) {
+	for name, waitErr := range map[string]error{
+		"Created":    nil,
+		"NotCreated": awserr.New(elasticache.ErrCodeCacheClusterNotFoundFault, "cache cluster not found", nil),
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			m := mock_model.NewMockModeler(ctrl)
+			a := mock_aws.NewMockClient(ctrl)
+			s := Server{
+				Model: m,
+				NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+					return a, nil
+				},
+			}
+			resourceID := "test-db-id"
+			drd := newRedisDriverResourceDefinition(resourceID)
+			host := "redis-1.abc123.0001.euw1.cache.amazonaws.com"
+
+			m.EXPECT().LockResource(gomock.Any(), resourceID).Return(true, nil)
+			m.
+				EXPECT().
+				SelectResourceMetadata(gomock.Any(), resourceID).
+				Return(model.ResourceMetadata{
+					ID:        resourceID,
+					Type:      "redis",
+					Status:    model.StatusProvisioning,
+					CreatedAt: time.Date(2020, 07, 16, 18, 12, 20, 0, time.UTC),
+					Params:    drd.DriverParams,
+					Data:      map[string]interface{}{"cluster_id": "redis-1"},
+				}, true, nil)
+			if waitErr == nil {
+				a.EXPECT().WaitForElastiCacheRedis(gomock.Any(), "redis-1").Return(host, nil)
+			} else {
+				// The earlier request was interrupted before creating the cluster.
+				a.EXPECT().WaitForElastiCacheRedis(gomock.Any(), "redis-1").Return("", waitErr)
+				a.EXPECT().CreateElastiCacheRedis(gomock.Any(), "redis-1", drd.ID, "cache.t3.micro", "eu-west-1a").Return(host, nil)
+			}
+			m.
+				EXPECT().
+				InsertOrUpdateResourceMetadata(gomock.Any(), gomock.Any()).
+				Do(func(ctx context.Context, metadata model.ResourceMetadata) {
+					is.Equal(metadata.Status, model.StatusReady)
+					is.Equal(metadata.Data["host"], host)
+				}).
+				Return(nil)
+			m.EXPECT().UnlockResource(gomock.Any(), resourceID).Return(nil)
+
+			res := ExecuteRequest(s, http.MethodPost, "/", drd, t)
+
+			is.Equal(res.Code, http.StatusOK)
+		})
+	}
+}
+
+func TestDeleteAWSResource_Provisioning(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			return a, nil
+		},
+	}
+	resourceID := "test-db-id"
+	drd := newRedisDriverResourceDefinition(resourceID)
+
+	m.EXPECT().LockResource(gomock.Any(), resourceID).Return(true, nil)
+	m.
+		EXPECT().
+		SelectResourceMetadata(gomock.Any(), resourceID).
+		Return(model.ResourceMetadata{
+			ID:     resourceID,
+			Type:   "redis",
+			Status: model.StatusProvisioning,
+			Params: drd.DriverParams,
+			Data:   map[string]interface{}{"cluster_id": "redis-1"},
+		}, true, nil)
+	a.
+		EXPECT().
+		DeleteElastiCacheRedis(gomock.Any(), "redis-1", "").
+		Return(awserr.New(elasticache.ErrCodeCacheClusterNotFoundFault, "cache cluster not found", nil))
+	m.EXPECT().DeleteResourceMetadata(gomock.Any(), resourceID, gomock.Any()).Return(nil)
+	m.EXPECT().UnlockResource(gomock.Any(), resourceID).Return(nil)
+
+	header := http.Header{}
+	jsonSecrets, _ := json.Marshal(drd.DriverSecrets)
+	header.Add("Humanitec-Driver-Secrets", base64.StdEncoding.EncodeToString(jsonSecrets))
+	jsonParams, _ := json.Marshal(drd.DriverParams)
+	header.Add("Humanitec-Driver-Params", base64.StdEncoding.EncodeToString(jsonParams))
+
+	res := ExecuteRequestHeader(s, http.MethodDelete, "/"+resourceID, nil, header, t)
+
+	is.Equal(res.Code, http.StatusNoContent) // the cluster was never created
+}
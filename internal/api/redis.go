@@ -1,54 +1,68 @@
 package api
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"strings"
 
-	"github.com/google/uuid"
+	"github.com/aws/aws-sdk-go/service/elasticache"
+	"humanitec.io/resources/driver-aws-external/internal/aws"
+	"humanitec.io/resources/driver-aws-external/internal/logging"
 	"humanitec.io/resources/driver-aws-external/internal/messages"
+	"humanitec.io/resources/driver-aws-external/internal/model"
 )
 
-func (s *Server) createRedis(drd messages.DriverResourceDefinition, awsCreds AWSCredentials) (messages.ValuesSecrets, error) {
+// createRedis creates an ElastiCache cluster for the resource. If pending is not nil, provisioning of the resource was
+// interrupted earlier and is resumed by waiting for the cluster recorded in it.
+func (s *Server) createRedis(ctx context.Context, drd messages.DriverResourceDefinition, awsCreds AWSCredentials, pending map[string]interface{}) (messages.ValuesSecrets, error) {
+	l := logging.FromContext(ctx)
 
 	var region string
 	var ok bool
 	if region, ok = drd.DriverParams["region"].(string); !ok {
-		log.Printf(`"region" property in driver_params: Expected string, Got: %T`, drd.DriverParams["region"])
+		l.Errorf(`"region" property in driver_params: Expected string, Got: %T`, drd.DriverParams["region"])
 		return messages.ValuesSecrets{}, fmt.Errorf(`"region" property in driver_params: expected string, got %T`, drd.DriverParams["region"])
 	}
 
-	client, err := s.NewAwsClient(awsCreds.AccessKeyID, awsCreds.SecretAccessKey, region, s.TimeoutLimit)
+	client, err := s.NewAwsClient(awsCreds.AccessKeyID, awsCreds.SecretAccessKey, region, s.Wait)
 	if err != nil {
-		log.Printf("Unable to create AWS client: %v", err)
+		l.WithError(err).Error("Unable to create AWS client")
 		return messages.ValuesSecrets{}, err
 	}
 
 	// Here we call the driver to create the elasticache with appropriate params
-	clusterUUID, err := uuid.NewRandom()
-	if err != nil {
-		log.Println("Unable to generate random UUID.")
-		return messages.ValuesSecrets{}, fmt.Errorf("create s3 bucket, generating name: %w", err)
+	clusterId, resuming := pending["cluster_id"].(string)
+	if !resuming {
+		clusterId = s.physicalName(drd, clusterIDRules)
 	}
-	clusterId := "redis-" + clusterUUID.String()
 
 	var cacheNodeType string
 	if cacheNodeType, ok = drd.DriverParams["cache_node_type"].(string); !ok {
-		log.Printf(`"cache_node_type" property in driver_params: Expected string, Got: %T`, drd.DriverParams["cache_node_type"])
+		l.Errorf(`"cache_node_type" property in driver_params: Expected string, Got: %T`, drd.DriverParams["cache_node_type"])
 		return messages.ValuesSecrets{}, fmt.Errorf(`"cache_node_type" property in driver_params: expected string, got %T`, drd.DriverParams["cache_node_type"])
 	}
 
 	var cacheAz string
 	if cacheAz, ok = drd.DriverParams["cache_az"].(string); !ok {
-		log.Printf(`"cache_az" property in driver_params: Expected string, Got: %T`, drd.DriverParams["cache_az"])
+		l.Errorf(`"cache_az" property in driver_params: Expected string, Got: %T`, drd.DriverParams["cache_az"])
 		return messages.ValuesSecrets{}, fmt.Errorf(`"cache_az" property in driver_params: expected string, got %T`, drd.DriverParams["cache_az"])
 	}
 
-	log.Printf(`client.CreateElastiCacheRedis("%s", "%s", "%s)`, clusterId, cacheNodeType, cacheAz)
-	endpoint, err := client.CreateElastiCacheRedis(clusterId, cacheNodeType, cacheAz)
+	l = l.WithField("cluster_id", clusterId)
+	var endpoint string
+	if resuming {
+		l.Info("Resuming provisioning of ElastiCache cluster")
+		endpoint, err = client.WaitForElastiCacheRedis(ctx, clusterId)
+	}
+	// The earlier request may have been interrupted before it created the cluster.
+	if !resuming || awsErrorCode(err) == elasticache.ErrCodeCacheClusterNotFoundFault {
+		l.Infof(`Creating ElastiCache cluster with node type "%s" in "%s"`, cacheNodeType, cacheAz)
+		endpoint, err = client.CreateElastiCacheRedis(ctx, clusterId, drd.ID, cacheNodeType, cacheAz)
+	}
 
 	if err != nil {
-		log.Printf(`client.CreateElastiCacheRedis("%s", "%s", "%s) returned error: %v`, clusterId, cacheNodeType, cacheAz, err)
-		return messages.ValuesSecrets{}, err
+		l.WithError(err).Error("Creating ElastiCache cluster failed")
+		return messages.ValuesSecrets{}, interrupted(ctx, err, map[string]interface{}{"cluster_id": clusterId})
 	}
 	return messages.ValuesSecrets{
 		Values: map[string]interface{}{
@@ -59,21 +73,26 @@ func (s *Server) createRedis(drd messages.DriverResourceDefinition, awsCreds AWS
 	}, nil
 }
 
-func (s *Server) deleteRedis(id string, driverParams, driverSecrets map[string]interface{}, awsCreds AWSCredentials) error {
+// deleteRedis deletes a cluster. If finalSnapshotId is not empty, a snapshot with that name is taken first.
+func (s *Server) deleteRedis(ctx context.Context, id, finalSnapshotId string, driverParams, driverSecrets map[string]interface{}, awsCreds AWSCredentials) error {
+	l := logging.FromContext(ctx)
 
 	var region string
 	var ok bool
 	if region, ok = driverParams["region"].(string); !ok {
-		log.Printf(`"region" property in driver_params: Expected string, Got: %T`, driverParams["region"])
+		l.Errorf(`"region" property in driver_params: Expected string, Got: %T`, driverParams["region"])
 		return fmt.Errorf(`"region" property in driver_params: expected string, got %T`, driverParams["region"])
 	}
 
-	client, err := s.NewAwsClient(awsCreds.AccessKeyID, awsCreds.SecretAccessKey, region, s.TimeoutLimit)
+	client, err := s.NewAwsClient(awsCreds.AccessKeyID, awsCreds.SecretAccessKey, region, s.Wait)
 	if err != nil {
 		return err
 	}
 
-	err = client.DeleteElastiCacheRedis(id)
+	if finalSnapshotId != "" {
+		l.WithField("cluster_id", id).Infof(`Taking final snapshot "%s" of ElastiCache cluster`, finalSnapshotId)
+	}
+	err = client.DeleteElastiCacheRedis(ctx, id, finalSnapshotId)
 
 	if err != nil {
 		return err
@@ -81,3 +100,104 @@ func (s *Server) deleteRedis(id string, driverParams, driverSecrets map[string]i
 
 	return nil
 }
+
+// retainRedis marks a cluster that is left in AWS when its resource is deleted.
+func (s *Server) retainRedis(ctx context.Context, resourceID, id string, driverParams map[string]interface{}, awsCreds AWSCredentials) error {
+	l := logging.FromContext(ctx)
+
+	var region string
+	var ok bool
+	if region, ok = driverParams["region"].(string); !ok {
+		l.Errorf(`"region" property in driver_params: Expected string, Got: %T`, driverParams["region"])
+		return fmt.Errorf(`"region" property in driver_params: expected string, got %T`, driverParams["region"])
+	}
+
+	client, err := s.NewAwsClient(awsCreds.AccessKeyID, awsCreds.SecretAccessKey, region, s.Wait)
+	if err != nil {
+		return err
+	}
+
+	l.WithField("cluster_id", id).Info("Retaining ElastiCache cluster")
+	return client.TagElastiCacheRedis(ctx, id, map[string]string{aws.RetainedTag: resourceID})
+}
+
+// waitForRedisDeletion waits for a cluster whose deletion AWS has accepted to be removed.
+func (s *Server) waitForRedisDeletion(ctx context.Context, id string, driverParams map[string]interface{}, awsCreds AWSCredentials) error {
+	l := logging.FromContext(ctx)
+
+	var region string
+	var ok bool
+	if region, ok = driverParams["region"].(string); !ok {
+		l.Errorf(`"region" property in driver_params: Expected string, Got: %T`, driverParams["region"])
+		return fmt.Errorf(`"region" property in driver_params: expected string, got %T`, driverParams["region"])
+	}
+
+	client, err := s.NewAwsClient(awsCreds.AccessKeyID, awsCreds.SecretAccessKey, region, s.Wait)
+	if err != nil {
+		return err
+	}
+
+	return client.WaitForElastiCacheRedisDeleted(ctx, id)
+}
+
+// redisDeleted reports, without waiting, whether a cluster whose deletion AWS has accepted has been removed.
+func (s *Server) redisDeleted(ctx context.Context, id string, driverParams map[string]interface{}, awsCreds AWSCredentials) (bool, error) {
+	l := logging.FromContext(ctx)
+
+	var region string
+	var ok bool
+	if region, ok = driverParams["region"].(string); !ok {
+		l.Errorf(`"region" property in driver_params: Expected string, Got: %T`, driverParams["region"])
+		return false, fmt.Errorf(`"region" property in driver_params: expected string, got %T`, driverParams["region"])
+	}
+
+	client, err := s.NewAwsClient(awsCreds.AccessKeyID, awsCreds.SecretAccessKey, region, s.Wait)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = client.DescribeElastiCacheRedis(ctx, id)
+	if awsErrorCode(err) == elasticache.ErrCodeCacheClusterNotFoundFault {
+		return true, nil
+	}
+	return false, err
+}
+
+// redisAvailable reports whether a cluster whose deletion AWS has accepted is available again. AWS stops deleting
+// clusters whose final snapshot fails, which then have to be deleted again. Clusters that are gone are not available.
+func (s *Server) redisAvailable(ctx context.Context, id string, driverParams map[string]interface{}, awsCreds AWSCredentials) (bool, error) {
+	l := logging.FromContext(ctx)
+
+	var region string
+	var ok bool
+	if region, ok = driverParams["region"].(string); !ok {
+		l.Errorf(`"region" property in driver_params: Expected string, Got: %T`, driverParams["region"])
+		return false, fmt.Errorf(`"region" property in driver_params: expected string, got %T`, driverParams["region"])
+	}
+
+	client, err := s.NewAwsClient(awsCreds.AccessKeyID, awsCreds.SecretAccessKey, region, s.Wait)
+	if err != nil {
+		return false, err
+	}
+
+	cluster, err := client.DescribeElastiCacheRedis(ctx, id)
+	if awsErrorCode(err) == elasticache.ErrCodeCacheClusterNotFoundFault {
+		return false, nil
+	}
+	return cluster.Status == "available", err
+}
+
+// redisClusterID returns the ID of the cluster of a redis resource. Resources whose provisioning was interrupted only
+// record the ID. Others record the host of the cluster, which starts with it.
+func redisClusterID(metadata model.ResourceMetadata) string {
+	if id, ok := metadata.Data["cluster_id"].(string); ok {
+		return id
+	}
+	return clusterIdFromHost(metadata.Data["host"].(string))
+}
+
+// clusterIdFromHost extracts the cache cluster ID from the endpoint address of its node. ElastiCache node endpoints
+// take the form "<cluster-id>.<hash>.<node>.<region>.cache.amazonaws.com".
+func clusterIdFromHost(host string) string {
+	return strings.SplitN(host, ".", 2)[0]
+}
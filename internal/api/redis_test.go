@@ -1,11 +1,13 @@
 package api
 
 import (
+	"context"
 	"testing"
 
 	"humanitec.io/resources/driver-aws-external/internal/aws"
 	"humanitec.io/resources/driver-aws-external/internal/aws/mock_aws"
 	"humanitec.io/resources/driver-aws-external/internal/messages"
+	"humanitec.io/resources/driver-aws-external/internal/model"
 	"humanitec.io/resources/driver-aws-external/internal/model/mock_model"
 
 	"github.com/golang/mock/gomock"
@@ -25,7 +27,7 @@ func TestCreateRedis(t *testing.T) {
 	a := mock_aws.NewMockClient(ctrl)
 	s := Server{
 		Model: m,
-		NewAwsClient: func(key, secret, reg string, timeoutLimit int) (aws.Client, error) {
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
 			is.Equal(key, accessKeyId)
 			is.Equal(secret, secretAccessKey)
 			is.Equal(reg, region)
@@ -61,11 +63,11 @@ func TestCreateRedis(t *testing.T) {
 
 	a.
 		EXPECT().
-		CreateElastiCacheRedis(gomock.AssignableToTypeOf(""), drd.DriverParams["cache_node_type"], drd.DriverParams["cache_az"]).
+		CreateElastiCacheRedis(gomock.Any(), gomock.AssignableToTypeOf(""), drd.ID, drd.DriverParams["cache_node_type"], drd.DriverParams["cache_az"]).
 		Return(redisHost, nil).
 		Times(1)
 
-	responseData, err := s.createRedis(drd, awsCreds)
+	responseData, err := s.createRedis(context.Background(), drd, awsCreds, nil)
 
 	is.NoErr(err)
 	is.Equal(expectedData, responseData)
@@ -84,7 +86,7 @@ func TestDeleteRedis(t *testing.T) {
 	a := mock_aws.NewMockClient(ctrl)
 	s := Server{
 		Model: m,
-		NewAwsClient: func(key, secret, reg string, timeoutLimit int) (aws.Client, error) {
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
 			is.Equal(key, accessKeyId)
 			is.Equal(secret, secretAccessKey)
 			is.Equal(reg, region)
@@ -107,11 +109,25 @@ func TestDeleteRedis(t *testing.T) {
 	awsCreds, _ := AccountMapToAWSCredentials(driverSecrets["account"])
 	a.
 		EXPECT().
-		DeleteElastiCacheRedis(elastiCacheID).
+		DeleteElastiCacheRedis(gomock.Any(), elastiCacheID, "").
 		Return(nil).
 		Times(1)
 
-	err := s.deleteRedis(elastiCacheID, driverParams, driverSecrets, awsCreds)
+	err := s.deleteRedis(context.Background(), elastiCacheID, "", driverParams, driverSecrets, awsCreds)
 
 	is.NoErr(err)
 }
+
+func TestRedisClusterID(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(redisClusterID(model.ResourceMetadata{Data: map[string]interface{}{"host": "redis-123.abcdef.0001.euw1.cache.amazonaws.com"}}), "redis-123")
+	is.Equal(redisClusterID(model.ResourceMetadata{Data: map[string]interface{}{"cluster_id": "redis-123"}}), "redis-123") // provisioning was interrupted
+}
+
+func TestClusterIdFromHost(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(clusterIdFromHost("redis-123.abcdef.0001.euw1.cache.amazonaws.com"), "redis-123")
+	is.Equal(clusterIdFromHost("redis-123"), "redis-123")
+}
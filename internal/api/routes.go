@@ -1,16 +1,24 @@
 package api
 
-import "github.com/gorilla/mux"
+import (
+	"github.com/gorilla/mux"
+	"humanitec.io/resources/driver-aws-external/internal/metrics"
+)
 
 // SetupRoutes creates a router for app endpoints supported by API
 func (s *Server) SetupRoutes() {
 	r := mux.NewRouter()
 	// Public
-	r.Methods("POST").Path("/").HandlerFunc(s.createOrUpdateAWSResource)
-	r.Methods("DELETE").Path("/{resourceId}").HandlerFunc(s.deleteAWSResource)
+	r.Methods("POST").Path("/").HandlerFunc(s.createOrUpdateAWSResource).Name(RouteCreateOrUpdate)
+	r.Methods("DELETE").Path("/{resourceId}").HandlerFunc(s.deleteAWSResource).Name(RouteDelete)
+	r.Methods("POST").Path("/{resourceId}/cancel-deletion").HandlerFunc(s.cancelDeletion).Name(RouteCancelDeletion)
+	r.Methods("POST").Path("/import").HandlerFunc(s.importAWSResource).Name(RouteImport)
 
 	// Internal
 	r.Methods("GET").Path("/alive").HandlerFunc(s.isAlive)
 	r.Methods("GET").Path("/health").HandlerFunc(s.isReady)
+	r.Methods("GET").Path("/metrics").Handler(metrics.Handler()).Name(RouteMetrics)
+
+	r.Use(traceRequests, requestLogging, instrumentRoutes, s.authenticate)
 	s.Router = r
 }
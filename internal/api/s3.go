@@ -1,59 +1,148 @@
 package api
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"time"
 
-	"github.com/google/uuid"
+	"humanitec.io/resources/driver-aws-external/internal/aws"
+	"humanitec.io/resources/driver-aws-external/internal/logging"
 	"humanitec.io/resources/driver-aws-external/internal/messages"
+	"humanitec.io/resources/driver-aws-external/internal/model"
 )
 
-func (s *Server) createS3Bucket(drd messages.DriverResourceDefinition, awsCreds AWSCredentials) (messages.ValuesSecrets, error) {
+// createS3Bucket creates a bucket for the resource and applies its security settings and configuration, which are
+// returned as the settings to record in the resource's metadata. If pending is not nil, provisioning of the resource was interrupted
+// earlier and is resumed using the bucket name recorded in it.
+func (s *Server) createS3Bucket(ctx context.Context, drd messages.DriverResourceDefinition, awsCreds AWSCredentials, pending map[string]interface{}) (messages.ValuesSecrets, map[string]interface{}, error) {
+	l := logging.FromContext(ctx)
 
 	var region string
 	var ok bool
 	if region, ok = drd.DriverParams["region"].(string); !ok {
-		log.Printf(`"region" property in driver_params: Expected string, Got: %T`, drd.DriverParams["region"])
-		return messages.ValuesSecrets{}, fmt.Errorf(`"region" property in driver_params: expected string, got %T`, drd.DriverParams["region"])
+		l.Errorf(`"region" property in driver_params: Expected string, Got: %T`, drd.DriverParams["region"])
+		return messages.ValuesSecrets{}, nil, fmt.Errorf(`"region" property in driver_params: expected string, got %T`, drd.DriverParams["region"])
 	}
 
-	bucketNameUUID, err := uuid.NewRandom()
+	security, settings, err := bucketSecurity(drd.DriverParams)
 	if err != nil {
-		log.Println("Unable to generate random UUID.")
-		return messages.ValuesSecrets{}, fmt.Errorf("create s3 bucket, generating name: %w", err)
+		l.WithError(err).Error("Invalid bucket security settings")
+		return messages.ValuesSecrets{}, nil, err
+	}
+	config, configSettings, configured, err := bucketConfig(drd.ResourceParams, nil)
+	if err != nil {
+		l.WithError(err).Error("Invalid bucket configuration")
+		return messages.ValuesSecrets{}, nil, err
+	}
+	for k, v := range configSettings {
+		settings[k] = v
+	}
+
+	bucketName, resuming := pending["bucket"].(string)
+	if !resuming {
+		bucketName = s.physicalName(drd, bucketNameRules)
 	}
-	bucketName := bucketNameUUID.String()
 
-	client, err := s.NewAwsClient(awsCreds.AccessKeyID, awsCreds.SecretAccessKey, region, s.TimeoutLimit)
+	client, err := s.NewAwsClient(awsCreds.AccessKeyID, awsCreds.SecretAccessKey, region, s.Wait)
 	if err != nil {
-		return messages.ValuesSecrets{}, err
+		return messages.ValuesSecrets{}, nil, err
 	}
 
-	generatedRegion, err := client.CreateBucket(bucketName)
+	// A bucket created by an earlier request for the resource is adopted. The bucket is created in the client's region,
+	// so that is the region recorded for it.
+	_, err = client.CreateBucket(ctx, bucketName, drd.ID)
+	if err == nil {
+		err = client.SecureBucket(ctx, bucketName, security)
+	}
+	if err == nil && configured {
+		err = client.ConfigureBucket(ctx, bucketName, config)
+	}
 	if err != nil {
-		return messages.ValuesSecrets{}, err
+		return messages.ValuesSecrets{}, nil, interrupted(ctx, err, map[string]interface{}{"bucket": bucketName})
 	}
 
+	values := map[string]interface{}{
+		"region": region,
+		"bucket": bucketName,
+	}
+	if config.Website != nil {
+		values["website_endpoint"] = aws.WebsiteEndpoint(bucketName, region)
+	}
 	return messages.ValuesSecrets{
-		Values: map[string]interface{}{
-			"region": generatedRegion,
-			"bucket": bucketName,
-		},
+		Values: values,
 		Secrets: map[string]interface{}{
 			"aws_access_key_id":     awsCreds.AccessKeyID,
 			"aws_secret_access_key": awsCreds.SecretAccessKey,
 		},
-	}, nil
+	}, settings, nil
 }
 
-func (s *Server) deleteS3Bucket(bucketName, region string, awsCreds AWSCredentials) error {
+// reconcileS3Bucket applies changes to the bucket configuration in resource_params to the resource's existing bucket
+// and records them in its metadata, along with the website endpoint if website hosting is enabled. Parts of the
+// configuration of imported buckets that resource_params leave out are kept as they are.
+func (s *Server) reconcileS3Bucket(ctx context.Context, drd messages.DriverResourceDefinition, awsCreds AWSCredentials, metadata *model.ResourceMetadata) error {
+	l := logging.FromContext(ctx)
 
-	client, err := s.NewAwsClient(awsCreds.AccessKeyID, awsCreds.SecretAccessKey, region, s.TimeoutLimit)
+	params := drd.ResourceParams
+	if imported, _ := metadata.Settings["imported"].(bool); imported {
+		params = withImportedConfig(params, metadata.Settings)
+	}
+	config, configSettings, changed, err := bucketConfig(params, metadata.Settings)
 	if err != nil {
+		l.WithError(err).Error("Invalid bucket configuration")
 		return err
 	}
+	if !changed {
+		return nil
+	}
 
-	err = client.DeleteBucket(bucketName)
+	bucketName, _ := metadata.Data["bucket"].(string)
+	region, _ := metadata.Params["region"].(string)
+	client, err := s.NewAwsClient(awsCreds.AccessKeyID, awsCreds.SecretAccessKey, region, s.Wait)
+	if err != nil {
+		return err
+	}
+	l.WithField("bucket", bucketName).Info("Updating bucket configuration.")
+	if err := client.ConfigureBucket(ctx, bucketName, config); err != nil {
+		return err
+	}
+
+	settings := map[string]interface{}{}
+	for k, v := range metadata.Settings {
+		settings[k] = v
+	}
+	for _, k := range bucketConfigSettings {
+		delete(settings, k)
+	}
+	for k, v := range configSettings {
+		settings[k] = v
+	}
+	metadata.Settings = settings
+
+	data := map[string]interface{}{}
+	for k, v := range metadata.Data {
+		data[k] = v
+	}
+	// Resources created before the bucket's region was taken from driver_params may have its location recorded instead.
+	data["region"] = region
+	delete(data, "website_endpoint")
+	if config.Website != nil {
+		data["website_endpoint"] = aws.WebsiteEndpoint(bucketName, region)
+	}
+	metadata.Data = data
+	metadata.UpdatedAt = time.Now().UTC()
+	// The bucket has been changed so the change must be recorded, even if the caller has gone away.
+	return s.Model.InsertOrUpdateResourceMetadata(withoutCancel(ctx), *metadata)
+}
+
+func (s *Server) deleteS3Bucket(ctx context.Context, bucketName, region string, awsCreds AWSCredentials) error {
+
+	client, err := s.NewAwsClient(awsCreds.AccessKeyID, awsCreds.SecretAccessKey, region, s.Wait)
+	if err != nil {
+		return err
+	}
+
+	err = client.DeleteBucket(ctx, bucketName)
 
 	if err != nil {
 		return err
@@ -61,3 +150,14 @@ func (s *Server) deleteS3Bucket(bucketName, region string, awsCreds AWSCredentia
 
 	return nil
 }
+
+// retainS3Bucket marks a bucket that is left in AWS when its resource is deleted.
+func (s *Server) retainS3Bucket(ctx context.Context, resourceID, bucketName, region string, awsCreds AWSCredentials) error {
+	client, err := s.NewAwsClient(awsCreds.AccessKeyID, awsCreds.SecretAccessKey, region, s.Wait)
+	if err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).WithField("bucket", bucketName).Info("Retaining bucket.")
+	return client.TagBucket(ctx, bucketName, map[string]string{aws.RetainedTag: resourceID})
+}
@@ -0,0 +1,345 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"humanitec.io/resources/driver-aws-external/internal/aws"
+)
+
+// lifecycleRuleParams is a lifecycle rule as declared in resource_params.
+type lifecycleRuleParams struct {
+	ID                                 string                      `json:"id"`
+	Prefix                             string                      `json:"prefix"`
+	Enabled                            *bool                       `json:"enabled"`
+	ExpirationDays                     int64                       `json:"expiration_days,omitempty"`
+	NoncurrentVersionExpirationDays    int64                       `json:"noncurrent_version_expiration_days,omitempty"`
+	AbortIncompleteMultipartUploadDays int64                       `json:"abort_incomplete_multipart_upload_days,omitempty"`
+	Transitions                        []lifecycleTransitionParams `json:"transitions,omitempty"`
+}
+
+type lifecycleTransitionParams struct {
+	Days         int64  `json:"days"`
+	StorageClass string `json:"storage_class"`
+}
+
+// corsRuleParams is a CORS rule as declared in resource_params.
+type corsRuleParams struct {
+	ID             string   `json:"id,omitempty"`
+	AllowedOrigins []string `json:"allowed_origins"`
+	AllowedMethods []string `json:"allowed_methods"`
+	AllowedHeaders []string `json:"allowed_headers,omitempty"`
+	ExposeHeaders  []string `json:"expose_headers,omitempty"`
+	MaxAgeSeconds  int64    `json:"max_age_seconds,omitempty"`
+}
+
+// corsMethods are the HTTP methods S3 allows in CORS rules.
+var corsMethods = []string{"GET", "PUT", "POST", "DELETE", "HEAD"}
+
+// maxCORSRules is the most CORS rules S3 accepts for a bucket.
+const maxCORSRules = 100
+
+// websiteParams is the static website configuration declared in resource_params.
+type websiteParams struct {
+	IndexDocument string `json:"index_document"`
+	ErrorDocument string `json:"error_document,omitempty"`
+}
+
+// bucketConfigSettings are the keys of the settings recorded for a bucket's configuration.
+var bucketConfigSettings = []string{"versioning", "lifecycle_rules", "cors_rules", "website"}
+
+// bucketConfig reads the versioning, lifecycle rules, CORS rules and website configuration for a bucket from its
+// resource_params. previous holds the
+// settings recorded when the bucket was last configured, or nil for a new bucket. As versioning cannot be turned off
+// once it has been enabled, it is suspended instead. The configuration is also returned in the form recorded in the
+// resource's metadata, along with whether it differs from previous.
+func bucketConfig(params, previous map[string]interface{}) (aws.BucketConfig, map[string]interface{}, bool, error) {
+	var config aws.BucketConfig
+
+	versioning, ok := params["versioning"]
+	if !ok || versioning == nil {
+		versioning = false
+	}
+	if enabled, ok := versioning.(bool); !ok {
+		return config, nil, false, invalidParams(`"versioning" property in resource_params: expected boolean, got %T`, versioning)
+	} else if enabled {
+		config.Versioning = s3.BucketVersioningStatusEnabled
+	} else if previous["versioning"] != nil {
+		config.Versioning = s3.BucketVersioningStatusSuspended
+	}
+
+	rules, err := lifecycleRules(params["lifecycle_rules"])
+	if err != nil {
+		return config, nil, false, err
+	}
+	for _, r := range rules {
+		rule := aws.LifecycleRule{
+			ID:                                 r.ID,
+			Prefix:                             r.Prefix,
+			Disabled:                           !*r.Enabled,
+			ExpirationDays:                     r.ExpirationDays,
+			NoncurrentVersionExpirationDays:    r.NoncurrentVersionExpirationDays,
+			AbortIncompleteMultipartUploadDays: r.AbortIncompleteMultipartUploadDays,
+		}
+		for _, t := range r.Transitions {
+			rule.Transitions = append(rule.Transitions, aws.LifecycleTransition{Days: t.Days, StorageClass: t.StorageClass})
+		}
+		config.LifecycleRules = append(config.LifecycleRules, rule)
+	}
+
+	cors, err := corsRules(params["cors_rules"])
+	if err != nil {
+		return config, nil, false, err
+	}
+	for _, r := range cors {
+		config.CORSRules = append(config.CORSRules, aws.CORSRule{
+			ID:             r.ID,
+			AllowedOrigins: r.AllowedOrigins,
+			AllowedMethods: r.AllowedMethods,
+			AllowedHeaders: r.AllowedHeaders,
+			ExposeHeaders:  r.ExposeHeaders,
+			MaxAgeSeconds:  r.MaxAgeSeconds,
+		})
+	}
+
+	site, err := website(params["website"])
+	if err != nil {
+		return config, nil, false, err
+	}
+	if site != nil {
+		config.Website = &aws.BucketWebsite{IndexDocument: site.IndexDocument, ErrorDocument: site.ErrorDocument}
+	}
+
+	settings := map[string]interface{}{}
+	if config.Versioning != "" {
+		settings["versioning"] = config.Versioning
+	}
+	if len(rules) > 0 {
+		if settings["lifecycle_rules"], err = recordedForm(rules); err != nil {
+			return config, nil, false, err
+		}
+	}
+	if len(cors) > 0 {
+		if settings["cors_rules"], err = recordedForm(cors); err != nil {
+			return config, nil, false, err
+		}
+	}
+	if site != nil {
+		if settings["website"], err = recordedForm(site); err != nil {
+			return config, nil, false, err
+		}
+	}
+	changed := false
+	for _, key := range bucketConfigSettings {
+		if !reflect.DeepEqual(settings[key], previous[key]) {
+			changed = true
+		}
+	}
+	return config, settings, changed, nil
+}
+
+// importedBucketSettings returns the settings recorded for the configuration an imported bucket was found with, in the
+// form bucketConfig and bucketSecurity record them. Unlike for buckets created by the driver, only the encryption is
+// recorded of its security settings.
+func importedBucketSettings(bucket aws.Bucket) (map[string]interface{}, error) {
+	settings := map[string]interface{}{}
+	var err error
+	config := bucket.Config
+	if config.Versioning != "" {
+		settings["versioning"] = config.Versioning
+	}
+	if len(config.LifecycleRules) > 0 {
+		rules := make([]lifecycleRuleParams, 0, len(config.LifecycleRules))
+		for _, r := range config.LifecycleRules {
+			enabled := !r.Disabled
+			rule := lifecycleRuleParams{
+				ID:                                 r.ID,
+				Prefix:                             r.Prefix,
+				Enabled:                            &enabled,
+				ExpirationDays:                     r.ExpirationDays,
+				NoncurrentVersionExpirationDays:    r.NoncurrentVersionExpirationDays,
+				AbortIncompleteMultipartUploadDays: r.AbortIncompleteMultipartUploadDays,
+			}
+			for _, t := range r.Transitions {
+				rule.Transitions = append(rule.Transitions, lifecycleTransitionParams{Days: t.Days, StorageClass: t.StorageClass})
+			}
+			rules = append(rules, rule)
+		}
+		if settings["lifecycle_rules"], err = recordedForm(rules); err != nil {
+			return nil, err
+		}
+	}
+	if len(config.CORSRules) > 0 {
+		rules := make([]corsRuleParams, 0, len(config.CORSRules))
+		for _, r := range config.CORSRules {
+			rules = append(rules, corsRuleParams(r))
+		}
+		if settings["cors_rules"], err = recordedForm(rules); err != nil {
+			return nil, err
+		}
+	}
+	if config.Website != nil {
+		if settings["website"], err = recordedForm(websiteParams(*config.Website)); err != nil {
+			return nil, err
+		}
+	}
+
+	switch bucket.SSEAlgorithm {
+	case s3.ServerSideEncryptionAes256:
+		settings["encryption"] = encryptionSSES3
+	case s3.ServerSideEncryptionAwsKms:
+		settings["encryption"] = encryptionSSEKMS
+	case "":
+		settings["encryption"] = encryptionNone
+	default:
+		settings["encryption"] = bucket.SSEAlgorithm
+	}
+	if bucket.KMSKeyID != "" {
+		settings["kms_key_id"] = bucket.KMSKeyID
+	}
+	return settings, nil
+}
+
+// withImportedConfig returns the resource_params of an imported bucket with each part of the bucket configuration they
+// leave out taken from the settings recorded for it, so that those parts are kept as they are rather than removed.
+func withImportedConfig(params, settings map[string]interface{}) map[string]interface{} {
+	withImported := map[string]interface{}{}
+	for k, v := range params {
+		withImported[k] = v
+	}
+	for _, key := range bucketConfigSettings {
+		if _, declared := params[key]; declared || settings[key] == nil {
+			continue
+		}
+		if key == "versioning" {
+			withImported[key] = settings[key] == s3.BucketVersioningStatusEnabled
+		} else {
+			withImported[key] = settings[key]
+		}
+	}
+	return withImported
+}
+
+// recordedForm round-trips v through JSON so that it compares equal to the same settings read back from the metadata.
+func recordedForm(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var recorded interface{}
+	err = json.Unmarshal(b, &recorded)
+	return recorded, err
+}
+
+// decodeResourceParam decodes the named resource_param into v, rejecting properties v does not have.
+func decodeResourceParam(name string, value interface{}, v interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return invalidParams(`"%s" property in resource_params: %v`, name, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return invalidParams(`"%s" property in resource_params: %v`, name, err)
+	}
+	return nil
+}
+
+// lifecycleRules decodes and validates the "lifecycle_rules" resource_param. Rules without an id are numbered and
+// rules are enabled unless stated otherwise.
+func lifecycleRules(value interface{}) ([]lifecycleRuleParams, error) {
+	if value == nil {
+		return nil, nil
+	}
+	var rules []lifecycleRuleParams
+	if err := decodeResourceParam("lifecycle_rules", value, &rules); err != nil {
+		return nil, err
+	}
+
+	ids := map[string]bool{}
+	for i := range rules {
+		r := &rules[i]
+		if r.ID == "" {
+			r.ID = "rule-" + strconv.Itoa(i+1)
+		}
+		if ids[r.ID] {
+			return nil, invalidParams(`"lifecycle_rules" property in resource_params: duplicate rule id "%s"`, r.ID)
+		}
+		ids[r.ID] = true
+		if r.Enabled == nil {
+			enabled := true
+			r.Enabled = &enabled
+		}
+
+		if r.ExpirationDays < 0 || r.NoncurrentVersionExpirationDays < 0 || r.AbortIncompleteMultipartUploadDays < 0 {
+			return nil, invalidParams(`"lifecycle_rules" property in resource_params: rule "%s": days must not be negative`, r.ID)
+		}
+		if r.ExpirationDays == 0 && r.NoncurrentVersionExpirationDays == 0 && r.AbortIncompleteMultipartUploadDays == 0 && len(r.Transitions) == 0 {
+			return nil, invalidParams(`"lifecycle_rules" property in resource_params: rule "%s": no expiration or transition set`, r.ID)
+		}
+		for _, t := range r.Transitions {
+			if t.Days <= 0 {
+				return nil, invalidParams(`"lifecycle_rules" property in resource_params: rule "%s": transition days must be positive`, r.ID)
+			}
+			if !oneOf(t.StorageClass, s3.TransitionStorageClass_Values()...) {
+				return nil, invalidParams(`"lifecycle_rules" property in resource_params: rule "%s": expected storage_class to be one of %q, got "%s"`,
+					r.ID, s3.TransitionStorageClass_Values(), t.StorageClass)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// corsRules decodes and validates the "cors_rules" resource_param.
+func corsRules(value interface{}) ([]corsRuleParams, error) {
+	if value == nil {
+		return nil, nil
+	}
+	var rules []corsRuleParams
+	if err := decodeResourceParam("cors_rules", value, &rules); err != nil {
+		return nil, err
+	}
+	if len(rules) > maxCORSRules {
+		return nil, invalidParams(`"cors_rules" property in resource_params: at most %d rules are allowed, got %d`, maxCORSRules, len(rules))
+	}
+	for i, r := range rules {
+		if len(r.AllowedOrigins) == 0 {
+			return nil, invalidParams(`"cors_rules" property in resource_params: rule %d: allowed_origins must not be empty`, i+1)
+		}
+		if len(r.AllowedMethods) == 0 {
+			return nil, invalidParams(`"cors_rules" property in resource_params: rule %d: allowed_methods must not be empty`, i+1)
+		}
+		for _, m := range r.AllowedMethods {
+			if !oneOf(m, corsMethods...) {
+				return nil, invalidParams(`"cors_rules" property in resource_params: rule %d: expected allowed_methods to be one of %q, got "%s"`,
+					i+1, corsMethods, m)
+			}
+		}
+		if r.MaxAgeSeconds < 0 {
+			return nil, invalidParams(`"cors_rules" property in resource_params: rule %d: max_age_seconds must not be negative`, i+1)
+		}
+	}
+	return rules, nil
+}
+
+// website decodes and validates the "website" resource_param. The index document defaults to index.html.
+func website(value interface{}) (*websiteParams, error) {
+	if value == nil {
+		return nil, nil
+	}
+	var w websiteParams
+	if err := decodeResourceParam("website", value, &w); err != nil {
+		return nil, err
+	}
+	if w.IndexDocument == "" {
+		w.IndexDocument = "index.html"
+	}
+	if strings.Contains(w.IndexDocument, "/") {
+		return nil, invalidParams(`"website" property in resource_params: index_document must not contain "/", got "%s"`, w.IndexDocument)
+	}
+	return &w, nil
+}
@@ -0,0 +1,374 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"humanitec.io/resources/driver-aws-external/internal/aws"
+	"humanitec.io/resources/driver-aws-external/internal/aws/mock_aws"
+	"humanitec.io/resources/driver-aws-external/internal/messages"
+	"humanitec.io/resources/driver-aws-external/internal/model"
+	"humanitec.io/resources/driver-aws-external/internal/model/mock_model"
+
+	"github.com/golang/mock/gomock"
+	"github.com/matryer/is"
+)
+
+func TestBucketConfig(t *testing.T) {
+	tmpRule := map[string]interface{}{
+		"id":              "tmp",
+		"prefix":          "tmp/",
+		"expiration_days": 7.0,
+	}
+	recordedTmpRule := map[string]interface{}{
+		"id":              "tmp",
+		"prefix":          "tmp/",
+		"enabled":         true,
+		"expiration_days": 7.0,
+	}
+	for _, tc := range []struct {
+		name     string
+		params   map[string]interface{}
+		previous map[string]interface{}
+		config   aws.BucketConfig
+		settings map[string]interface{}
+		changed  bool
+	}{
+		{
+			name:     "Nothing",
+			params:   map[string]interface{}{},
+			settings: map[string]interface{}{},
+		},
+		{
+			name: "Rules",
+			params: map[string]interface{}{
+				"versioning": true,
+				"lifecycle_rules": []interface{}{
+					map[string]interface{}{
+						"noncurrent_version_expiration_days":     30.0,
+						"abort_incomplete_multipart_upload_days": 1.0,
+						"transitions": []interface{}{
+							map[string]interface{}{"days": 30.0, "storage_class": "STANDARD_IA"},
+							map[string]interface{}{"days": 90.0, "storage_class": "GLACIER"},
+						},
+					},
+					map[string]interface{}{"id": "logs", "prefix": "logs/", "enabled": false, "expiration_days": 365.0},
+				},
+			},
+			config: aws.BucketConfig{
+				Versioning: "Enabled",
+				LifecycleRules: []aws.LifecycleRule{
+					{
+						ID:                                 "rule-1",
+						NoncurrentVersionExpirationDays:    30,
+						AbortIncompleteMultipartUploadDays: 1,
+						Transitions: []aws.LifecycleTransition{
+							{Days: 30, StorageClass: "STANDARD_IA"},
+							{Days: 90, StorageClass: "GLACIER"},
+						},
+					},
+					{ID: "logs", Prefix: "logs/", Disabled: true, ExpirationDays: 365},
+				},
+			},
+			settings: map[string]interface{}{
+				"versioning": "Enabled",
+				"lifecycle_rules": []interface{}{
+					map[string]interface{}{
+						"id":                                     "rule-1",
+						"prefix":                                 "",
+						"enabled":                                true,
+						"noncurrent_version_expiration_days":     30.0,
+						"abort_incomplete_multipart_upload_days": 1.0,
+						"transitions": []interface{}{
+							map[string]interface{}{"days": 30.0, "storage_class": "STANDARD_IA"},
+							map[string]interface{}{"days": 90.0, "storage_class": "GLACIER"},
+						},
+					},
+					map[string]interface{}{"id": "logs", "prefix": "logs/", "enabled": false, "expiration_days": 365.0},
+				},
+			},
+			changed: true,
+		},
+		{
+			name: "CORSAndWebsite",
+			params: map[string]interface{}{
+				"cors_rules": []interface{}{
+					map[string]interface{}{
+						"allowed_origins": []interface{}{"https://app.example.com"},
+						"allowed_methods": []interface{}{"GET", "PUT"},
+						"max_age_seconds": 3000.0,
+					},
+				},
+				"website": map[string]interface{}{},
+			},
+			config: aws.BucketConfig{
+				CORSRules: []aws.CORSRule{{
+					AllowedOrigins: []string{"https://app.example.com"},
+					AllowedMethods: []string{"GET", "PUT"},
+					MaxAgeSeconds:  3000,
+				}},
+				Website: &aws.BucketWebsite{IndexDocument: "index.html"},
+			},
+			settings: map[string]interface{}{
+				"cors_rules": []interface{}{
+					map[string]interface{}{
+						"allowed_origins": []interface{}{"https://app.example.com"},
+						"allowed_methods": []interface{}{"GET", "PUT"},
+						"max_age_seconds": 3000.0,
+					},
+				},
+				"website": map[string]interface{}{"index_document": "index.html"},
+			},
+			changed: true,
+		},
+		{
+			name:     "WebsiteDisabled",
+			params:   map[string]interface{}{},
+			previous: map[string]interface{}{"website": map[string]interface{}{"index_document": "index.html"}},
+			settings: map[string]interface{}{},
+			changed:  true,
+		},
+		{
+			name:     "Unchanged",
+			params:   map[string]interface{}{"versioning": true, "lifecycle_rules": []interface{}{tmpRule}},
+			previous: map[string]interface{}{"encryption": "sse-s3", "versioning": "Enabled", "lifecycle_rules": []interface{}{recordedTmpRule}},
+			config: aws.BucketConfig{
+				Versioning:     "Enabled",
+				LifecycleRules: []aws.LifecycleRule{{ID: "tmp", Prefix: "tmp/", ExpirationDays: 7}},
+			},
+			settings: map[string]interface{}{"versioning": "Enabled", "lifecycle_rules": []interface{}{recordedTmpRule}},
+		},
+		{
+			name:     "VersioningSuspended",
+			params:   map[string]interface{}{},
+			previous: map[string]interface{}{"versioning": "Enabled", "lifecycle_rules": []interface{}{recordedTmpRule}},
+			config:   aws.BucketConfig{Versioning: "Suspended"},
+			settings: map[string]interface{}{"versioning": "Suspended"},
+			changed:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			config, settings, changed, err := bucketConfig(tc.params, tc.previous)
+			is.NoErr(err)
+			is.Equal(config, tc.config)
+			is.Equal(settings, tc.settings)
+			is.Equal(changed, tc.changed)
+		})
+	}
+}
+
+func TestBucketConfig_Invalid(t *testing.T) {
+	for name, params := range map[string]map[string]interface{}{
+		"VersioningNotBoolean": {"versioning": "Enabled"},
+		"RulesNotList":         {"lifecycle_rules": "expire"},
+		"UnknownProperty":      {"lifecycle_rules": []interface{}{map[string]interface{}{"expiration_days": 7.0, "expires": true}}},
+		"NoAction":             {"lifecycle_rules": []interface{}{map[string]interface{}{"prefix": "tmp/"}}},
+		"NegativeDays":         {"lifecycle_rules": []interface{}{map[string]interface{}{"expiration_days": -1.0}}},
+		"DuplicateID": {"lifecycle_rules": []interface{}{
+			map[string]interface{}{"id": "tmp", "expiration_days": 7.0},
+			map[string]interface{}{"id": "tmp", "expiration_days": 1.0},
+		}},
+		"UnknownStorageClass": {"lifecycle_rules": []interface{}{map[string]interface{}{
+			"transitions": []interface{}{map[string]interface{}{"days": 30.0, "storage_class": "TAPE"}},
+		}}},
+		"CORSWithoutOrigins": {"cors_rules": []interface{}{map[string]interface{}{"allowed_methods": []interface{}{"GET"}}}},
+		"CORSUnknownMethod": {"cors_rules": []interface{}{map[string]interface{}{
+			"allowed_origins": []interface{}{"*"},
+			"allowed_methods": []interface{}{"PATCH"},
+		}}},
+		"WebsiteNotObject":     {"website": true},
+		"WebsiteIndexWithPath": {"website": map[string]interface{}{"index_document": "docs/index.html"}},
+		"TransitionWithoutDays": {"lifecycle_rules": []interface{}{map[string]interface{}{
+			"transitions": []interface{}{map[string]interface{}{"storage_class": "GLACIER"}},
+		}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			_, _, _, err := bucketConfig(params, nil)
+			var perr *invalidParamsError
+			is.True(errors.As(err, &perr))
+		})
+	}
+}
+
+func TestWithImportedConfig(t *testing.T) {
+	is := is.New(t)
+	settings := map[string]interface{}{
+		"imported":        true,
+		"versioning":      "Suspended",
+		"lifecycle_rules": []interface{}{map[string]interface{}{"id": "expire-tmp", "prefix": "", "enabled": true, "expiration_days": 7.0}},
+		"website":         map[string]interface{}{"index_document": "index.html"},
+	}
+
+	params := withImportedConfig(map[string]interface{}{"website": nil}, settings)
+
+	is.Equal(params, map[string]interface{}{
+		"versioning":      false, // suspended
+		"lifecycle_rules": settings["lifecycle_rules"],
+		"website":         nil, // declared, so not taken from the imported bucket
+	})
+	_, recorded, changed, err := bucketConfig(params, settings)
+	is.NoErr(err)
+	is.Equal(recorded["versioning"], "Suspended")
+	is.True(changed) // only the website is removed
+	is.Equal(recorded["website"], nil)
+	is.Equal(recorded["lifecycle_rules"], settings["lifecycle_rules"])
+}
+
+func TestCreateAWSResource_ReconcileBucket(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	region := "eu-west-1"
+	m := mock_model.NewMockModeler(ctrl)
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			is.Equal(reg, region)
+			return a, nil
+		},
+	}
+	drd := messages.DriverResourceDefinition{
+		ID:             "test-db-id",
+		Type:           "s3",
+		ResourceParams: map[string]interface{}{"versioning": true},
+		DriverParams:   map[string]interface{}{"region": region},
+		DriverSecrets: map[string]interface{}{
+			"account": map[string]interface{}{
+				"aws_access_key_id":     "AWS_ACCESS_KEY_ID-value",
+				"aws_secret_access_key": "AWS_SECRET_ACCESS_KEY-value",
+			},
+		},
+	}
+	metadata := model.ResourceMetadata{
+		ID:        drd.ID,
+		Type:      drd.Type,
+		CreatedAt: time.Date(2020, 07, 16, 18, 12, 20, 0, time.UTC),
+		DeletedAt: sql.NullTime{Valid: false},
+		Params:    map[string]interface{}{"region": region},
+		Data:      map[string]interface{}{"region": region, "bucket": "my-s3-bucket"},
+		Settings:  defaultBucketSettings(),
+	}
+	updated := metadata
+	updated.Settings = defaultBucketSettings()
+	updated.Settings["versioning"] = "Enabled"
+
+	m.EXPECT().LockResource(gomock.Any(), drd.ID).Return(true, nil)
+	m.EXPECT().UnlockResource(gomock.Any(), drd.ID).Return(nil)
+	m.EXPECT().SelectResourceMetadata(gomock.Any(), drd.ID).Return(metadata, true, nil)
+	a.
+		EXPECT().
+		ConfigureBucket(gomock.Any(), "my-s3-bucket", aws.BucketConfig{Versioning: "Enabled"}).
+		Return(nil).
+		Times(1)
+	m.
+		EXPECT().
+		InsertOrUpdateResourceMetadata(gomock.Any(), IgnoreDateResourceMetadata(updated)).
+		Return(nil).
+		Times(1)
+
+	res := ExecuteRequest(s, http.MethodPost, "/", drd, t)
+
+	is.Equal(res.Code, http.StatusOK)
+}
+
+func TestReconcileS3Bucket_Website(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			return a, nil
+		},
+	}
+	drd := messages.DriverResourceDefinition{
+		ID:             "test-db-id",
+		Type:           "s3",
+		ResourceParams: map[string]interface{}{"website": map[string]interface{}{"error_document": "404.html"}},
+	}
+	metadata := model.ResourceMetadata{
+		ID:     drd.ID,
+		Type:   drd.Type,
+		Params: map[string]interface{}{"region": "eu-central-1"},
+		// Resources created by earlier versions may have the bucket's location recorded as its region.
+		Data:     map[string]interface{}{"region": "/my-s3-bucket", "bucket": "my-s3-bucket"},
+		Settings: defaultBucketSettings(),
+	}
+	a.
+		EXPECT().
+		ConfigureBucket(gomock.Any(), "my-s3-bucket", aws.BucketConfig{
+			Website: &aws.BucketWebsite{IndexDocument: "index.html", ErrorDocument: "404.html"},
+		}).
+		Return(nil).
+		Times(1)
+	m.EXPECT().InsertOrUpdateResourceMetadata(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+	is.NoErr(s.reconcileS3Bucket(context.Background(), drd, AWSCredentials{}, &metadata))
+	is.Equal(metadata.Data["region"], "eu-central-1")
+	is.Equal(metadata.Data["website_endpoint"], "http://my-s3-bucket.s3-website.eu-central-1.amazonaws.com")
+	is.Equal(metadata.Settings["website"], map[string]interface{}{"index_document": "index.html", "error_document": "404.html"})
+	is.Equal(metadata.Settings["encryption"], "sse-s3") // security settings are kept
+
+	// Disabling the website removes the endpoint.
+	drd.ResourceParams = map[string]interface{}{}
+	a.EXPECT().ConfigureBucket(gomock.Any(), "my-s3-bucket", aws.BucketConfig{}).Return(nil).Times(1)
+	m.EXPECT().InsertOrUpdateResourceMetadata(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+	is.NoErr(s.reconcileS3Bucket(context.Background(), drd, AWSCredentials{}, &metadata))
+	_, hasEndpoint := metadata.Data["website_endpoint"]
+	is.True(!hasEndpoint)
+	_, hasWebsite := metadata.Settings["website"]
+	is.True(!hasWebsite)
+}
+
+func TestCreateAWSResource_ReconcileBucketInvalid(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			t.Fatal("AWS must not be called for invalid params")
+			return nil, nil
+		},
+	}
+	drd := messages.DriverResourceDefinition{
+		ID:             "test-db-id",
+		Type:           "s3",
+		ResourceParams: map[string]interface{}{"versioning": "yes"},
+		DriverParams:   map[string]interface{}{"region": "eu-west-1"},
+		DriverSecrets: map[string]interface{}{
+			"account": map[string]interface{}{
+				"aws_access_key_id":     "AWS_ACCESS_KEY_ID-value",
+				"aws_secret_access_key": "AWS_SECRET_ACCESS_KEY-value",
+			},
+		},
+	}
+	metadata := model.ResourceMetadata{
+		ID:       drd.ID,
+		Type:     drd.Type,
+		Params:   map[string]interface{}{"region": "eu-west-1"},
+		Data:     map[string]interface{}{"region": "eu-west-1", "bucket": "my-s3-bucket"},
+		Settings: defaultBucketSettings(),
+	}
+	m.EXPECT().LockResource(gomock.Any(), drd.ID).Return(true, nil)
+	m.EXPECT().UnlockResource(gomock.Any(), drd.ID).Return(nil)
+	m.EXPECT().SelectResourceMetadata(gomock.Any(), drd.ID).Return(metadata, true, nil)
+
+	res := ExecuteRequest(s, http.MethodPost, "/", drd, t)
+
+	is.Equal(res.Code, http.StatusBadRequest)
+}
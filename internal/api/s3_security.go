@@ -0,0 +1,81 @@
+package api
+
+import (
+	"github.com/aws/aws-sdk-go/service/s3"
+	"humanitec.io/resources/driver-aws-external/internal/aws"
+)
+
+// Values of the "encryption" driver_param.
+const (
+	encryptionSSES3  = "sse-s3"
+	encryptionSSEKMS = "sse-kms"
+	encryptionNone   = "none"
+)
+
+// bucketSecurity reads the security settings for a bucket from its driver_params. Buckets are encrypted with SSE-S3
+// (or SSE-KMS if "kms_key_id" is set), block all public access, disable ACLs and only accept requests over TLS unless
+// driver_params say otherwise. The settings are also returned in the form recorded in the resource's metadata.
+func bucketSecurity(params map[string]interface{}) (aws.BucketSecurity, map[string]interface{}, error) {
+	var security aws.BucketSecurity
+
+	kmsKeyID, err := stringParam(params, "kms_key_id", "")
+	if err != nil {
+		return security, nil, err
+	}
+	defaultEncryption := encryptionSSES3
+	if kmsKeyID != "" {
+		defaultEncryption = encryptionSSEKMS
+	}
+	encryption, err := stringParam(params, "encryption", defaultEncryption)
+	if err != nil {
+		return security, nil, err
+	}
+	switch encryption {
+	case encryptionSSES3:
+		security.SSEAlgorithm = s3.ServerSideEncryptionAes256
+	case encryptionSSEKMS:
+		security.SSEAlgorithm = s3.ServerSideEncryptionAwsKms
+		security.KMSKeyID = kmsKeyID
+	case encryptionNone:
+	default:
+		return security, nil, invalidParams(`"encryption" property in driver_params: expected one of "%s", "%s" or "%s", got "%s"`,
+			encryptionSSES3, encryptionSSEKMS, encryptionNone, encryption)
+	}
+	if kmsKeyID != "" && encryption != encryptionSSEKMS {
+		return security, nil, invalidParams(`"kms_key_id" property in driver_params: only used with "%s" encryption`, encryptionSSEKMS)
+	}
+
+	if security.BlockPublicAccess, err = boolParam(params, "block_public_access", true); err != nil {
+		return security, nil, err
+	}
+	if security.EnforceTLS, err = boolParam(params, "enforce_tls", true); err != nil {
+		return security, nil, err
+	}
+	if security.ObjectOwnership, err = stringParam(params, "object_ownership", s3.ObjectOwnershipBucketOwnerEnforced); err != nil {
+		return security, nil, err
+	}
+	if !oneOf(security.ObjectOwnership, s3.ObjectOwnership_Values()...) {
+		return security, nil, invalidParams(`"object_ownership" property in driver_params: expected one of %q, got "%s"`,
+			s3.ObjectOwnership_Values(), security.ObjectOwnership)
+	}
+
+	settings := map[string]interface{}{
+		"encryption":          encryption,
+		"block_public_access": security.BlockPublicAccess,
+		"object_ownership":    security.ObjectOwnership,
+		"enforce_tls":         security.EnforceTLS,
+	}
+	if kmsKeyID != "" {
+		settings["kms_key_id"] = kmsKeyID
+	}
+	return security, settings, nil
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
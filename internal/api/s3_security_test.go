@@ -0,0 +1,131 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"humanitec.io/resources/driver-aws-external/internal/aws"
+	"humanitec.io/resources/driver-aws-external/internal/messages"
+	"humanitec.io/resources/driver-aws-external/internal/model"
+	"humanitec.io/resources/driver-aws-external/internal/model/mock_model"
+
+	"github.com/golang/mock/gomock"
+	"github.com/matryer/is"
+)
+
+func TestBucketSecurity(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		params   map[string]interface{}
+		security aws.BucketSecurity
+		settings map[string]interface{}
+	}{
+		{
+			name:   "Defaults",
+			params: map[string]interface{}{"region": "eu-west-1"},
+			security: aws.BucketSecurity{
+				SSEAlgorithm:      "AES256",
+				BlockPublicAccess: true,
+				ObjectOwnership:   "BucketOwnerEnforced",
+				EnforceTLS:        true,
+			},
+			settings: defaultBucketSettings(),
+		},
+		{
+			name:   "KMSKey",
+			params: map[string]interface{}{"kms_key_id": "alias/my-key"},
+			security: aws.BucketSecurity{
+				SSEAlgorithm:      "aws:kms",
+				KMSKeyID:          "alias/my-key",
+				BlockPublicAccess: true,
+				ObjectOwnership:   "BucketOwnerEnforced",
+				EnforceTLS:        true,
+			},
+			settings: map[string]interface{}{
+				"encryption":          "sse-kms",
+				"kms_key_id":          "alias/my-key",
+				"block_public_access": true,
+				"object_ownership":    "BucketOwnerEnforced",
+				"enforce_tls":         true,
+			},
+		},
+		{
+			name: "Overridden",
+			params: map[string]interface{}{
+				"encryption":          "none",
+				"block_public_access": false,
+				"object_ownership":    "ObjectWriter",
+				"enforce_tls":         false,
+			},
+			security: aws.BucketSecurity{ObjectOwnership: "ObjectWriter"},
+			settings: map[string]interface{}{
+				"encryption":          "none",
+				"block_public_access": false,
+				"object_ownership":    "ObjectWriter",
+				"enforce_tls":         false,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+			security, settings, err := bucketSecurity(tc.params)
+			is.NoErr(err)
+			is.Equal(security, tc.security)
+			is.Equal(settings, tc.settings)
+		})
+	}
+}
+
+func TestBucketSecurity_Invalid(t *testing.T) {
+	for name, params := range map[string]map[string]interface{}{
+		"UnknownEncryption": {"encryption": "rot13"},
+		"KeyWithoutKMS":     {"encryption": "sse-s3", "kms_key_id": "alias/my-key"},
+		"UnknownOwnership":  {"object_ownership": "Anyone"},
+		"NotBoolean":        {"enforce_tls": "yes"},
+		"NotString":         {"kms_key_id": 42},
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			_, _, err := bucketSecurity(params)
+			var perr *invalidParamsError
+			is.True(errors.As(err, &perr))
+		})
+	}
+}
+
+func TestCreateAWSResource_InvalidParams(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_model.NewMockModeler(ctrl)
+	s := Server{
+		Model: m,
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			t.Fatal("AWS must not be called for invalid params")
+			return nil, nil
+		},
+	}
+	drd := messages.DriverResourceDefinition{
+		ID:   "test-db-id",
+		Type: "s3",
+		DriverParams: map[string]interface{}{
+			"region":     "eu-west-1",
+			"encryption": "rot13",
+		},
+		DriverSecrets: map[string]interface{}{
+			"account": map[string]interface{}{
+				"aws_access_key_id":     "AWS_ACCESS_KEY_ID-value",
+				"aws_secret_access_key": "AWS_SECRET_ACCESS_KEY-value",
+			},
+		},
+	}
+	m.EXPECT().LockResource(gomock.Any(), drd.ID).Return(true, nil)
+	m.EXPECT().UnlockResource(gomock.Any(), drd.ID).Return(nil)
+	m.EXPECT().SelectResourceMetadata(gomock.Any(), drd.ID).Return(model.ResourceMetadata{}, false, nil)
+
+	res := ExecuteRequest(s, http.MethodPost, "/", drd, t)
+
+	is.Equal(res.Code, http.StatusBadRequest)
+}
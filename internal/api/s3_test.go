@@ -1,6 +1,8 @@
 package api
 
 import (
+	"context"
+	"fmt"
 	"testing"
 
 	"humanitec.io/resources/driver-aws-external/internal/aws"
@@ -25,7 +27,7 @@ func TestCreateS3Bucket(t *testing.T) {
 	a := mock_aws.NewMockClient(ctrl)
 	s := Server{
 		Model: m,
-		NewAwsClient: func(key, secret, reg string, timeout int) (aws.Client, error) {
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
 			is.Equal(key, accessKeyId)
 			is.Equal(secret, secretAccessKey)
 			is.Equal(reg, region)
@@ -60,15 +62,154 @@ func TestCreateS3Bucket(t *testing.T) {
 
 	a.
 		EXPECT().
-		CreateBucket(gomock.AssignableToTypeOf("")).
-		Do(func(bn interface{}) {
+		CreateBucket(gomock.Any(), gomock.AssignableToTypeOf(""), drd.ID).
+		Do(func(ctx, bn, resourceID interface{}) {
 			expectedData.Values["bucket"] = bn.(string)
 		}).
 		Return(region, nil).
 		Times(1)
+	a.
+		EXPECT().
+		SecureBucket(gomock.Any(), gomock.AssignableToTypeOf(""), aws.BucketSecurity{
+			SSEAlgorithm:      "AES256",
+			BlockPublicAccess: true,
+			ObjectOwnership:   "BucketOwnerEnforced",
+			EnforceTLS:        true,
+		}).
+		Return(nil).
+		Times(1)
 
-	responseData, err := s.createS3Bucket(drd, awsCreds)
+	responseData, settings, err := s.createS3Bucket(context.Background(), drd, awsCreds, nil)
 
 	is.NoErr(err)
 	is.Equal(expectedData, responseData)
+	is.Equal(settings, defaultBucketSettings())
+}
+
+func TestCreateS3Bucket_Configured(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			return a, nil
+		},
+	}
+	drd := messages.DriverResourceDefinition{
+		ID:   "resource-id",
+		Type: "s3",
+		ResourceParams: map[string]interface{}{
+			"versioning":      true,
+			"lifecycle_rules": []interface{}{map[string]interface{}{"id": "tmp", "prefix": "tmp/", "expiration_days": 7.0}},
+			"website":         map[string]interface{}{},
+		},
+		DriverParams: map[string]interface{}{
+			"region": "eu-west-1",
+		},
+	}
+
+	gomock.InOrder(
+		a.EXPECT().CreateBucket(gomock.Any(), gomock.AssignableToTypeOf(""), drd.ID).Return("eu-west-1", nil),
+		a.EXPECT().SecureBucket(gomock.Any(), gomock.AssignableToTypeOf(""), gomock.Any()).Return(nil),
+		a.
+			EXPECT().
+			ConfigureBucket(gomock.Any(), gomock.AssignableToTypeOf(""), aws.BucketConfig{
+				Versioning:     "Enabled",
+				LifecycleRules: []aws.LifecycleRule{{ID: "tmp", Prefix: "tmp/", ExpirationDays: 7}},
+				Website:        &aws.BucketWebsite{IndexDocument: "index.html"},
+			}).
+			Return(nil),
+	)
+
+	data, settings, err := s.createS3Bucket(context.Background(), drd, AWSCredentials{}, nil)
+
+	is.NoErr(err)
+	is.Equal(data.Values["website_endpoint"], fmt.Sprintf("http://%s.s3-website-eu-west-1.amazonaws.com", data.Values["bucket"]))
+	is.Equal(settings["encryption"], "sse-s3") // security settings are recorded too
+	is.Equal(settings["versioning"], "Enabled")
+	is.Equal(len(settings["lifecycle_rules"].([]interface{})), 1)
+}
+
+func TestCreateS3Bucket_Location(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			return a, nil
+		},
+	}
+	drd := messages.DriverResourceDefinition{
+		ID:             "resource-id",
+		Type:           "s3",
+		ResourceParams: map[string]interface{}{"website": map[string]interface{}{}},
+		DriverParams:   map[string]interface{}{"region": "eu-west-1"},
+	}
+
+	// S3 reports the location of a new bucket rather than its region.
+	a.
+		EXPECT().
+		CreateBucket(gomock.Any(), gomock.AssignableToTypeOf(""), drd.ID).
+		DoAndReturn(func(ctx context.Context, bucketName, resourceID string) (string, error) {
+			return "/" + bucketName, nil
+		})
+	a.EXPECT().SecureBucket(gomock.Any(), gomock.AssignableToTypeOf(""), gomock.Any()).Return(nil)
+	a.EXPECT().ConfigureBucket(gomock.Any(), gomock.AssignableToTypeOf(""), gomock.Any()).Return(nil)
+
+	data, _, err := s.createS3Bucket(context.Background(), drd, AWSCredentials{}, nil)
+
+	is.NoErr(err)
+	is.Equal(data.Values["region"], "eu-west-1")
+	is.Equal(data.Values["website_endpoint"], fmt.Sprintf("http://%s.s3-website-eu-west-1.amazonaws.com", data.Values["bucket"]))
+}
+
+func TestCreateS3Bucket_Retry(t *testing.T) {
+	is := is.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	a := mock_aws.NewMockClient(ctrl)
+	s := Server{
+		NewAwsClient: func(key, secret, reg string, wait aws.WaitConfig) (aws.Client, error) {
+			return a, nil
+		},
+	}
+	drd := messages.DriverResourceDefinition{
+		ID:           "resource-id",
+		Type:         "s3",
+		DriverParams: map[string]interface{}{"region": "eu-west-1"},
+	}
+
+	var names []string
+	a.
+		EXPECT().
+		CreateBucket(gomock.Any(), gomock.AssignableToTypeOf(""), drd.ID).
+		Do(func(ctx, bn, resourceID interface{}) {
+			names = append(names, bn.(string))
+		}).
+		Return("eu-west-1", nil).
+		Times(2)
+	a.EXPECT().SecureBucket(gomock.Any(), gomock.AssignableToTypeOf(""), gomock.Any()).Return(nil).Times(2)
+
+	// E.g. the first response was lost, so the caller retries without the metadata having been recorded.
+	_, _, err := s.createS3Bucket(context.Background(), drd, AWSCredentials{}, nil)
+	is.NoErr(err)
+	_, _, err = s.createS3Bucket(context.Background(), drd, AWSCredentials{}, nil)
+	is.NoErr(err)
+
+	is.Equal(names[0], names[1]) // the retry creates, and so adopts, the same bucket
+}
+
+// defaultBucketSettings are the settings recorded for buckets whose driver_params do not override any.
+func defaultBucketSettings() map[string]interface{} {
+	return map[string]interface{}{
+		"encryption":          "sse-s3",
+		"block_public_access": true,
+		"object_ownership":    "BucketOwnerEnforced",
+		"enforce_tls":         true,
+	}
 }
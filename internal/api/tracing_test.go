@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/matryer/is"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceRequests_PropagatesTraceContext(t *testing.T) {
+	is := is.New(t)
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rr := ExecuteRequestHeader(Server{}, http.MethodGet, "/alive", nil, header, t)
+
+	is.Equal(rr.Code, http.StatusOK)
+	spans := recorder.Ended()
+	is.Equal(len(spans), 1)
+	is.Equal(spans[0].Name(), "GET /alive")
+	is.Equal(spans[0].SpanKind(), trace.SpanKindServer)
+	is.Equal(spans[0].SpanContext().TraceID().String(), "4bf92f3577b34da6a3ce929d0e0e4736")
+	is.Equal(spans[0].Parent().SpanID().String(), "00f067aa0ba902b7")
+}
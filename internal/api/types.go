@@ -1,9 +1,12 @@
 package api
 
 import (
+	"context"
 	"net/http"
+	"time"
 
 	"humanitec.io/resources/driver-aws-external/internal/aws"
+	"humanitec.io/resources/driver-aws-external/internal/config"
 	"humanitec.io/resources/driver-aws-external/internal/doer"
 	"humanitec.io/resources/driver-aws-external/internal/model"
 )
@@ -14,8 +17,27 @@ type Server struct {
 	Router       http.Handler
 	ServingPort  string
 	HttpClient   doer.Doer
-	NewAwsClient func(string, string, string, int) (aws.Client, error)
-	TimeoutLimit int
+	NewAwsClient func(string, string, string, aws.WaitConfig) (aws.Client, error)
+	// Wait configures how AWS clients wait for clusters to become available or to be removed.
+	Wait aws.WaitConfig
+	// PingAWS checks that AWS can be reached. If nil, AWS is not checked by /health.
+	PingAWS func(ctx context.Context) error
+	// HealthCheckTimeout limits how long each /health check may take. Defaults to defaultHealthCheckTimeout.
+	HealthCheckTimeout time.Duration
+	// Auth holds the Authenticator for each named route. (See Routes.) Routes without one are open.
+	Auth map[string]Authenticator
+	// DefaultRegion is used for resources whose driver_params do not include a region.
+	DefaultRegion string
+	// RegionDefaults holds defaults for other driver_params, by region.
+	RegionDefaults map[string]config.RegionDefaults
+	// Naming configures the names of buckets and clusters. The template defaults to config.DefaultNamingTemplate.
+	Naming config.Naming
+	// Deletion configures the grace period before the infrastructure of deleted resources is deleted. Without one, it
+	// is deleted straight away.
+	Deletion config.Deletion
+
+	// draining is set once the server starts shutting down. It is only accessed atomically.
+	draining int32
 }
 
 type AWSCredentials struct {
@@ -3,9 +3,10 @@ package api
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"regexp"
+
+	"humanitec.io/resources/driver-aws-external/internal/logging"
 )
 
 func AccountMapToAWSCredentials(accountMap interface{}) (AWSCredentials, error) {
@@ -34,7 +35,7 @@ var validID = regexp.MustCompile(`^[a-z0-9][a-z0-9-]+[a-z0-9]$`)
 func writeAsJSON(w http.ResponseWriter, statusCode int, obj interface{}) {
 	jsonObj, err := json.Marshal(obj)
 	if err != nil {
-		log.Println(err)
+		logging.Base().WithError(err).Error("Unable to encode response as JSON")
 		w.WriteHeader(500)
 		return
 	}
@@ -62,10 +63,7 @@ func isValidAsID(str string) bool {
 	return validID.MatchString(str)
 }
 
+// isAlive only reports that the process is serving requests. Dependencies are checked by isReady.
 func (s *Server) isAlive(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
-
-func (s *Server) isReady(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-}
@@ -0,0 +1,203 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"humanitec.io/resources/driver-aws-external/internal/logging"
+)
+
+// BucketConfig describes how a bucket is configured for the application using it, as opposed to its security. Apart
+// from versioning, each part of the configuration replaces what was set on the bucket before.
+type BucketConfig struct {
+	// Versioning is s3.BucketVersioningStatusEnabled or s3.BucketVersioningStatusSuspended. Once enabled, versioning
+	// can only be suspended. If empty, versioning is left as it is.
+	Versioning string
+	// LifecycleRules replace the bucket's lifecycle configuration. If there are none, it is removed.
+	LifecycleRules []LifecycleRule
+	// CORSRules replace the bucket's CORS configuration. If there are none, it is removed.
+	CORSRules []CORSRule
+	// Website enables static website hosting. If nil, it is disabled.
+	Website *BucketWebsite
+}
+
+// LifecycleRule expires or transitions objects whose keys start with Prefix. Zero values disable the corresponding
+// action.
+type LifecycleRule struct {
+	ID                                 string
+	Prefix                             string
+	Disabled                           bool
+	ExpirationDays                     int64
+	NoncurrentVersionExpirationDays    int64
+	AbortIncompleteMultipartUploadDays int64
+	Transitions                        []LifecycleTransition
+}
+
+// LifecycleTransition moves objects to another storage class, e.g. s3.TransitionStorageClassGlacier, once they are
+// Days old.
+type LifecycleTransition struct {
+	Days         int64
+	StorageClass string
+}
+
+// CORSRule allows browsers on AllowedOrigins to make cross-origin requests to the bucket.
+type CORSRule struct {
+	ID             string
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	ExposeHeaders  []string
+	MaxAgeSeconds  int64
+}
+
+// BucketWebsite configures static website hosting for a bucket.
+type BucketWebsite struct {
+	IndexDocument string
+	ErrorDocument string
+}
+
+// WebsiteEndpoint returns the URL a bucket's static website is served from.
+func WebsiteEndpoint(bucketName, region string) string {
+	dnsSuffix := "amazonaws.com"
+	if p, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), region); ok {
+		dnsSuffix = p.DNSSuffix()
+	}
+	// Regions launched before 2014 use a dash rather than a dot between "s3-website" and the region.
+	separator := "."
+	switch region {
+	case "us-east-1", "us-west-1", "us-west-2", "ap-southeast-1", "ap-southeast-2", "ap-northeast-1", "eu-west-1",
+		"sa-east-1", "us-gov-west-1":
+		separator = "-"
+	}
+	return fmt.Sprintf("http://%s.s3-website%s%s.%s", bucketName, separator, region, dnsSuffix)
+}
+
+// ConfigureBucket applies the configuration to an existing bucket.
+func (c awsClient) ConfigureBucket(ctx context.Context, bucketName string, config BucketConfig) error {
+	l := logging.FromContext(ctx).WithField("bucket", bucketName)
+	svc := s3.New(c.sess)
+
+	if config.Versioning != "" {
+		_, err := svc.PutBucketVersioningWithContext(ctx, &s3.PutBucketVersioningInput{
+			Bucket:                  aws.String(bucketName),
+			VersioningConfiguration: &s3.VersioningConfiguration{Status: aws.String(config.Versioning)},
+		})
+		if err != nil {
+			l.WithError(err).Error("Error setting s3 bucket versioning")
+			return fmt.Errorf(`setting versioning of s3 bucket "%s": %w`, bucketName, err)
+		}
+	}
+
+	if len(config.LifecycleRules) == 0 {
+		_, err := svc.DeleteBucketLifecycleWithContext(ctx, &s3.DeleteBucketLifecycleInput{Bucket: aws.String(bucketName)})
+		if err != nil {
+			l.WithError(err).Error("Error removing s3 bucket lifecycle rules")
+			return fmt.Errorf(`removing lifecycle rules of s3 bucket "%s": %w`, bucketName, err)
+		}
+	} else {
+		rules := make([]*s3.LifecycleRule, 0, len(config.LifecycleRules))
+		for _, r := range config.LifecycleRules {
+			rules = append(rules, toS3LifecycleRule(r))
+		}
+		_, err := svc.PutBucketLifecycleConfigurationWithContext(ctx, &s3.PutBucketLifecycleConfigurationInput{
+			Bucket:                 aws.String(bucketName),
+			LifecycleConfiguration: &s3.BucketLifecycleConfiguration{Rules: rules},
+		})
+		if err != nil {
+			l.WithError(err).Error("Error setting s3 bucket lifecycle rules")
+			return fmt.Errorf(`setting lifecycle rules of s3 bucket "%s": %w`, bucketName, err)
+		}
+	}
+
+	if len(config.CORSRules) == 0 {
+		_, err := svc.DeleteBucketCorsWithContext(ctx, &s3.DeleteBucketCorsInput{Bucket: aws.String(bucketName)})
+		if err != nil {
+			l.WithError(err).Error("Error removing s3 bucket CORS rules")
+			return fmt.Errorf(`removing CORS rules of s3 bucket "%s": %w`, bucketName, err)
+		}
+	} else {
+		rules := make([]*s3.CORSRule, 0, len(config.CORSRules))
+		for _, r := range config.CORSRules {
+			rules = append(rules, toS3CORSRule(r))
+		}
+		_, err := svc.PutBucketCorsWithContext(ctx, &s3.PutBucketCorsInput{
+			Bucket:            aws.String(bucketName),
+			CORSConfiguration: &s3.CORSConfiguration{CORSRules: rules},
+		})
+		if err != nil {
+			l.WithError(err).Error("Error setting s3 bucket CORS rules")
+			return fmt.Errorf(`setting CORS rules of s3 bucket "%s": %w`, bucketName, err)
+		}
+	}
+
+	if config.Website == nil {
+		_, err := svc.DeleteBucketWebsiteWithContext(ctx, &s3.DeleteBucketWebsiteInput{Bucket: aws.String(bucketName)})
+		if err != nil {
+			l.WithError(err).Error("Error disabling s3 bucket website")
+			return fmt.Errorf(`disabling website of s3 bucket "%s": %w`, bucketName, err)
+		}
+		return nil
+	}
+	website := &s3.WebsiteConfiguration{
+		IndexDocument: &s3.IndexDocument{Suffix: aws.String(config.Website.IndexDocument)},
+	}
+	if config.Website.ErrorDocument != "" {
+		website.ErrorDocument = &s3.ErrorDocument{Key: aws.String(config.Website.ErrorDocument)}
+	}
+	_, err := svc.PutBucketWebsiteWithContext(ctx, &s3.PutBucketWebsiteInput{
+		Bucket:               aws.String(bucketName),
+		WebsiteConfiguration: website,
+	})
+	if err != nil {
+		l.WithError(err).Error("Error setting s3 bucket website")
+		return fmt.Errorf(`setting website of s3 bucket "%s": %w`, bucketName, err)
+	}
+	return nil
+}
+
+func toS3LifecycleRule(r LifecycleRule) *s3.LifecycleRule {
+	rule := &s3.LifecycleRule{
+		ID:     aws.String(r.ID),
+		Filter: &s3.LifecycleRuleFilter{Prefix: aws.String(r.Prefix)},
+		Status: aws.String(s3.ExpirationStatusEnabled),
+	}
+	if r.Disabled {
+		rule.Status = aws.String(s3.ExpirationStatusDisabled)
+	}
+	if r.ExpirationDays > 0 {
+		rule.Expiration = &s3.LifecycleExpiration{Days: aws.Int64(r.ExpirationDays)}
+	}
+	if r.NoncurrentVersionExpirationDays > 0 {
+		rule.NoncurrentVersionExpiration = &s3.NoncurrentVersionExpiration{NoncurrentDays: aws.Int64(r.NoncurrentVersionExpirationDays)}
+	}
+	if r.AbortIncompleteMultipartUploadDays > 0 {
+		rule.AbortIncompleteMultipartUpload = &s3.AbortIncompleteMultipartUpload{DaysAfterInitiation: aws.Int64(r.AbortIncompleteMultipartUploadDays)}
+	}
+	for _, t := range r.Transitions {
+		rule.Transitions = append(rule.Transitions, &s3.Transition{Days: aws.Int64(t.Days), StorageClass: aws.String(t.StorageClass)})
+	}
+	return rule
+}
+
+func toS3CORSRule(r CORSRule) *s3.CORSRule {
+	rule := &s3.CORSRule{
+		AllowedOrigins: aws.StringSlice(r.AllowedOrigins),
+		AllowedMethods: aws.StringSlice(r.AllowedMethods),
+	}
+	if r.ID != "" {
+		rule.ID = aws.String(r.ID)
+	}
+	if len(r.AllowedHeaders) > 0 {
+		rule.AllowedHeaders = aws.StringSlice(r.AllowedHeaders)
+	}
+	if len(r.ExposeHeaders) > 0 {
+		rule.ExposeHeaders = aws.StringSlice(r.ExposeHeaders)
+	}
+	if r.MaxAgeSeconds > 0 {
+		rule.MaxAgeSeconds = aws.Int64(r.MaxAgeSeconds)
+	}
+	return rule
+}
@@ -0,0 +1,140 @@
+package aws
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/matryer/is"
+)
+
+func TestConfigureBucket(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, server := newTestClient(t, 1)
+	_, err := c.CreateBucket(ctx, "my-bucket", "my-resource")
+	is.NoErr(err)
+
+	err = c.ConfigureBucket(ctx, "my-bucket", BucketConfig{
+		Versioning: s3.BucketVersioningStatusEnabled,
+		LifecycleRules: []LifecycleRule{{
+			ID:                                 "tmp",
+			Prefix:                             "tmp/",
+			ExpirationDays:                     7,
+			NoncurrentVersionExpirationDays:    30,
+			AbortIncompleteMultipartUploadDays: 1,
+			Transitions:                        []LifecycleTransition{{Days: 3, StorageClass: s3.TransitionStorageClassGlacier}},
+		}},
+	})
+	is.NoErr(err)
+
+	bucket, _ := server.Bucket("my-bucket")
+	is.True(strings.Contains(bucket.Config["versioning"], "<Status>Enabled</Status>"))
+	lifecycle := bucket.Config["lifecycle"]
+	is.True(strings.Contains(lifecycle, "<ID>tmp</ID>"))
+	is.True(strings.Contains(lifecycle, "<Prefix>tmp/</Prefix>"))
+	is.True(strings.Contains(lifecycle, "<Status>Enabled</Status>"))
+	is.True(strings.Contains(lifecycle, "<Expiration><Days>7</Days></Expiration>"))
+	is.True(strings.Contains(lifecycle, "<NoncurrentDays>30</NoncurrentDays>"))
+	is.True(strings.Contains(lifecycle, "<DaysAfterInitiation>1</DaysAfterInitiation>"))
+	// The SDK does not write the elements of a structure in a fixed order.
+	is.True(strings.Contains(lifecycle, "<Days>3</Days>"))
+	is.True(strings.Contains(lifecycle, "<StorageClass>GLACIER</StorageClass>"))
+}
+
+func TestConfigureBucket_CORSAndWebsite(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, server := newTestClient(t, 1)
+	_, err := c.CreateBucket(ctx, "my-bucket", "my-resource")
+	is.NoErr(err)
+
+	err = c.ConfigureBucket(ctx, "my-bucket", BucketConfig{
+		CORSRules: []CORSRule{{
+			AllowedOrigins: []string{"https://app.example.com"},
+			AllowedMethods: []string{"GET", "PUT"},
+			AllowedHeaders: []string{"*"},
+			MaxAgeSeconds:  3000,
+		}},
+		Website: &BucketWebsite{IndexDocument: "index.html", ErrorDocument: "404.html"},
+	})
+	is.NoErr(err)
+
+	bucket, _ := server.Bucket("my-bucket")
+	cors := bucket.Config["cors"]
+	is.True(strings.Contains(cors, "<AllowedOrigin>https://app.example.com</AllowedOrigin>"))
+	is.True(strings.Contains(cors, "<AllowedMethod>GET</AllowedMethod><AllowedMethod>PUT</AllowedMethod>"))
+	is.True(strings.Contains(cors, "<AllowedHeader>*</AllowedHeader>"))
+	is.True(strings.Contains(cors, "<MaxAgeSeconds>3000</MaxAgeSeconds>"))
+	is.True(strings.Contains(bucket.Config["website"], "<IndexDocument><Suffix>index.html</Suffix></IndexDocument>"))
+	is.True(strings.Contains(bucket.Config["website"], "<ErrorDocument><Key>404.html</Key></ErrorDocument>"))
+
+	is.NoErr(c.ConfigureBucket(ctx, "my-bucket", BucketConfig{}))
+	bucket, _ = server.Bucket("my-bucket")
+	_, tagged := bucket.Config["tagging"]
+	is.True(tagged)
+	is.Equal(len(bucket.Config), 1) // CORS rules and website are removed
+}
+
+func TestWebsiteEndpoint(t *testing.T) {
+	for region, endpoint := range map[string]string{
+		"eu-west-1":    "http://my-bucket.s3-website-eu-west-1.amazonaws.com",
+		"eu-central-1": "http://my-bucket.s3-website.eu-central-1.amazonaws.com",
+		"cn-north-1":   "http://my-bucket.s3-website.cn-north-1.amazonaws.com.cn",
+	} {
+		t.Run(region, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(WebsiteEndpoint("my-bucket", region), endpoint)
+		})
+	}
+}
+
+func TestConfigureBucket_Reconfigure(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, server := newTestClient(t, 1)
+	_, err := c.CreateBucket(ctx, "my-bucket", "my-resource")
+	is.NoErr(err)
+	is.NoErr(c.ConfigureBucket(ctx, "my-bucket", BucketConfig{
+		Versioning:     s3.BucketVersioningStatusEnabled,
+		LifecycleRules: []LifecycleRule{{ID: "tmp", ExpirationDays: 7}},
+	}))
+
+	is.NoErr(c.ConfigureBucket(ctx, "my-bucket", BucketConfig{Versioning: s3.BucketVersioningStatusSuspended}))
+
+	bucket, _ := server.Bucket("my-bucket")
+	is.True(strings.Contains(bucket.Config["versioning"], "<Status>Suspended</Status>"))
+	_, hasLifecycle := bucket.Config["lifecycle"]
+	is.True(!hasLifecycle) // lifecycle rules are removed
+}
+
+func TestConfigureBucket_NoSuchBucket(t *testing.T) {
+	is := is.New(t)
+	c, _ := newTestClient(t, 1)
+
+	err := c.ConfigureBucket(context.Background(), "my-bucket", BucketConfig{Versioning: s3.BucketVersioningStatusEnabled})
+
+	is.Equal(errorCode(err), s3.ErrCodeNoSuchBucket)
+}
+
+func TestFakeConfigureBucket(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	a, _ := newTestFakeAccount(DefaultFakeConfig())
+	c, _ := a.New("key", "secret", "eu-west-1", DefaultWaitConfig())
+	config := BucketConfig{
+		Versioning:     s3.BucketVersioningStatusEnabled,
+		LifecycleRules: []LifecycleRule{{ID: "tmp", ExpirationDays: 7}},
+	}
+
+	is.Equal(errorCode(c.ConfigureBucket(ctx, "my-bucket", config)), s3.ErrCodeNoSuchBucket)
+
+	_, err := c.CreateBucket(ctx, "my-bucket", "my-resource")
+	is.NoErr(err)
+	is.NoErr(c.ConfigureBucket(ctx, "my-bucket", config))
+	is.Equal(a.buckets["my-bucket"].config, config)
+
+	is.NoErr(c.ConfigureBucket(ctx, "my-bucket", BucketConfig{}))
+	is.Equal(a.buckets["my-bucket"].config, BucketConfig{Versioning: s3.BucketVersioningStatusEnabled}) // versioning is left as it is
+}
@@ -0,0 +1,127 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"humanitec.io/resources/driver-aws-external/internal/logging"
+)
+
+// BucketSecurity describes the security settings applied to a bucket by SecureBucket. Zero values leave the
+// corresponding setting as it is.
+type BucketSecurity struct {
+	// SSEAlgorithm is the default server-side encryption: s3.ServerSideEncryptionAes256 (SSE-S3) or
+	// s3.ServerSideEncryptionAwsKms (SSE-KMS).
+	SSEAlgorithm string
+	// KMSKeyID is the key used for SSE-KMS. If empty, the AWS managed key for S3 is used.
+	KMSKeyID string
+	// BlockPublicAccess enables all four Block Public Access settings.
+	BlockPublicAccess bool
+	// ObjectOwnership is one of the s3.ObjectOwnership values, e.g. s3.ObjectOwnershipBucketOwnerEnforced.
+	ObjectOwnership string
+	// EnforceTLS sets a bucket policy denying requests that are not made over TLS.
+	EnforceTLS bool
+}
+
+// SecureBucket applies the security settings to an existing bucket. Each setting replaces any existing configuration
+// of the same kind, so it is safe to call again, e.g. when resuming interrupted provisioning.
+func (c awsClient) SecureBucket(ctx context.Context, bucketName string, security BucketSecurity) error {
+	l := logging.FromContext(ctx).WithField("bucket", bucketName)
+	svc := s3.New(c.sess)
+
+	if security.ObjectOwnership != "" {
+		_, err := svc.PutBucketOwnershipControlsWithContext(ctx, &s3.PutBucketOwnershipControlsInput{
+			Bucket: aws.String(bucketName),
+			OwnershipControls: &s3.OwnershipControls{
+				Rules: []*s3.OwnershipControlsRule{{ObjectOwnership: aws.String(security.ObjectOwnership)}},
+			},
+		})
+		if err != nil {
+			l.WithError(err).Error("Error setting s3 bucket object ownership")
+			return fmt.Errorf(`setting object ownership of s3 bucket "%s": %w`, bucketName, err)
+		}
+	}
+
+	if security.BlockPublicAccess {
+		_, err := svc.PutPublicAccessBlockWithContext(ctx, &s3.PutPublicAccessBlockInput{
+			Bucket: aws.String(bucketName),
+			PublicAccessBlockConfiguration: &s3.PublicAccessBlockConfiguration{
+				BlockPublicAcls:       aws.Bool(true),
+				BlockPublicPolicy:     aws.Bool(true),
+				IgnorePublicAcls:      aws.Bool(true),
+				RestrictPublicBuckets: aws.Bool(true),
+			},
+		})
+		if err != nil {
+			l.WithError(err).Error("Error blocking public access to s3 bucket")
+			return fmt.Errorf(`blocking public access to s3 bucket "%s": %w`, bucketName, err)
+		}
+	}
+
+	if security.SSEAlgorithm != "" {
+		rule := &s3.ServerSideEncryptionByDefault{SSEAlgorithm: aws.String(security.SSEAlgorithm)}
+		if security.KMSKeyID != "" {
+			rule.KMSMasterKeyID = aws.String(security.KMSKeyID)
+		}
+		_, err := svc.PutBucketEncryptionWithContext(ctx, &s3.PutBucketEncryptionInput{
+			Bucket: aws.String(bucketName),
+			ServerSideEncryptionConfiguration: &s3.ServerSideEncryptionConfiguration{
+				Rules: []*s3.ServerSideEncryptionRule{{
+					ApplyServerSideEncryptionByDefault: rule,
+					// S3 Bucket Keys reduce the number of requests made to KMS.
+					BucketKeyEnabled: aws.Bool(security.SSEAlgorithm == s3.ServerSideEncryptionAwsKms),
+				}},
+			},
+		})
+		if err != nil {
+			l.WithError(err).Error("Error setting s3 bucket encryption")
+			return fmt.Errorf(`setting encryption of s3 bucket "%s": %w`, bucketName, err)
+		}
+	}
+
+	if security.EnforceTLS {
+		policy, err := tlsOnlyPolicy(c.region, bucketName)
+		if err != nil {
+			return err
+		}
+		_, err = svc.PutBucketPolicyWithContext(ctx, &s3.PutBucketPolicyInput{
+			Bucket: aws.String(bucketName),
+			Policy: aws.String(policy),
+		})
+		if err != nil {
+			l.WithError(err).Error("Error setting s3 bucket policy")
+			return fmt.Errorf(`setting policy of s3 bucket "%s": %w`, bucketName, err)
+		}
+	}
+	return nil
+}
+
+// tlsOnlyPolicy returns a bucket policy denying all requests to the bucket that are not made over TLS.
+func tlsOnlyPolicy(region, bucketName string) (string, error) {
+	partition := "aws"
+	if p, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), region); ok {
+		partition = p.ID()
+	}
+	arn := fmt.Sprintf("arn:%s:s3:::%s", partition, bucketName)
+	policy, err := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{{
+			"Sid":       "DenyInsecureTransport",
+			"Effect":    "Deny",
+			"Principal": "*",
+			"Action":    "s3:*",
+			"Resource":  []string{arn, arn + "/*"},
+			"Condition": map[string]interface{}{
+				"Bool": map[string]string{"aws:SecureTransport": "false"},
+			},
+		}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("encoding bucket policy: %w", err)
+	}
+	return string(policy), nil
+}
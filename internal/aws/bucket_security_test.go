@@ -0,0 +1,93 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/matryer/is"
+)
+
+func TestSecureBucket(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, server := newTestClient(t, 1)
+	_, err := c.CreateBucket(ctx, "my-bucket", "my-resource")
+	is.NoErr(err)
+
+	err = c.SecureBucket(ctx, "my-bucket", BucketSecurity{
+		SSEAlgorithm:      s3.ServerSideEncryptionAwsKms,
+		KMSKeyID:          "arn:aws:kms:eu-west-1:111122223333:key/my-key",
+		BlockPublicAccess: true,
+		ObjectOwnership:   s3.ObjectOwnershipBucketOwnerEnforced,
+		EnforceTLS:        true,
+	})
+	is.NoErr(err)
+
+	bucket, _ := server.Bucket("my-bucket")
+	is.True(strings.Contains(bucket.Config["encryption"], "<SSEAlgorithm>aws:kms</SSEAlgorithm>"))
+	is.True(strings.Contains(bucket.Config["encryption"], "<KMSMasterKeyID>arn:aws:kms:eu-west-1:111122223333:key/my-key</KMSMasterKeyID>"))
+	is.True(strings.Contains(bucket.Config["encryption"], "<BucketKeyEnabled>true</BucketKeyEnabled>"))
+	is.True(strings.Contains(bucket.Config["publicAccessBlock"], "<BlockPublicPolicy>true</BlockPublicPolicy>"))
+	is.True(strings.Contains(bucket.Config["ownershipControls"], "<ObjectOwnership>BucketOwnerEnforced</ObjectOwnership>"))
+
+	var policy struct {
+		Statement []struct {
+			Effect    string
+			Resource  []string
+			Condition map[string]map[string]string
+		}
+	}
+	is.NoErr(json.Unmarshal([]byte(bucket.Config["policy"]), &policy))
+	is.Equal(policy.Statement[0].Effect, "Deny")
+	is.Equal(policy.Statement[0].Resource, []string{"arn:aws:s3:::my-bucket", "arn:aws:s3:::my-bucket/*"})
+	is.Equal(policy.Statement[0].Condition["Bool"]["aws:SecureTransport"], "false")
+}
+
+func TestSecureBucket_Nothing(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, server := newTestClient(t, 1)
+	_, err := c.CreateBucket(ctx, "my-bucket", "my-resource")
+	is.NoErr(err)
+
+	is.NoErr(c.SecureBucket(ctx, "my-bucket", BucketSecurity{}))
+
+	bucket, _ := server.Bucket("my-bucket")
+	_, tagged := bucket.Config["tagging"]
+	is.True(tagged)
+	is.Equal(len(bucket.Config), 1) // nothing is configured but the owner tag
+}
+
+func TestSecureBucket_NoSuchBucket(t *testing.T) {
+	is := is.New(t)
+	c, _ := newTestClient(t, 1)
+
+	err := c.SecureBucket(context.Background(), "my-bucket", BucketSecurity{SSEAlgorithm: s3.ServerSideEncryptionAes256})
+
+	is.Equal(errorCode(err), s3.ErrCodeNoSuchBucket)
+}
+
+func TestTLSOnlyPolicy_Partition(t *testing.T) {
+	is := is.New(t)
+	policy, err := tlsOnlyPolicy("cn-north-1", "my-bucket")
+	is.NoErr(err)
+	is.True(strings.Contains(policy, `"arn:aws-cn:s3:::my-bucket"`))
+}
+
+func TestFakeSecureBucket(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	a, _ := newTestFakeAccount(DefaultFakeConfig())
+	c, _ := a.New("key", "secret", "eu-west-1", DefaultWaitConfig())
+	security := BucketSecurity{SSEAlgorithm: s3.ServerSideEncryptionAes256, EnforceTLS: true}
+
+	is.Equal(errorCode(c.SecureBucket(ctx, "my-bucket", security)), s3.ErrCodeNoSuchBucket)
+
+	_, err := c.CreateBucket(ctx, "my-bucket", "my-resource")
+	is.NoErr(err)
+	is.NoErr(c.SecureBucket(ctx, "my-bucket", security))
+	is.Equal(a.buckets["my-bucket"].security, security)
+}
@@ -3,50 +3,112 @@
 package aws
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
-	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/elasticache"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"go.opentelemetry.io/otel/trace"
+	"humanitec.io/resources/driver-aws-external/internal/logging"
 )
 
+// Client provisions AWS resources. Methods stop waiting for AWS and return an error once the context passed to them is
+// cancelled or its deadline passes. The context also carries the request-scoped logger and span.
 type Client interface {
-	CreateBucket(bucketName string) (string, error)
-	DeleteBucket(bucketName string) error
-	CreateElastiCacheRedis(clusterId string, cacheNodeType string, cacheAz string) (string, error)
-	DeleteElastiCacheRedis(clusterId string) error
+	// CreateBucket creates a bucket for the resource with the supplied ID in the client's region, tags it with OwnerTag
+	// and returns the region. If the account already owns a bucket with the name that was created for the same
+	// resource, it is adopted. Other existing buckets are not adopted; an error wrapping ErrNotOwned is returned
+	// instead.
+	CreateBucket(ctx context.Context, bucketName, resourceID string) (string, error)
+	DeleteBucket(ctx context.Context, bucketName string) error
+	// SecureBucket applies security settings to a bucket that has already been created.
+	SecureBucket(ctx context.Context, bucketName string, security BucketSecurity) error
+	// ConfigureBucket applies versioning, lifecycle rules, CORS rules and website hosting to a bucket that has already
+	// been created.
+	ConfigureBucket(ctx context.Context, bucketName string, config BucketConfig) error
+	// CreateElastiCacheRedis creates a cluster for the resource with the supplied ID, tagged with OwnerTag, waits for it
+	// to become available and returns its host. If a cluster with the id already exists that was created for the same
+	// resource, it is adopted as it is and waited for instead. Other existing clusters are not adopted; an error
+	// wrapping ErrNotOwned is returned instead.
+	CreateElastiCacheRedis(ctx context.Context, clusterId, resourceID, cacheNodeType, cacheAz string) (string, error)
+	// DeleteElastiCacheRedis deletes a cluster. If finalSnapshotId is not empty, a snapshot with that name is taken of the
+	// cluster before it is deleted.
+	DeleteElastiCacheRedis(ctx context.Context, clusterId, finalSnapshotId string) error
+	// WaitForElastiCacheRedisDeleted waits for a cluster whose deletion has been requested to be removed, i.e. until
+	// describing it fails with CacheClusterNotFound. If the cluster is available again, an error wrapping
+	// ErrDeletionAbandoned is returned instead.
+	WaitForElastiCacheRedisDeleted(ctx context.Context, clusterId string) error
+	// WaitForElastiCacheRedis waits for a cluster that has already been created to become available and returns its
+	// host, like CreateElastiCacheRedis.
+	WaitForElastiCacheRedis(ctx context.Context, clusterId string) (string, error)
+	// BucketRegion returns the region an existing bucket is in.
+	BucketRegion(ctx context.Context, bucketName string) (string, error)
+	// DescribeBucket returns the configuration of an existing bucket. The client must be in the bucket's region.
+	DescribeBucket(ctx context.Context, bucketName string) (Bucket, error)
+	// DescribeElastiCacheRedis returns the state and configuration of an existing cluster.
+	DescribeElastiCacheRedis(ctx context.Context, clusterId string) (CacheCluster, error)
+	// TagBucket adds tags to a bucket, keeping any other tags it already has.
+	TagBucket(ctx context.Context, bucketName string, tags map[string]string) error
+	// TagElastiCacheRedis adds tags to a cluster, keeping any other tags it already has.
+	TagElastiCacheRedis(ctx context.Context, clusterId string, tags map[string]string) error
 }
 
 type awsClient struct {
-	sess         *session.Session
-	region       string
-	timeoutLimit int
+	sess   *session.Session
+	region string
+	wait   WaitConfig
 }
 
-func New(accessKeyId, secretAccessKey, region string, timeoutLimit int) (Client, error) {
-	creds := credentials.NewStaticCredentials(accessKeyId, secretAccessKey, "")
-	sess, err := session.NewSession(&aws.Config{
-		Region:      &region,
-		Credentials: creds,
-	})
-	if err != nil {
-		log.Printf(`Error creating AWS Session: %v`, err)
-		return nil, fmt.Errorf(`creating aws session: %w`, err)
-	}
-	return awsClient{
-		sess:         sess,
-		region:       region,
-		timeoutLimit: timeoutLimit,
-	}, nil
+// New creates a Client that talks to AWS.
+func New(accessKeyId, secretAccessKey, region string, wait WaitConfig) (Client, error) {
+	return NewWithEndpoint("")(accessKeyId, secretAccessKey, region, wait)
 }
 
-func (c awsClient) CreateBucket(bucketName string) (string, error) {
+// NewWithEndpoint returns a function like New that creates Clients sending all requests to the supplied endpoint
+// instead of AWS. (e.g. a localaws.Server.) If endpoint is empty, the default AWS endpoints are used.
+func NewWithEndpoint(endpoint string) func(accessKeyId, secretAccessKey, region string, wait WaitConfig) (Client, error) {
+	return func(accessKeyId, secretAccessKey, region string, wait WaitConfig) (Client, error) {
+		creds := credentials.NewStaticCredentials(accessKeyId, secretAccessKey, "")
+		cfg := &aws.Config{
+			Region:      &region,
+			Credentials: creds,
+		}
+		if endpoint != "" {
+			cfg.Endpoint = aws.String(endpoint)
+			// Custom endpoints will not resolve virtual-hosted bucket names.
+			cfg.S3ForcePathStyle = aws.Bool(true)
+		}
+		sess, err := session.NewSession(cfg)
+		if err != nil {
+			logging.Base().WithError(err).Error("Error creating AWS Session")
+			return nil, fmt.Errorf(`creating aws session: %w`, err)
+		}
+		sess.Handlers.Validate.PushFrontNamed(startSpanHandler)
+		sess.Handlers.Complete.PushBackNamed(metricsHandler)
+		sess.Handlers.Complete.PushBackNamed(endSpanHandler)
+		return tracedClient{awsClient{
+			sess:   sess,
+			region: region,
+			wait:   wait,
+		}}, nil
+	}
+}
+
+// detached returns a context for calls that must be made even if ctx is cancelled meanwhile. It carries the logger and
+// span of ctx, and is limited to the attempt timeout instead.
+func (c awsClient) detached(ctx context.Context) (context.Context, context.CancelFunc) {
+	d := trace.ContextWithSpan(logging.NewContext(context.Background(), logging.FromContext(ctx)), trace.SpanFromContext(ctx))
+	return context.WithTimeout(d, c.wait.AttemptTimeout)
+}
+
+func (c awsClient) CreateBucket(ctx context.Context, bucketName, resourceID string) (string, error) {
+	l := logging.FromContext(ctx).WithField("bucket", bucketName)
 	input := &s3.CreateBucketInput{
 		Bucket: aws.String(bucketName),
 		CreateBucketConfiguration: &s3.CreateBucketConfiguration{
@@ -54,41 +116,64 @@ func (c awsClient) CreateBucket(bucketName string) (string, error) {
 		},
 	}
 	svc := s3.New(c.sess)
-	bucketResult, err := svc.CreateBucket(input)
+	_, err := svc.CreateBucketWithContext(ctx, input)
 	if err != nil {
 		var aerr awserr.Error
 		if errors.As(err, &aerr) {
 			switch aerr.Code() {
 			case s3.ErrCodeBucketAlreadyExists:
-				log.Printf(`Attempted to create s3 bucket that already exists: "%s"`, bucketName)
+				l.Warn("Attempted to create s3 bucket that already exists")
 				return "", fmt.Errorf(`s3 bucket name already exists "%s": %w`, bucketName, aerr)
 			case s3.ErrCodeBucketAlreadyOwnedByYou:
-				log.Printf(`Attempted to create s3 bucket that already exists: "%s"`, bucketName)
-				return "", fmt.Errorf(`s3 bucket name already exists "%s": %w`, bucketName, aerr)
+				// Names are derived from the resource, so this may be a retry of an earlier request that created the
+				// bucket. It may as well be a bucket created for another resource, or retained when the resource was
+				// deleted before.
+				tags, err := c.bucketTags(ctx, bucketName)
+				if err != nil {
+					return "", err
+				}
+				if !ownedBy(tags, resourceID) {
+					l.WithField("tags", tags).Warn("S3 bucket already exists in this account for another resource.")
+					return "", fmt.Errorf(`s3 bucket "%s" %w`, bucketName, ErrNotOwned)
+				}
+				l.Info("S3 bucket already exists in this account. Adopting it.")
+				return c.region, nil
 			}
 		}
-		log.Printf(`Error creating s3 bucket "%s": %v`, bucketName, err)
+		l.WithError(err).Error("Error creating s3 bucket")
 		return "", fmt.Errorf(`creating s3 bucket "%s": %w`, bucketName, err)
 	}
-	return *bucketResult.Location, nil
+
+	// NOTE: S3 cannot tag buckets as they are created. The tag is added even if ctx is cancelled meanwhile, as the bucket
+	// could not be adopted when the request is retried without it. Should the driver stop before it is added, creating
+	// the resource again fails with a conflict until the bucket is tagged by hand.
+	tagCtx, cancel := c.detached(ctx)
+	defer cancel()
+	if err := c.TagBucket(tagCtx, bucketName, map[string]string{OwnerTag: resourceID}); err != nil {
+		return "", err
+	}
+	// The location S3 returns is the bucket's path or URL, not its region.
+	return c.region, nil
 }
 
-func (c awsClient) DeleteBucket(bucketName string) error {
+func (c awsClient) DeleteBucket(ctx context.Context, bucketName string) error {
+	l := logging.FromContext(ctx).WithField("bucket", bucketName)
 	// NOTE: This is not a full implementation. Buckets need to be empty before they can be deleted.
 	// See https://docs.aws.amazon.com/AmazonS3/latest/dev/delete-or-empty-bucket.html#delete-bucket-awssdks
 	input := &s3.DeleteBucketInput{
 		Bucket: aws.String(bucketName),
 	}
 	svc := s3.New(c.sess)
-	_, err := svc.DeleteBucket(input)
+	_, err := svc.DeleteBucketWithContext(ctx, input)
 	if err != nil {
-		log.Printf(`Error creating s3 bucket "%s": %v`, bucketName, err)
-		return fmt.Errorf(`creating s3 bucket "%s": %w`, bucketName, err)
+		l.WithError(err).Error("Error deleting s3 bucket")
+		return fmt.Errorf(`deleting s3 bucket "%s": %w`, bucketName, err)
 	}
 	return nil
 }
 
-func (c awsClient) CreateElastiCacheRedis(clusterId string, cacheNodeType string, cacheAz string) (string, error) {
+func (c awsClient) CreateElastiCacheRedis(ctx context.Context, clusterId, resourceID, cacheNodeType, cacheAz string) (string, error) {
+	l := logging.FromContext(ctx).WithField("cluster_id", clusterId)
 	input := &elasticache.CreateCacheClusterInput{
 		AutoMinorVersionUpgrade:   aws.Bool(true),
 		CacheClusterId:            aws.String(clusterId),
@@ -100,125 +185,191 @@ func (c awsClient) CreateElastiCacheRedis(clusterId string, cacheNodeType string
 		Port:                      aws.Int64(6379),
 		PreferredAvailabilityZone: aws.String(cacheAz),
 		SnapshotRetentionLimit:    aws.Int64(7),
+		Tags:                      []*elasticache.Tag{{Key: aws.String(OwnerTag), Value: aws.String(resourceID)}},
 	}
 
 	svc := elasticache.New(c.sess)
-	_, err := svc.CreateCacheCluster(input)
+	_, err := svc.CreateCacheClusterWithContext(ctx, input)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
 			switch aerr.Code() {
 			case elasticache.ErrCodeReplicationGroupNotFoundFault:
-				log.Printf(`Replication group not found`)
+				l.Error("Replication group not found")
 				return "", fmt.Errorf(`Replication group not found`)
 			case elasticache.ErrCodeInvalidReplicationGroupStateFault:
-				log.Printf(`Invalid replication group state`)
+				l.Error("Invalid replication group state")
 				return "", fmt.Errorf(`Invalid replication group state`)
 			case elasticache.ErrCodeCacheClusterAlreadyExistsFault:
-				log.Printf(`Cache cluster already exists`)
-				return "", fmt.Errorf(`Cache cluster already exists`)
+				// Names are derived from the resource, so this may be a retry of an earlier request that created the
+				// cluster. It may as well be a cluster created for another resource, or retained when the resource was
+				// deleted before.
+				cluster, err := c.DescribeElastiCacheRedis(ctx, clusterId)
+				if err != nil {
+					return "", err
+				}
+				if !ownedBy(cluster.Tags, resourceID) {
+					l.WithField("tags", cluster.Tags).Warn("Cache cluster already exists for another resource.")
+					return "", fmt.Errorf(`Elasticache cluster "%s" %w`, clusterId, ErrNotOwned)
+				}
+				l.Info("Cache cluster already exists. Adopting it.")
+				return c.WaitForElastiCacheRedis(ctx, clusterId)
 			case elasticache.ErrCodeInsufficientCacheClusterCapacityFault:
-				log.Printf(`Insufficient cache cluster capacity`)
+				l.Error("Insufficient cache cluster capacity")
 				return "", fmt.Errorf(`Insufficient cache cluster capacity`)
 			case elasticache.ErrCodeCacheSecurityGroupNotFoundFault:
-				log.Printf(`Cache security group not found`)
+				l.Error("Cache security group not found")
 				return "", fmt.Errorf(`Cache security group not found`)
 			case elasticache.ErrCodeCacheSubnetGroupNotFoundFault:
-				log.Printf(`Subnet group not found`)
+				l.Error("Subnet group not found")
 				return "", fmt.Errorf(`Subnet group not found`)
 			case elasticache.ErrCodeClusterQuotaForCustomerExceededFault:
-				log.Printf(`Cluster quota for customer exceeded`)
+				l.Error("Cluster quota for customer exceeded")
 				return "", fmt.Errorf(`Cluster quota for customer exceeded`)
 			case elasticache.ErrCodeNodeQuotaForClusterExceededFault:
-				log.Printf(`Quota for cluster exceeded`)
+				l.Error("Quota for cluster exceeded")
 				return "", fmt.Errorf(`Quota for cluster exceeded`)
 			case elasticache.ErrCodeNodeQuotaForCustomerExceededFault:
-				log.Printf(`Node quota for customer exceeded`)
+				l.Error("Node quota for customer exceeded")
 				return "", fmt.Errorf(`Node quota for customer exceeded`)
 			case elasticache.ErrCodeCacheParameterGroupNotFoundFault:
-				log.Printf(`Cache parameter group not found`)
+				l.Error("Cache parameter group not found")
 				return "", fmt.Errorf(`Cache parameter group not found`)
 			case elasticache.ErrCodeInvalidVPCNetworkStateFault:
-				log.Printf(`Invalid VPC network state`)
+				l.Error("Invalid VPC network state")
 				return "", fmt.Errorf(`Invalid VPC network state`)
 			case elasticache.ErrCodeTagQuotaPerResourceExceeded:
-				log.Printf(`Tag quota per resource exceeded`)
+				l.Error("Tag quota per resource exceeded")
 				return "", fmt.Errorf(`Tag quota per resource exceeded`)
 			case elasticache.ErrCodeInvalidParameterValueException:
-				log.Printf(`Invalid parameter value exception`)
+				l.Error("Invalid parameter value exception")
 				return "", fmt.Errorf(`Invalid parameter value exception`)
 			case elasticache.ErrCodeInvalidParameterCombinationException:
-				log.Printf(`Invalid parameter combination`)
+				l.Error("Invalid parameter combination")
 				return "", fmt.Errorf(`Invalid parameter combination`)
-			default:
-				fmt.Println(aerr.Error())
 			}
 		}
-		log.Printf(`Error creating Elasticache cluster "%s": %v`, clusterId, err)
+		l.WithError(err).Error("Error creating Elasticache cluster")
 		return "", fmt.Errorf(`creating Elasticache cluster "%s": %w`, clusterId, err)
 	}
-	log.Printf("Cluster %s created. Retrieving Hostname.", clusterId)
+	l.Info("Cluster created.")
+	return c.WaitForElastiCacheRedis(ctx, clusterId)
+}
+
+// redisUnavailableStatuses are the statuses of clusters that will not become available.
+var redisUnavailableStatuses = []string{"create-failed", "incompatible-network", "restore-failed", "deleting", "deleted"}
+
+func (c awsClient) WaitForElastiCacheRedis(ctx context.Context, clusterId string) (string, error) {
+	l := logging.FromContext(ctx).WithField("cluster_id", clusterId)
+	l.Info("Waiting for cluster to become available.")
+	svc := elasticache.New(c.sess)
 	dcci := &elasticache.DescribeCacheClustersInput{
 		CacheClusterId:    aws.String(clusterId),
 		ShowCacheNodeInfo: aws.Bool(true),
 	}
-	available := false
-	timeoutCount := c.timeoutLimit / 10
-
-	var dcco *elasticache.DescribeCacheClustersOutput
-	for !available {
-		time.Sleep(10 * time.Second)
-		timeoutCount = timeoutCount - 1
-		var err error
-		log.Printf(`Calling svc.DescribeCacheClusters({CacheClusterId: "%s", ShowCacheNodeInfo: true})`, clusterId)
-		dcco, err = svc.DescribeCacheClusters(dcci)
+	w := waiter{
+		waitConfig: c.wait.waiting(c.wait.CreateTimeout),
+		name:       fmt.Sprintf(`cluster "%s" to become available`, clusterId),
+		terminal:   redisUnavailableStatuses,
+	}
+	var cluster *elasticache.CacheCluster
+	err := w.wait(ctx, func(ctx context.Context) (string, bool, error) {
+		dcco, err := svc.DescribeCacheClustersWithContext(ctx, dcci, countPollIterations)
 		if err != nil {
-			log.Printf(`Error describing Elasticache cluster "%s": %v`, clusterId, err)
-			return "", fmt.Errorf(`describing Elasticache cluster "%s": %w`, clusterId, err)
+			return "", false, err
 		}
-		if len(dcco.CacheClusters) > 0 {
-			if *(dcco.CacheClusters[0].CacheClusterStatus) == "available" {
-				if len(dcco.CacheClusters[0].CacheNodes) > 0 {
-					log.Printf("len(dcco.CacheClusters[0].CacheNodes) = %d", len(dcco.CacheClusters[0].CacheNodes))
-					if *(dcco.CacheClusters[0].CacheNodes[0].CacheNodeStatus) == "available" {
-						fmt.Printf("*(dcco.CacheClusters[0].CacheNodes[0].CacheNodeStatus) = %s", *(dcco.CacheClusters[0].CacheNodes[0].CacheNodeStatus))
-						if dcco.CacheClusters[0].CacheNodes[0].Endpoint != nil {
-							fmt.Printf("dcco.CacheClusters[0].CacheNodes[0].Endpoint = %T", dcco.CacheClusters[0].CacheNodes[0].Endpoint)
-							if dcco.CacheClusters[0].CacheNodes[0].Endpoint.Address != nil {
-								fmt.Printf("dcco.CacheClusters[0].CacheNodes[0].Endpoint.Address = %s", *(dcco.CacheClusters[0].CacheNodes[0].Endpoint.Address))
-								available = true
-							} else {
-								log.Printf("dcco.CacheClusters[0].CacheNodes[0].Endpoint.Address == nil")
-							}
-						} else {
-							log.Printf("dcco.CacheClusters[0].CacheNodes[0].Endpoint == nil")
-						}
-					} else {
-						log.Printf("dcco.CacheClusters[0].CacheNodes[0].CacheNodeStatus != available")
-					}
-				} else {
-					log.Printf("len(dcco.CacheClusters[0].CacheNodes) == 0")
-				}
-			}
+		if len(dcco.CacheClusters) == 0 {
+			return "", false, nil
 		}
-		if timeoutCount <= 0 {
-			return "", fmt.Errorf(`fetching endpoint failed. cluster "%s" not available after %d seconds: %w`, clusterId, c.timeoutLimit, err)
+		cluster = dcco.CacheClusters[0]
+		status := aws.StringValue(cluster.CacheClusterStatus)
+		return status, status == "available", nil
+	})
+	if err != nil {
+		var aerr awserr.Error
+		var terr *terminalStatusError
+		switch {
+		case errors.As(err, &aerr) && aerr.Code() == request.WaiterResourceNotReadyErrorCode:
+			l.WithError(err).Error("Elasticache cluster did not become available")
+			return "", fmt.Errorf(`fetching endpoint failed. cluster "%s" not available after %v: %w`, clusterId, c.wait.CreateTimeout, err)
+		case errors.As(err, &terr):
+			l.WithField("status", terr.status).Error("Elasticache cluster will not become available")
+			return "", fmt.Errorf(`fetching endpoint failed. cluster "%s" is %s: %w`, clusterId, terr.status, err)
 		}
+		l.WithError(err).Error("Error waiting for Elasticache cluster to become available")
+		return "", fmt.Errorf(`waiting for Elasticache cluster "%s": %w`, clusterId, err)
+	}
+
+	if len(cluster.CacheNodes) == 0 || cluster.CacheNodes[0].Endpoint == nil || cluster.CacheNodes[0].Endpoint.Address == nil {
+		l.Error("Elasticache cluster is available but has no endpoint")
+		return "", fmt.Errorf(`fetching endpoint failed. cluster "%s" has no endpoint`, clusterId)
 	}
-	log.Printf("Endpoint retrieved: Address: %s", *(dcco.CacheClusters[0].CacheNodes[0].Endpoint.Address))
-	return *(dcco.CacheClusters[0].CacheNodes[0].Endpoint.Address), nil
+	address := aws.StringValue(cluster.CacheNodes[0].Endpoint.Address)
+	l.WithField("address", address).Info("Endpoint retrieved")
+	return address, nil
 }
 
-func (c awsClient) DeleteElastiCacheRedis(clusterId string) error {
+func (c awsClient) DeleteElastiCacheRedis(ctx context.Context, clusterId, finalSnapshotId string) error {
+	l := logging.FromContext(ctx).WithField("cluster_id", clusterId)
 	input := &elasticache.DeleteCacheClusterInput{
 		CacheClusterId: aws.String(clusterId),
 	}
+	if finalSnapshotId != "" {
+		input.FinalSnapshotIdentifier = aws.String(finalSnapshotId)
+	}
 
 	svc := elasticache.New(c.sess)
 
-	_, err := svc.DeleteCacheCluster(input)
+	_, err := svc.DeleteCacheClusterWithContext(ctx, input)
+	if err != nil {
+		l.WithError(err).Error("Error deleting elasticache redis cluster")
+		return fmt.Errorf(`deleting elasticache redis cluster "%s": %w`, clusterId, err)
+	}
+	return nil
+}
+
+// ErrDeletionAbandoned is returned when waiting for the deletion of a cluster that is available again, e.g. because
+// taking its final snapshot failed. AWS will not delete it unless its deletion is requested again.
+var ErrDeletionAbandoned = errors.New("is available again and will not be deleted")
+
+func (c awsClient) WaitForElastiCacheRedisDeleted(ctx context.Context, clusterId string) error {
+	l := logging.FromContext(ctx).WithField("cluster_id", clusterId)
+	l.Info("Waiting for cluster to be deleted.")
+	svc := elasticache.New(c.sess)
+	dcci := &elasticache.DescribeCacheClustersInput{
+		CacheClusterId: aws.String(clusterId),
+	}
+	// Keeps waiting while a final snapshot is taken and only succeeds once the cluster is gone, not when it is reported
+	// as "deleted". Clusters that are available again will not be deleted, e.g. because the final snapshot failed.
+	w := waiter{
+		waitConfig: c.wait.waiting(c.wait.DeleteTimeout),
+		name:       fmt.Sprintf(`cluster "%s" to be deleted`, clusterId),
+		terminal:   []string{"available"},
+	}
+	err := w.wait(ctx, func(ctx context.Context) (string, bool, error) {
+		dcco, err := svc.DescribeCacheClustersWithContext(ctx, dcci, countPollIterations)
+		var aerr awserr.Error
+		if errors.As(err, &aerr) && aerr.Code() == elasticache.ErrCodeCacheClusterNotFoundFault {
+			return "", true, nil
+		}
+		if err != nil || len(dcco.CacheClusters) == 0 {
+			return "", false, err
+		}
+		return aws.StringValue(dcco.CacheClusters[0].CacheClusterStatus), false, nil
+	})
 	if err != nil {
-		log.Printf(`Error deleting elasticache redis cluster "%s": %v`, clusterId, err)
-		return fmt.Errorf(`deleting elasticache redis cluster "%s": %v`, clusterId, err)
+		var aerr awserr.Error
+		var terr *terminalStatusError
+		switch {
+		case errors.As(err, &aerr) && aerr.Code() == request.WaiterResourceNotReadyErrorCode:
+			l.WithError(err).Warn("Elasticache cluster was not deleted in time")
+			return fmt.Errorf(`cluster "%s" not deleted after %v: %w`, clusterId, c.wait.DeleteTimeout, err)
+		case errors.As(err, &terr):
+			l.WithField("status", terr.status).Warn("Elasticache cluster is not being deleted any more")
+			return fmt.Errorf(`cluster "%s" %w`, clusterId, ErrDeletionAbandoned)
+		}
+		l.WithError(err).Error("Error waiting for Elasticache cluster to be deleted")
+		return fmt.Errorf(`waiting for deletion of Elasticache cluster "%s": %w`, clusterId, err)
 	}
+	l.Info("Cluster deleted.")
 	return nil
 }
@@ -0,0 +1,367 @@
+package aws
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/matryer/is"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"humanitec.io/resources/driver-aws-external/internal/aws/localaws"
+	"humanitec.io/resources/driver-aws-external/internal/metrics"
+)
+
+// newTestClient creates an awsClient that talks to a fresh localaws.Server and gives up waiting after timeout seconds.
+func newTestClient(t *testing.T, timeout int) (awsClient, *localaws.Server) {
+	server := localaws.NewServer()
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+
+	wait := DefaultWaitConfig()
+	wait.CreateTimeout = time.Duration(timeout) * time.Second
+	wait.DeleteTimeout = time.Duration(timeout) * time.Second
+	wait.MinDelay = 5 * time.Millisecond
+	wait.MaxDelay = 20 * time.Millisecond
+	client, err := NewWithEndpoint(ts.URL)("key", "secret", "eu-west-1", wait)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client.(tracedClient).next.(awsClient), server
+}
+
+func TestCreateBucket(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, server := newTestClient(t, 1)
+
+	region, err := c.CreateBucket(ctx, "my-bucket", "my-resource")
+
+	is.NoErr(err)
+	is.Equal(region, "eu-west-1") // the region, as when the bucket is adopted, not the location S3 returns
+	bucket, exists := server.Bucket("my-bucket")
+	is.True(exists)
+	is.Equal(bucket.LocationConstraint, "eu-west-1")
+	is.Equal(bucketTagging(t, bucket), map[string]string{"humanitec:resource-id": "my-resource"})
+}
+
+// bucketTagging returns the tags set on a localaws bucket.
+func bucketTagging(t *testing.T, bucket localaws.Bucket) map[string]string {
+	var tagging struct {
+		Tags []struct {
+			Key   string
+			Value string
+		} `xml:"TagSet>Tag"`
+	}
+	if err := xml.Unmarshal([]byte(bucket.Config["tagging"]), &tagging); err != nil {
+		t.Fatal(err)
+	}
+	tags := map[string]string{}
+	for _, tag := range tagging.Tags {
+		tags[tag.Key] = tag.Value
+	}
+	return tags
+}
+
+func TestCreateBucket_AlreadyExists(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, _ := newTestClient(t, 1)
+	calls := metrics.AWSAPICalls.WithLabelValues("s3", "CreateBucket")
+	errs := metrics.AWSAPIErrors.WithLabelValues("s3", "CreateBucket", s3.ErrCodeBucketAlreadyOwnedByYou)
+	callsBefore, errsBefore := testutil.ToFloat64(calls), testutil.ToFloat64(errs)
+
+	_, err := c.CreateBucket(ctx, "my-bucket", "my-resource")
+	is.NoErr(err)
+	region, err := c.CreateBucket(ctx, "my-bucket", "my-resource")
+
+	is.NoErr(err) // the bucket is adopted
+	is.Equal(region, "eu-west-1")
+	is.Equal(testutil.ToFloat64(calls), callsBefore+2)
+	is.Equal(testutil.ToFloat64(errs), errsBefore+1)
+}
+
+func TestCreateBucket_NotOwned(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, _ := newTestClient(t, 1)
+	_, err := c.CreateBucket(ctx, "my-bucket", "my-resource")
+	is.NoErr(err)
+
+	_, err = c.CreateBucket(ctx, "my-bucket", "other-resource")
+	is.True(errors.Is(err, ErrNotOwned)) // buckets of other resources are not adopted
+
+	is.NoErr(c.TagBucket(ctx, "my-bucket", map[string]string{RetainedTag: "my-resource"}))
+	_, err = c.CreateBucket(ctx, "my-bucket", "my-resource")
+	is.True(errors.Is(err, ErrNotOwned)) // nor are retained buckets
+
+	_, err = s3.New(c.sess).CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("untagged-bucket")})
+	is.NoErr(err)
+	_, err = c.CreateBucket(ctx, "untagged-bucket", "my-resource")
+	is.True(errors.Is(err, ErrNotOwned)) // nor are buckets created otherwise
+}
+
+func TestDeleteBucket(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, server := newTestClient(t, 1)
+	_, err := c.CreateBucket(ctx, "my-bucket", "my-resource")
+	is.NoErr(err)
+
+	is.NoErr(c.DeleteBucket(ctx, "my-bucket"))
+
+	_, exists := server.Bucket("my-bucket")
+	is.True(!exists)
+	is.Equal(errorCode(c.DeleteBucket(ctx, "my-bucket")), s3.ErrCodeNoSuchBucket)
+}
+
+func TestCreateElastiCacheRedis(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, server := newTestClient(t, 1)
+	server.ClusterCreateDelay = 50 * time.Millisecond
+	polls := metrics.ElastiCachePollIterations.WithLabelValues("available")
+	pollsBefore := testutil.ToFloat64(polls)
+
+	host, err := c.CreateElastiCacheRedis(ctx, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+
+	is.NoErr(err)
+	is.Equal(testutil.ToFloat64(polls), pollsBefore+1)
+	is.Equal(host, "redis-1.local.0001.cache.localhost")
+	cluster, exists := server.CacheCluster("redis-1")
+	is.True(exists)
+	is.Equal(cluster.CacheClusterStatus, "available")
+	is.Equal(cluster.CacheNodeType, "cache.t3.micro")
+	is.Equal(cluster.PreferredAvailabilityZone, "eu-west-1a")
+	is.Equal(cluster.Engine, "redis")
+	is.Equal(cluster.EngineVersion, "5.0.6")
+	is.Equal(cluster.NumCacheNodes, 1)
+	is.Equal(cluster.Port, 6379)
+	is.Equal(cluster.CacheSubnetGroupName, "default")
+	is.Equal(cluster.SnapshotRetentionLimit, 7)
+	is.True(cluster.AutoMinorVersionUpgrade)
+}
+
+func TestCreateElastiCacheRedis_Timeout(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, server := newTestClient(t, 1)
+	server.ClusterCreateDelay = time.Hour
+
+	_, err := c.CreateElastiCacheRedis(ctx, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+
+	is.True(err != nil)
+	cluster, _ := server.CacheCluster("redis-1")
+	is.Equal(cluster.CacheClusterStatus, "creating")
+}
+
+func TestWaitForElastiCacheRedis_Timeout(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, server := newTestClient(t, 1)
+	server.ClusterCreateDelay = time.Hour
+	_, err := c.CreateElastiCacheRedis(ctx, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+	is.True(err != nil)
+
+	_, err = c.WaitForElastiCacheRedis(ctx, "redis-1")
+
+	is.Equal(errorCode(err), request.WaiterResourceNotReadyErrorCode)
+	is.True(strings.Contains(err.Error(), `status is "creating"`)) // reports what the cluster was doing
+}
+
+func TestWaitForElastiCacheRedis_NotFound(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, _ := newTestClient(t, 300)
+
+	start := time.Now()
+	_, err := c.WaitForElastiCacheRedis(ctx, "redis-1")
+
+	is.Equal(errorCode(err), elasticache.ErrCodeCacheClusterNotFoundFault)
+	is.True(time.Since(start) < time.Second) // not retried until the timeout
+}
+
+func TestWaitForElastiCacheRedis_Deleting(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, server := newTestClient(t, 300)
+	server.ClusterDeleteDelay = time.Hour
+	_, err := c.CreateElastiCacheRedis(ctx, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+	is.NoErr(err)
+	is.NoErr(c.DeleteElastiCacheRedis(ctx, "redis-1", ""))
+
+	_, err = c.WaitForElastiCacheRedis(ctx, "redis-1")
+
+	var terr *terminalStatusError
+	is.True(errors.As(err, &terr)) // will never become available
+	is.Equal(terr.status, "deleting")
+}
+
+func TestCreateElastiCacheRedis_Cancelled(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	c, server := newTestClient(t, 300)
+	server.ClusterCreateDelay = time.Hour
+
+	start := time.Now()
+	_, err := c.CreateElastiCacheRedis(ctx, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+
+	is.Equal(errorCode(err), request.CanceledErrorCode)
+	is.True(time.Since(start) < time.Second) // stopped polling as soon as the context was done
+}
+
+func TestWaitForElastiCacheRedis(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, server := newTestClient(t, 1)
+	server.ClusterCreateDelay = 200 * time.Millisecond
+	// The request creating the cluster gives up waiting for it.
+	interrupted, cancel := context.WithTimeout(ctx, 30*time.Millisecond)
+	defer cancel()
+	_, err := c.CreateElastiCacheRedis(interrupted, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+	is.Equal(errorCode(err), request.CanceledErrorCode)
+
+	host, err := c.WaitForElastiCacheRedis(ctx, "redis-1")
+
+	is.NoErr(err)
+	is.Equal(host, "redis-1.local.0001.cache.localhost")
+}
+
+func TestCreateElastiCacheRedis_Errors(t *testing.T) {
+	for code, message := range map[string]string{
+		elasticache.ErrCodeInsufficientCacheClusterCapacityFault: "Insufficient cache cluster capacity",
+		elasticache.ErrCodeCacheSubnetGroupNotFoundFault:         "Subnet group not found",
+		elasticache.ErrCodeClusterQuotaForCustomerExceededFault:  "Cluster quota for customer exceeded",
+	} {
+		t.Run(code, func(t *testing.T) {
+			is := is.New(t)
+			ctx := context.Background()
+			c, server := newTestClient(t, 1)
+			server.Errors["CreateCacheCluster"] = code
+
+			_, err := c.CreateElastiCacheRedis(ctx, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+
+			is.Equal(err.Error(), message)
+		})
+	}
+}
+
+func TestCreateElastiCacheRedis_AlreadyExists(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, _ := newTestClient(t, 1)
+	_, err := c.CreateElastiCacheRedis(ctx, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+	is.NoErr(err)
+
+	host, err := c.CreateElastiCacheRedis(ctx, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+
+	is.NoErr(err) // the cluster is adopted
+	is.Equal(host, "redis-1.local.0001.cache.localhost")
+}
+
+func TestCreateElastiCacheRedis_NotOwned(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, server := newTestClient(t, 1)
+	_, err := c.CreateElastiCacheRedis(ctx, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+	is.NoErr(err)
+	cluster, _ := server.CacheCluster("redis-1")
+	is.Equal(cluster.Tags, map[string]string{OwnerTag: "my-resource"})
+
+	_, err = c.CreateElastiCacheRedis(ctx, "redis-1", "other-resource", "cache.t3.micro", "eu-west-1a")
+	is.True(errors.Is(err, ErrNotOwned)) // clusters of other resources are not adopted
+
+	is.NoErr(c.TagElastiCacheRedis(ctx, "redis-1", map[string]string{RetainedTag: "my-resource"}))
+	_, err = c.CreateElastiCacheRedis(ctx, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+	is.True(errors.Is(err, ErrNotOwned)) // nor are retained clusters
+}
+
+func TestDeleteElastiCacheRedis(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, server := newTestClient(t, 1)
+	server.ClusterDeleteDelay = time.Hour
+	_, err := c.CreateElastiCacheRedis(ctx, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+	is.NoErr(err)
+
+	is.NoErr(c.DeleteElastiCacheRedis(ctx, "redis-1", ""))
+
+	cluster, _ := server.CacheCluster("redis-1")
+	is.Equal(cluster.CacheClusterStatus, "deleting")
+}
+
+func TestDeleteElastiCacheRedis_NotFound(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, _ := newTestClient(t, 1)
+
+	err := c.DeleteElastiCacheRedis(ctx, "redis-1", "")
+
+	is.True(err != nil)
+}
+
+func TestDeleteElastiCacheRedis_FinalSnapshot(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, server := newTestClient(t, 1)
+	_, err := c.CreateElastiCacheRedis(ctx, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+	is.NoErr(err)
+
+	is.NoErr(c.DeleteElastiCacheRedis(ctx, "redis-1", "redis-1-final"))
+
+	clusterId, exists := server.Snapshot("redis-1-final")
+	is.True(exists)
+	is.Equal(clusterId, "redis-1")
+}
+
+func TestWaitForElastiCacheRedisDeleted(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, server := newTestClient(t, 1)
+	_, err := c.CreateElastiCacheRedis(ctx, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+	is.NoErr(err)
+	server.ClusterDeleteDelay = 100 * time.Millisecond
+	is.NoErr(c.DeleteElastiCacheRedis(ctx, "redis-1", ""))
+
+	is.NoErr(c.WaitForElastiCacheRedisDeleted(ctx, "redis-1"))
+
+	_, exists := server.CacheCluster("redis-1")
+	is.True(!exists) // only returns once the cluster is gone
+}
+
+func TestWaitForElastiCacheRedisDeleted_Available(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, _ := newTestClient(t, 1)
+	_, err := c.CreateElastiCacheRedis(ctx, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+	is.NoErr(err)
+
+	// The cluster is available, as it is once AWS stops deleting it.
+	err = c.WaitForElastiCacheRedisDeleted(ctx, "redis-1")
+
+	is.True(errors.Is(err, ErrDeletionAbandoned))
+}
+
+func TestWaitForElastiCacheRedisDeleted_Timeout(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, server := newTestClient(t, 1)
+	_, err := c.CreateElastiCacheRedis(ctx, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+	is.NoErr(err)
+	server.ClusterDeleteDelay = time.Hour
+	is.NoErr(c.DeleteElastiCacheRedis(ctx, "redis-1", ""))
+
+	err = c.WaitForElastiCacheRedisDeleted(ctx, "redis-1")
+
+	is.Equal(errorCode(err), request.WaiterResourceNotReadyErrorCode)
+	cluster, _ := server.CacheCluster("redis-1")
+	is.Equal(cluster.CacheClusterStatus, "deleting")
+}
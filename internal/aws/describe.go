@@ -0,0 +1,242 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"humanitec.io/resources/driver-aws-external/internal/logging"
+)
+
+// CacheCluster describes an existing ElastiCache cluster.
+type CacheCluster struct {
+	ID               string
+	ARN              string
+	Status           string
+	Engine           string
+	CacheNodeType    string
+	AvailabilityZone string
+	// Host and Port are the endpoint of the cluster's first node. They are empty until the cluster is available.
+	Host string
+	Port int64
+	Tags map[string]string
+}
+
+// BucketRegion returns the region an existing bucket is in.
+func (c awsClient) BucketRegion(ctx context.Context, bucketName string) (string, error) {
+	l := logging.FromContext(ctx).WithField("bucket", bucketName)
+	svc := s3.New(c.sess)
+	req, out := svc.GetBucketLocationRequest(&s3.GetBucketLocationInput{Bucket: aws.String(bucketName)})
+	req.SetContext(ctx)
+	// Turns the LocationConstraint of buckets in us-east-1 and eu-west-1 into their region names.
+	req.Handlers.Unmarshal.PushBackNamed(s3.NormalizeBucketLocationHandler)
+	if err := req.Send(); err != nil {
+		l.WithError(err).Error("Error getting s3 bucket location")
+		return "", fmt.Errorf(`getting location of s3 bucket "%s": %w`, bucketName, err)
+	}
+	return aws.StringValue(out.LocationConstraint), nil
+}
+
+// DescribeElastiCacheRedis returns the state and configuration of an existing cluster.
+func (c awsClient) DescribeElastiCacheRedis(ctx context.Context, clusterId string) (CacheCluster, error) {
+	l := logging.FromContext(ctx).WithField("cluster_id", clusterId)
+	svc := elasticache.New(c.sess)
+	out, err := svc.DescribeCacheClustersWithContext(ctx, &elasticache.DescribeCacheClustersInput{
+		CacheClusterId:    aws.String(clusterId),
+		ShowCacheNodeInfo: aws.Bool(true),
+	})
+	if err != nil {
+		l.WithError(err).Error("Error describing Elasticache cluster")
+		return CacheCluster{}, fmt.Errorf(`describing Elasticache cluster "%s": %w`, clusterId, err)
+	}
+	if len(out.CacheClusters) == 0 {
+		l.Error("Elasticache cluster is missing from the description")
+		return CacheCluster{}, fmt.Errorf(`describing Elasticache cluster "%s": no cluster returned`, clusterId)
+	}
+	cc := out.CacheClusters[0]
+	cluster := CacheCluster{
+		ID:               aws.StringValue(cc.CacheClusterId),
+		ARN:              aws.StringValue(cc.ARN),
+		Status:           aws.StringValue(cc.CacheClusterStatus),
+		Engine:           aws.StringValue(cc.Engine),
+		CacheNodeType:    aws.StringValue(cc.CacheNodeType),
+		AvailabilityZone: aws.StringValue(cc.PreferredAvailabilityZone),
+	}
+	if len(cc.CacheNodes) > 0 && cc.CacheNodes[0].Endpoint != nil {
+		cluster.Host = aws.StringValue(cc.CacheNodes[0].Endpoint.Address)
+		cluster.Port = aws.Int64Value(cc.CacheNodes[0].Endpoint.Port)
+	}
+	if cluster.Tags, err = c.elastiCacheTags(ctx, clusterId, cluster.ARN); err != nil {
+		return CacheCluster{}, err
+	}
+	return cluster, nil
+}
+
+// Errors S3 returns when getting a part of a bucket's configuration that is not set. The SDK has no constants for them.
+const (
+	noSuchLifecycleConfiguration  = "NoSuchLifecycleConfiguration"
+	noSuchCORSConfiguration       = "NoSuchCORSConfiguration"
+	noSuchWebsiteConfiguration    = "NoSuchWebsiteConfiguration"
+	noSuchEncryptionConfiguration = "ServerSideEncryptionConfigurationNotFoundError"
+)
+
+// errorCode returns the code of the AWS error wrapped by err, or "" if there is none.
+func errorCode(err error) string {
+	var aerr awserr.Error
+	if errors.As(err, &aerr) {
+		return aerr.Code()
+	}
+	return ""
+}
+
+// Bucket describes the configuration of an existing bucket.
+type Bucket struct {
+	Config BucketConfig
+	// SSEAlgorithm and KMSKeyID are the bucket's default encryption, as in BucketSecurity. SSEAlgorithm is empty if the
+	// bucket has none.
+	SSEAlgorithm string
+	KMSKeyID     string
+	// Unsupported lists the parts of the configuration that cannot be expressed as a BucketConfig, e.g. lifecycle rules
+	// that filter objects by tag. They are left out of Config.
+	Unsupported []string
+	Tags        map[string]string
+}
+
+// DescribeBucket returns the configuration of an existing bucket.
+func (c awsClient) DescribeBucket(ctx context.Context, bucketName string) (Bucket, error) {
+	l := logging.FromContext(ctx).WithField("bucket", bucketName)
+	svc := s3.New(c.sess)
+	bucket := aws.String(bucketName)
+	var b Bucket
+
+	versioning, err := svc.GetBucketVersioningWithContext(ctx, &s3.GetBucketVersioningInput{Bucket: bucket})
+	if err != nil {
+		l.WithError(err).Error("Error getting s3 bucket versioning")
+		return Bucket{}, fmt.Errorf(`getting versioning of s3 bucket "%s": %w`, bucketName, err)
+	}
+	b.Config.Versioning = aws.StringValue(versioning.Status)
+
+	lifecycle, err := svc.GetBucketLifecycleConfigurationWithContext(ctx, &s3.GetBucketLifecycleConfigurationInput{Bucket: bucket})
+	if err != nil && errorCode(err) != noSuchLifecycleConfiguration {
+		l.WithError(err).Error("Error getting s3 bucket lifecycle rules")
+		return Bucket{}, fmt.Errorf(`getting lifecycle rules of s3 bucket "%s": %w`, bucketName, err)
+	}
+	if err == nil {
+		for _, r := range lifecycle.Rules {
+			rule, unsupported := fromS3LifecycleRule(r)
+			if unsupported != "" {
+				b.Unsupported = append(b.Unsupported, fmt.Sprintf(`lifecycle rule "%s": %s`, rule.ID, unsupported))
+				continue
+			}
+			b.Config.LifecycleRules = append(b.Config.LifecycleRules, rule)
+		}
+	}
+
+	cors, err := svc.GetBucketCorsWithContext(ctx, &s3.GetBucketCorsInput{Bucket: bucket})
+	if err != nil && errorCode(err) != noSuchCORSConfiguration {
+		l.WithError(err).Error("Error getting s3 bucket CORS rules")
+		return Bucket{}, fmt.Errorf(`getting CORS rules of s3 bucket "%s": %w`, bucketName, err)
+	}
+	if err == nil {
+		for _, r := range cors.CORSRules {
+			b.Config.CORSRules = append(b.Config.CORSRules, fromS3CORSRule(r))
+		}
+	}
+
+	website, err := svc.GetBucketWebsiteWithContext(ctx, &s3.GetBucketWebsiteInput{Bucket: bucket})
+	if err != nil && errorCode(err) != noSuchWebsiteConfiguration {
+		l.WithError(err).Error("Error getting s3 bucket website")
+		return Bucket{}, fmt.Errorf(`getting website of s3 bucket "%s": %w`, bucketName, err)
+	}
+	switch {
+	case err != nil:
+	case website.RedirectAllRequestsTo != nil || len(website.RoutingRules) > 0 || website.IndexDocument == nil:
+		b.Unsupported = append(b.Unsupported, "website: redirects")
+	default:
+		b.Config.Website = &BucketWebsite{IndexDocument: aws.StringValue(website.IndexDocument.Suffix)}
+		if website.ErrorDocument != nil {
+			b.Config.Website.ErrorDocument = aws.StringValue(website.ErrorDocument.Key)
+		}
+	}
+
+	encryption, err := svc.GetBucketEncryptionWithContext(ctx, &s3.GetBucketEncryptionInput{Bucket: bucket})
+	if err != nil && errorCode(err) != noSuchEncryptionConfiguration {
+		l.WithError(err).Error("Error getting s3 bucket encryption")
+		return Bucket{}, fmt.Errorf(`getting encryption of s3 bucket "%s": %w`, bucketName, err)
+	}
+	if err == nil && encryption.ServerSideEncryptionConfiguration != nil {
+		for _, r := range encryption.ServerSideEncryptionConfiguration.Rules {
+			if r.ApplyServerSideEncryptionByDefault != nil {
+				b.SSEAlgorithm = aws.StringValue(r.ApplyServerSideEncryptionByDefault.SSEAlgorithm)
+				b.KMSKeyID = aws.StringValue(r.ApplyServerSideEncryptionByDefault.KMSMasterKeyID)
+			}
+		}
+	}
+
+	if b.Tags, err = c.bucketTags(ctx, bucketName); err != nil {
+		return Bucket{}, err
+	}
+	return b, nil
+}
+
+// fromS3LifecycleRule is the reverse of toS3LifecycleRule. If the rule cannot be expressed as a LifecycleRule, it also
+// returns what it is about the rule that is not supported.
+func fromS3LifecycleRule(r *s3.LifecycleRule) (LifecycleRule, string) {
+	rule := LifecycleRule{
+		ID:       aws.StringValue(r.ID),
+		Prefix:   aws.StringValue(r.Prefix),
+		Disabled: aws.StringValue(r.Status) == s3.ExpirationStatusDisabled,
+	}
+	if f := r.Filter; f != nil {
+		if f.And != nil || f.Tag != nil || f.ObjectSizeGreaterThan != nil || f.ObjectSizeLessThan != nil {
+			return rule, "filters other than a prefix"
+		}
+		rule.Prefix = aws.StringValue(f.Prefix)
+	}
+	if e := r.Expiration; e != nil {
+		if e.Date != nil || aws.BoolValue(e.ExpiredObjectDeleteMarker) {
+			return rule, "expiration other than after days"
+		}
+		rule.ExpirationDays = aws.Int64Value(e.Days)
+	}
+	if e := r.NoncurrentVersionExpiration; e != nil {
+		if e.NewerNoncurrentVersions != nil {
+			return rule, "keeping newer noncurrent versions"
+		}
+		rule.NoncurrentVersionExpirationDays = aws.Int64Value(e.NoncurrentDays)
+	}
+	if len(r.NoncurrentVersionTransitions) > 0 {
+		return rule, "noncurrent version transitions"
+	}
+	if a := r.AbortIncompleteMultipartUpload; a != nil {
+		rule.AbortIncompleteMultipartUploadDays = aws.Int64Value(a.DaysAfterInitiation)
+	}
+	for _, t := range r.Transitions {
+		if t.Date != nil {
+			return rule, "transitions at a date"
+		}
+		rule.Transitions = append(rule.Transitions, LifecycleTransition{Days: aws.Int64Value(t.Days), StorageClass: aws.StringValue(t.StorageClass)})
+	}
+	return rule, ""
+}
+
+// fromS3CORSRule is the reverse of toS3CORSRule.
+func fromS3CORSRule(r *s3.CORSRule) CORSRule {
+	rule := CORSRule{
+		ID:             aws.StringValue(r.ID),
+		AllowedOrigins: aws.StringValueSlice(r.AllowedOrigins),
+		AllowedMethods: aws.StringValueSlice(r.AllowedMethods),
+		MaxAgeSeconds:  aws.Int64Value(r.MaxAgeSeconds),
+	}
+	if len(r.AllowedHeaders) > 0 {
+		rule.AllowedHeaders = aws.StringValueSlice(r.AllowedHeaders)
+	}
+	if len(r.ExposeHeaders) > 0 {
+		rule.ExposeHeaders = aws.StringValueSlice(r.ExposeHeaders)
+	}
+	return rule
+}
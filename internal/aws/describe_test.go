@@ -0,0 +1,158 @@
+package aws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/matryer/is"
+)
+
+func TestBucketRegion(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, _ := newTestClient(t, 1)
+	_, err := c.CreateBucket(ctx, "my-bucket", "my-resource")
+	is.NoErr(err)
+
+	region, err := c.BucketRegion(ctx, "my-bucket")
+	is.NoErr(err)
+	is.Equal(region, "eu-west-1")
+
+	_, err = c.BucketRegion(ctx, "other-bucket")
+	is.Equal(errorCode(err), s3.ErrCodeNoSuchBucket)
+}
+
+func TestDescribeElastiCacheRedis(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, server := newTestClient(t, 1)
+	server.ClusterCreateDelay = 50 * time.Millisecond
+	_, err := c.CreateElastiCacheRedis(ctx, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+	is.NoErr(err)
+
+	cluster, err := c.DescribeElastiCacheRedis(ctx, "redis-1")
+
+	is.NoErr(err)
+	is.Equal(cluster, CacheCluster{
+		ID:               "redis-1",
+		ARN:              "arn:aws:elasticache:local:000000000000:cluster:redis-1",
+		Status:           "available",
+		Engine:           "redis",
+		CacheNodeType:    "cache.t3.micro",
+		AvailabilityZone: "eu-west-1a",
+		Host:             "redis-1.local.0001.cache.localhost",
+		Port:             6379,
+		Tags:             map[string]string{OwnerTag: "my-resource"},
+	})
+
+	_, err = c.DescribeElastiCacheRedis(ctx, "redis-2")
+	is.Equal(errorCode(err), elasticache.ErrCodeCacheClusterNotFoundFault)
+}
+
+func TestFakeDescribe(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	a, _ := newTestFakeAccount(DefaultFakeConfig())
+	c, _ := a.New("key", "secret", "eu-west-1", DefaultWaitConfig())
+	other, _ := a.New("key", "secret", "eu-central-1", DefaultWaitConfig())
+
+	_, err := c.CreateBucket(ctx, "my-bucket", "my-resource")
+	is.NoErr(err)
+	region, err := other.BucketRegion(ctx, "my-bucket")
+	is.NoErr(err) // buckets are found from any region
+	is.Equal(region, "eu-west-1")
+
+	host, err := c.CreateElastiCacheRedis(ctx, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+	is.NoErr(err)
+	cluster, err := c.DescribeElastiCacheRedis(ctx, "redis-1")
+	is.NoErr(err)
+	is.Equal(cluster.Status, "available")
+	is.Equal(cluster.Host, host)
+	is.Equal(cluster.CacheNodeType, "cache.t3.micro")
+	is.Equal(cluster.Tags, map[string]string{OwnerTag: "my-resource"})
+
+	_, err = other.DescribeElastiCacheRedis(ctx, "redis-1")
+	is.Equal(errorCode(err), elasticache.ErrCodeCacheClusterNotFoundFault) // clusters are regional
+}
+
+func TestDescribeBucket(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, _ := newTestClient(t, 1)
+	_, err := c.CreateBucket(ctx, "my-bucket", "my-resource")
+	is.NoErr(err)
+
+	bucket, err := c.DescribeBucket(ctx, "my-bucket")
+	is.NoErr(err)
+	is.Equal(bucket, Bucket{Tags: map[string]string{OwnerTag: "my-resource"}}) // nothing is configured
+
+	config := BucketConfig{
+		Versioning: s3.BucketVersioningStatusEnabled,
+		LifecycleRules: []LifecycleRule{{
+			ID:                                 "archive",
+			Prefix:                             "logs/",
+			ExpirationDays:                     365,
+			NoncurrentVersionExpirationDays:    30,
+			AbortIncompleteMultipartUploadDays: 1,
+			Transitions:                        []LifecycleTransition{{Days: 30, StorageClass: s3.TransitionStorageClassGlacier}},
+		}},
+		CORSRules: []CORSRule{{
+			AllowedOrigins: []string{"https://app.example.com"},
+			AllowedMethods: []string{"GET"},
+			MaxAgeSeconds:  3000,
+		}},
+		Website: &BucketWebsite{IndexDocument: "index.html", ErrorDocument: "404.html"},
+	}
+	is.NoErr(c.ConfigureBucket(ctx, "my-bucket", config))
+	is.NoErr(c.SecureBucket(ctx, "my-bucket", BucketSecurity{SSEAlgorithm: s3.ServerSideEncryptionAwsKms, KMSKeyID: "key-1"}))
+
+	bucket, err = c.DescribeBucket(ctx, "my-bucket")
+	is.NoErr(err)
+	is.Equal(bucket, Bucket{Config: config, SSEAlgorithm: s3.ServerSideEncryptionAwsKms, KMSKeyID: "key-1", Tags: map[string]string{OwnerTag: "my-resource"}})
+
+	_, err = c.DescribeBucket(ctx, "other-bucket")
+	is.Equal(errorCode(err), s3.ErrCodeNoSuchBucket)
+}
+
+func TestDescribeBucket_Unsupported(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, _ := newTestClient(t, 1)
+	_, err := c.CreateBucket(ctx, "my-bucket", "my-resource")
+	is.NoErr(err)
+	svc := s3.New(c.sess)
+	_, err = svc.PutBucketLifecycleConfiguration(&s3.PutBucketLifecycleConfigurationInput{
+		Bucket: aws.String("my-bucket"),
+		LifecycleConfiguration: &s3.BucketLifecycleConfiguration{Rules: []*s3.LifecycleRule{
+			{
+				ID:         aws.String("by-tag"),
+				Filter:     &s3.LifecycleRuleFilter{Tag: &s3.Tag{Key: aws.String("temporary"), Value: aws.String("true")}},
+				Status:     aws.String(s3.ExpirationStatusEnabled),
+				Expiration: &s3.LifecycleExpiration{Days: aws.Int64(1)},
+			},
+			{
+				ID:         aws.String("by-prefix"),
+				Filter:     &s3.LifecycleRuleFilter{Prefix: aws.String("tmp/")},
+				Status:     aws.String(s3.ExpirationStatusEnabled),
+				Expiration: &s3.LifecycleExpiration{Days: aws.Int64(7)},
+			},
+		}},
+	})
+	is.NoErr(err)
+	_, err = svc.PutBucketWebsite(&s3.PutBucketWebsiteInput{
+		Bucket:               aws.String("my-bucket"),
+		WebsiteConfiguration: &s3.WebsiteConfiguration{RedirectAllRequestsTo: &s3.RedirectAllRequestsTo{HostName: aws.String("example.com")}},
+	})
+	is.NoErr(err)
+
+	bucket, err := c.DescribeBucket(ctx, "my-bucket")
+
+	is.NoErr(err)
+	is.Equal(bucket.Config.LifecycleRules, []LifecycleRule{{ID: "by-prefix", Prefix: "tmp/", ExpirationDays: 7}})
+	is.Equal(bucket.Config.Website, nil)
+	is.Equal(bucket.Unsupported, []string{`lifecycle rule "by-tag": filters other than a prefix`, "website: redirects"})
+}
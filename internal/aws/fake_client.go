@@ -1,26 +1,469 @@
 package aws
 
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"humanitec.io/resources/driver-aws-external/internal/logging"
+)
+
+// FakeConfig controls the behaviour of a FakeAccount.
+type FakeConfig struct {
+	// ClusterCreateDelay is how long a cache cluster stays in the "creating" state.
+	ClusterCreateDelay time.Duration
+
+	// ClusterDeleteDelay is how long a cache cluster stays in the "deleting" state.
+	ClusterDeleteDelay time.Duration
+
+	// MaxBuckets is the maximum number of buckets in the account.
+	MaxBuckets int
+
+	// MaxClusters is the maximum number of cache clusters per region.
+	MaxClusters int
+
+	// Errors maps operation names (e.g. "CreateBucket") to the AWS error code that the operation should fail with.
+	Errors map[string]string
+}
+
+// DefaultFakeConfig returns the configuration used when none is specified. The quotas match the AWS defaults.
+func DefaultFakeConfig() FakeConfig {
+	return FakeConfig{
+		MaxBuckets:  100,
+		MaxClusters: 300,
+		Errors:      map[string]string{},
+	}
+}
+
+type fakeCluster struct {
+	region        string
+	cacheNodeType string
+	cacheAz       string
+	status        string
+	tags          map[string]string
+	// transitionAt is when the cluster leaves its current transitional status.
+	transitionAt time.Time
+}
+
+// FakeAccount emulates the state of a single AWS account in-process. Clients created from the same FakeAccount see
+// each others buckets and clusters.
+type FakeAccount struct {
+	cfg   FakeConfig
+	now   func() time.Time
+	sleep func(context.Context, time.Duration) error
+
+	mu       sync.Mutex
+	buckets  map[string]*fakeBucket
+	clusters map[string]*fakeCluster
+	// snapshots maps the keys of final snapshots taken of deleted clusters to the id of the cluster.
+	snapshots map[string]string
+}
+
+// fakeBucket holds the state of a bucket in a FakeAccount.
+type fakeBucket struct {
+	region   string
+	security BucketSecurity
+	config   BucketConfig
+	tags     map[string]string
+}
+
+// NewFakeAccount creates an empty FakeAccount.
+func NewFakeAccount(cfg FakeConfig) *FakeAccount {
+	return &FakeAccount{
+		cfg:       cfg,
+		now:       time.Now,
+		sleep:     aws.SleepWithContext,
+		buckets:   map[string]*fakeBucket{},
+		clusters:  map[string]*fakeCluster{},
+		snapshots: map[string]string{},
+	}
+}
+
+// New creates a Client backed by the account. It has the same signature as the aws.New function.
+func (a *FakeAccount) New(accessKeyId, secretAccessKey, region string, wait WaitConfig) (Client, error) {
+	return tracedClient{fakeClient{
+		account: a,
+		region:  region,
+		wait:    wait,
+	}}, nil
+}
+
+// injectedError returns the error configured for the operation, if any.
+func (a *FakeAccount) injectedError(operation string) error {
+	if code, ok := a.cfg.Errors[operation]; ok {
+		return awserr.New(code, fmt.Sprintf("injected error for %s", operation), nil)
+	}
+	return nil
+}
+
+// clusterKey identifies a cluster. Cluster IDs are only unique within a region.
+func clusterKey(region, clusterId string) string {
+	return region + "/" + clusterId
+}
+
+// refresh moves clusters on from transitional statuses once their time is up. Must be called with the lock held.
+func (a *FakeAccount) refresh() {
+	now := a.now()
+	for key, cluster := range a.clusters {
+		if now.Before(cluster.transitionAt) {
+			continue
+		}
+		switch cluster.status {
+		case "creating":
+			cluster.status = "available"
+		case "deleting":
+			delete(a.clusters, key)
+		}
+	}
+}
+
+func (a *FakeAccount) clustersInRegion(region string) int {
+	count := 0
+	for _, cluster := range a.clusters {
+		if cluster.region == region {
+			count++
+		}
+	}
+	return count
+}
+
 type fakeClient struct {
-	region string
+	account *FakeAccount
+	region  string
+	wait    WaitConfig
 }
 
-func (c fakeClient) CreateBucket(bucketName string) (string, error) {
+func (c fakeClient) CreateBucket(ctx context.Context, bucketName, resourceID string) (string, error) {
+	a := c.account
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.injectedError("CreateBucket"); err != nil {
+		return "", fmt.Errorf(`creating s3 bucket "%s": %w`, bucketName, err)
+	}
+	if b, exists := a.buckets[bucketName]; exists {
+		if b.region != c.region {
+			return "", fmt.Errorf(`s3 bucket name already exists "%s": %w`, bucketName, awserr.New(s3.ErrCodeBucketAlreadyExists, "bucket already exists", nil))
+		}
+		if !ownedBy(b.tags, resourceID) {
+			return "", fmt.Errorf(`s3 bucket "%s" %w`, bucketName, ErrNotOwned)
+		}
+		return c.region, nil
+	}
+	if len(a.buckets) >= a.cfg.MaxBuckets {
+		return "", fmt.Errorf(`creating s3 bucket "%s": %w`, bucketName, awserr.New("TooManyBuckets", "you have attempted to create more buckets than allowed", nil))
+	}
+	a.buckets[bucketName] = &fakeBucket{region: c.region, tags: map[string]string{OwnerTag: resourceID}}
 	return c.region, nil
 }
 
-func (c fakeClient) DeleteBucket(bucketName string) error {
+func (c fakeClient) SecureBucket(ctx context.Context, bucketName string, security BucketSecurity) error {
+	a := c.account
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.injectedError("SecureBucket"); err != nil {
+		return fmt.Errorf(`securing s3 bucket "%s": %w`, bucketName, err)
+	}
+	b, exists := a.buckets[bucketName]
+	if !exists {
+		return fmt.Errorf(`securing s3 bucket "%s": %w`, bucketName, awserr.New(s3.ErrCodeNoSuchBucket, "the specified bucket does not exist", nil))
+	}
+	b.security = security
 	return nil
 }
 
-func (c fakeClient) CreateElastiCacheRedis(clusterId string, cacheNodeType string, cacheAz string) (string, error) {
-	return clusterId + "." + c.region, nil
+func (c fakeClient) ConfigureBucket(ctx context.Context, bucketName string, config BucketConfig) error {
+	a := c.account
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.injectedError("ConfigureBucket"); err != nil {
+		return fmt.Errorf(`configuring s3 bucket "%s": %w`, bucketName, err)
+	}
+	b, exists := a.buckets[bucketName]
+	if !exists {
+		return fmt.Errorf(`configuring s3 bucket "%s": %w`, bucketName, awserr.New(s3.ErrCodeNoSuchBucket, "the specified bucket does not exist", nil))
+	}
+	if config.Versioning == "" {
+		config.Versioning = b.config.Versioning
+	}
+	b.config = config
+	return nil
 }
-func (c fakeClient) DeleteElastiCacheRedis(clusterId string) error {
+
+func (c fakeClient) DeleteBucket(ctx context.Context, bucketName string) error {
+	a := c.account
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.injectedError("DeleteBucket"); err != nil {
+		return fmt.Errorf(`deleting s3 bucket "%s": %w`, bucketName, err)
+	}
+	if _, exists := a.buckets[bucketName]; !exists {
+		return fmt.Errorf(`deleting s3 bucket "%s": %w`, bucketName, awserr.New(s3.ErrCodeNoSuchBucket, "the specified bucket does not exist", nil))
+	}
+	delete(a.buckets, bucketName)
+	return nil
+}
+
+func (c fakeClient) CreateElastiCacheRedis(ctx context.Context, clusterId, resourceID, cacheNodeType, cacheAz string) (string, error) {
+	a := c.account
+	a.mu.Lock()
+
+	if err := a.injectedError("CreateElastiCacheRedis"); err != nil {
+		a.mu.Unlock()
+		return "", fmt.Errorf(`creating Elasticache cluster "%s": %w`, clusterId, err)
+	}
+	a.refresh()
+	key := clusterKey(c.region, clusterId)
+	if cluster, exists := a.clusters[key]; exists {
+		a.mu.Unlock()
+		if cluster.status == "deleting" {
+			return "", fmt.Errorf(`Cache cluster already exists: %w`, awserr.New(elasticache.ErrCodeCacheClusterAlreadyExistsFault, "cache cluster already exists", nil))
+		}
+		if !ownedBy(cluster.tags, resourceID) {
+			return "", fmt.Errorf(`Elasticache cluster "%s" %w`, clusterId, ErrNotOwned)
+		}
+		return c.WaitForElastiCacheRedis(ctx, clusterId)
+	}
+	if a.clustersInRegion(c.region) >= a.cfg.MaxClusters {
+		a.mu.Unlock()
+		return "", fmt.Errorf(`Cluster quota for customer exceeded: %w`, awserr.New(elasticache.ErrCodeClusterQuotaForCustomerExceededFault, "cluster quota exceeded", nil))
+	}
+	readyAt := a.now().Add(a.cfg.ClusterCreateDelay)
+	a.clusters[key] = &fakeCluster{
+		region:        c.region,
+		cacheNodeType: cacheNodeType,
+		cacheAz:       cacheAz,
+		status:        "creating",
+		transitionAt:  readyAt,
+		tags:          map[string]string{OwnerTag: resourceID},
+	}
+	a.mu.Unlock()
+
+	logging.FromContext(ctx).WithField("cluster_id", clusterId).Infof("Fake cluster created. Available at %v.", readyAt)
+	return c.WaitForElastiCacheRedis(ctx, clusterId)
+}
+
+func (c fakeClient) WaitForElastiCacheRedis(ctx context.Context, clusterId string) (string, error) {
+	a := c.account
+	key := clusterKey(c.region, clusterId)
+	a.mu.Lock()
+	a.refresh()
+	cluster, exists := a.clusters[key]
+	if !exists {
+		a.mu.Unlock()
+		return "", fmt.Errorf(`describing Elasticache cluster "%s": %w`, clusterId, awserr.New(elasticache.ErrCodeCacheClusterNotFoundFault, "cache cluster not found", nil))
+	}
+	if cluster.status != "creating" && cluster.status != "available" {
+		a.mu.Unlock()
+		err := &terminalStatusError{name: fmt.Sprintf(`cluster "%s" to become available`, clusterId), status: cluster.status}
+		return "", fmt.Errorf(`fetching endpoint failed. cluster "%s" is %s: %w`, clusterId, cluster.status, err)
+	}
+	readyAt := cluster.transitionAt
+	if cluster.status != "creating" {
+		readyAt = a.now()
+	}
+	a.mu.Unlock()
+
+	timeout := c.wait.CreateTimeout
+	if wait := readyAt.Sub(a.now()); wait > timeout {
+		if err := a.sleep(ctx, timeout); err != nil {
+			return "", fmt.Errorf(`waiting for Elasticache cluster "%s": %w`, clusterId, err)
+		}
+		return "", fmt.Errorf(`fetching endpoint failed. cluster "%s" not available after %v: %w`, clusterId, timeout,
+			awserr.New(request.WaiterResourceNotReadyErrorCode, fmt.Sprintf(`gave up waiting for cluster "%s" to become available after %v`, clusterId, timeout), nil))
+	} else if wait > 0 {
+		if err := a.sleep(ctx, wait); err != nil {
+			return "", fmt.Errorf(`waiting for Elasticache cluster "%s": %w`, clusterId, err)
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.refresh()
+	cluster, exists = a.clusters[key]
+	if !exists || cluster.status != "available" {
+		return "", fmt.Errorf(`describing Elasticache cluster "%s": %w`, clusterId, awserr.New(elasticache.ErrCodeCacheClusterNotFoundFault, "cache cluster not found", nil))
+	}
+	return fmt.Sprintf("%s.fake.0001.%s.cache.amazonaws.com", clusterId, c.region), nil
+}
+
+func (c fakeClient) DeleteElastiCacheRedis(ctx context.Context, clusterId, finalSnapshotId string) error {
+	a := c.account
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.injectedError("DeleteElastiCacheRedis"); err != nil {
+		return fmt.Errorf(`deleting elasticache redis cluster "%s": %w`, clusterId, err)
+	}
+	a.refresh()
+	cluster, exists := a.clusters[clusterKey(c.region, clusterId)]
+	if !exists {
+		return fmt.Errorf(`deleting elasticache redis cluster "%s": %w`, clusterId, awserr.New(elasticache.ErrCodeCacheClusterNotFoundFault, "cache cluster not found", nil))
+	}
+	if cluster.status != "available" {
+		return fmt.Errorf(`deleting elasticache redis cluster "%s": %w`, clusterId, awserr.New(elasticache.ErrCodeInvalidCacheClusterStateFault, fmt.Sprintf("cache cluster is %s", cluster.status), nil))
+	}
+	if finalSnapshotId != "" {
+		snapshotKey := clusterKey(c.region, finalSnapshotId)
+		if _, exists := a.snapshots[snapshotKey]; exists {
+			return fmt.Errorf(`deleting elasticache redis cluster "%s": %w`, clusterId, awserr.New(elasticache.ErrCodeSnapshotAlreadyExistsFault, "snapshot already exists", nil))
+		}
+		a.snapshots[snapshotKey] = clusterId
+	}
+	cluster.status = "deleting"
+	cluster.transitionAt = a.now().Add(a.cfg.ClusterDeleteDelay)
+	return nil
+}
+
+func (c fakeClient) WaitForElastiCacheRedisDeleted(ctx context.Context, clusterId string) error {
+	a := c.account
+	a.mu.Lock()
+	a.refresh()
+	cluster, exists := a.clusters[clusterKey(c.region, clusterId)]
+	if !exists {
+		a.mu.Unlock()
+		return nil
+	}
+	if cluster.status == "available" {
+		a.mu.Unlock()
+		return fmt.Errorf(`cluster "%s" %w`, clusterId, ErrDeletionAbandoned)
+	}
+	// Clusters that are not being deleted never go away.
+	deleting := cluster.status == "deleting"
+	goneAt := cluster.transitionAt
+	a.mu.Unlock()
+
+	timeout := c.wait.DeleteTimeout
+	if wait := goneAt.Sub(a.now()); !deleting || wait > timeout {
+		if err := a.sleep(ctx, timeout); err != nil {
+			return fmt.Errorf(`waiting for deletion of Elasticache cluster "%s": %w`, clusterId, err)
+		}
+		return fmt.Errorf(`cluster "%s" not deleted after %v: %w`, clusterId, timeout,
+			awserr.New(request.WaiterResourceNotReadyErrorCode, fmt.Sprintf(`gave up waiting for cluster "%s" to be deleted after %v`, clusterId, timeout), nil))
+	} else if wait > 0 {
+		if err := a.sleep(ctx, wait); err != nil {
+			return fmt.Errorf(`waiting for deletion of Elasticache cluster "%s": %w`, clusterId, err)
+		}
+	}
+	return nil
+}
+
+func (c fakeClient) BucketRegion(ctx context.Context, bucketName string) (string, error) {
+	a := c.account
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.injectedError("BucketRegion"); err != nil {
+		return "", fmt.Errorf(`getting location of s3 bucket "%s": %w`, bucketName, err)
+	}
+	b, exists := a.buckets[bucketName]
+	if !exists {
+		return "", fmt.Errorf(`getting location of s3 bucket "%s": %w`, bucketName, awserr.New(s3.ErrCodeNoSuchBucket, "the specified bucket does not exist", nil))
+	}
+	return b.region, nil
+}
+
+func (c fakeClient) DescribeBucket(ctx context.Context, bucketName string) (Bucket, error) {
+	a := c.account
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.injectedError("DescribeBucket"); err != nil {
+		return Bucket{}, fmt.Errorf(`getting versioning of s3 bucket "%s": %w`, bucketName, err)
+	}
+	b, exists := a.buckets[bucketName]
+	if !exists {
+		return Bucket{}, fmt.Errorf(`getting versioning of s3 bucket "%s": %w`, bucketName, awserr.New(s3.ErrCodeNoSuchBucket, "the specified bucket does not exist", nil))
+	}
+	return Bucket{Config: b.config, SSEAlgorithm: b.security.SSEAlgorithm, KMSKeyID: b.security.KMSKeyID, Tags: copyTags(b.tags)}, nil
+}
+
+func (c fakeClient) DescribeElastiCacheRedis(ctx context.Context, clusterId string) (CacheCluster, error) {
+	a := c.account
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.injectedError("DescribeElastiCacheRedis"); err != nil {
+		return CacheCluster{}, fmt.Errorf(`describing Elasticache cluster "%s": %w`, clusterId, err)
+	}
+	a.refresh()
+	cluster, exists := a.clusters[clusterKey(c.region, clusterId)]
+	if !exists {
+		return CacheCluster{}, fmt.Errorf(`describing Elasticache cluster "%s": %w`, clusterId, awserr.New(elasticache.ErrCodeCacheClusterNotFoundFault, "cache cluster not found", nil))
+	}
+	description := CacheCluster{
+		ID:               clusterId,
+		ARN:              fmt.Sprintf("arn:aws:elasticache:%s:000000000000:cluster:%s", c.region, clusterId),
+		Status:           cluster.status,
+		Engine:           "redis",
+		CacheNodeType:    cluster.cacheNodeType,
+		AvailabilityZone: cluster.cacheAz,
+		Tags:             copyTags(cluster.tags),
+	}
+	if cluster.status != "creating" {
+		description.Host = fmt.Sprintf("%s.fake.0001.%s.cache.amazonaws.com", clusterId, c.region)
+		description.Port = 6379
+	}
+	return description, nil
+}
+
+func (c fakeClient) TagBucket(ctx context.Context, bucketName string, tags map[string]string) error {
+	a := c.account
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.injectedError("TagBucket"); err != nil {
+		return fmt.Errorf(`tagging s3 bucket "%s": %w`, bucketName, err)
+	}
+	b, exists := a.buckets[bucketName]
+	if !exists {
+		return fmt.Errorf(`tagging s3 bucket "%s": %w`, bucketName, awserr.New(s3.ErrCodeNoSuchBucket, "the specified bucket does not exist", nil))
+	}
+	if b.tags == nil {
+		b.tags = map[string]string{}
+	}
+	for k, v := range tags {
+		b.tags[k] = v
+	}
+	return nil
+}
+
+func (c fakeClient) TagElastiCacheRedis(ctx context.Context, clusterId string, tags map[string]string) error {
+	a := c.account
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.injectedError("TagElastiCacheRedis"); err != nil {
+		return fmt.Errorf(`tagging Elasticache cluster "%s": %w`, clusterId, err)
+	}
+	a.refresh()
+	cluster, exists := a.clusters[clusterKey(c.region, clusterId)]
+	if !exists {
+		return fmt.Errorf(`describing Elasticache cluster "%s": %w`, clusterId, awserr.New(elasticache.ErrCodeCacheClusterNotFoundFault, "cache cluster not found", nil))
+	}
+	if cluster.tags == nil {
+		cluster.tags = map[string]string{}
+	}
+	for k, v := range tags {
+		cluster.tags[k] = v
+	}
 	return nil
 }
 
-func FakeNew(accessKeyId, secretAccessKey, region string, timeout int) (Client, error) {
-	return fakeClient{
-		region: region,
-	}, nil
+// copyTags copies tags so that descriptions do not change when the bucket or cluster is tagged later.
+func copyTags(tags map[string]string) map[string]string {
+	c := make(map[string]string, len(tags))
+	for k, v := range tags {
+		c[k] = v
+	}
+	return c
 }
@@ -0,0 +1,197 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/matryer/is"
+)
+
+// newTestFakeAccount creates a FakeAccount with a clock that only moves when the client sleeps.
+func newTestFakeAccount(cfg FakeConfig) (*FakeAccount, *time.Time) {
+	now := time.Date(2020, 07, 16, 18, 12, 20, 0, time.UTC)
+	a := NewFakeAccount(cfg)
+	a.now = func() time.Time { return now }
+	a.sleep = func(ctx context.Context, d time.Duration) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		now = now.Add(d)
+		return nil
+	}
+	return a, &now
+}
+
+func TestFakeBuckets(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	a, _ := newTestFakeAccount(DefaultFakeConfig())
+	c, _ := a.New("key", "secret", "eu-west-1", DefaultWaitConfig())
+	other, _ := a.New("key", "secret", "eu-central-1", DefaultWaitConfig())
+
+	region, err := c.CreateBucket(ctx, "my-bucket", "my-resource")
+	is.NoErr(err)
+	is.Equal(region, "eu-west-1")
+
+	region, err = c.CreateBucket(ctx, "my-bucket", "my-resource")
+	is.NoErr(err) // buckets owned by the account are adopted
+	is.Equal(region, "eu-west-1")
+
+	_, err = other.CreateBucket(ctx, "my-bucket", "my-resource")
+	is.Equal(errorCode(err), s3.ErrCodeBucketAlreadyExists) // bucket names are global
+
+	_, err = c.CreateBucket(ctx, "my-bucket", "other-resource")
+	is.True(errors.Is(err, ErrNotOwned)) // but only those created for the same resource
+	is.NoErr(c.TagBucket(ctx, "my-bucket", map[string]string{RetainedTag: "my-resource"}))
+	_, err = c.CreateBucket(ctx, "my-bucket", "my-resource")
+	is.True(errors.Is(err, ErrNotOwned)) // and not retained
+
+	is.NoErr(c.DeleteBucket(ctx, "my-bucket"))
+	is.Equal(errorCode(c.DeleteBucket(ctx, "my-bucket")), s3.ErrCodeNoSuchBucket)
+}
+
+func TestFakeBucketQuota(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	cfg := DefaultFakeConfig()
+	cfg.MaxBuckets = 1
+	a, _ := newTestFakeAccount(cfg)
+	c, _ := a.New("key", "secret", "eu-west-1", DefaultWaitConfig())
+
+	_, err := c.CreateBucket(ctx, "bucket-1", "my-resource")
+	is.NoErr(err)
+	_, err = c.CreateBucket(ctx, "bucket-2", "my-resource")
+	is.Equal(errorCode(err), "TooManyBuckets")
+}
+
+func TestFakeClusterLifecycle(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	cfg := DefaultFakeConfig()
+	cfg.ClusterCreateDelay = 2 * time.Minute
+	cfg.ClusterDeleteDelay = time.Minute
+	a, now := newTestFakeAccount(cfg)
+	c, _ := a.New("key", "secret", "eu-west-1", DefaultWaitConfig())
+	start := *now
+
+	host, err := c.CreateElastiCacheRedis(ctx, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+	is.NoErr(err)
+	is.Equal(host, "redis-1.fake.0001.eu-west-1.cache.amazonaws.com")
+	is.Equal(now.Sub(start), cfg.ClusterCreateDelay) // waited for the cluster to become available
+
+	adopted, err := c.CreateElastiCacheRedis(ctx, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+	is.NoErr(err) // existing clusters are adopted
+	is.Equal(adopted, host)
+	_, err = c.CreateElastiCacheRedis(ctx, "redis-1", "other-resource", "cache.t3.micro", "eu-west-1a")
+	is.True(errors.Is(err, ErrNotOwned)) // but only those created for the same resource
+
+	is.NoErr(c.DeleteElastiCacheRedis(ctx, "redis-1", ""))
+	is.Equal(errorCode(c.DeleteElastiCacheRedis(ctx, "redis-1", "")), elasticache.ErrCodeInvalidCacheClusterStateFault) // still deleting
+
+	_, err = c.CreateElastiCacheRedis(ctx, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+	is.Equal(errorCode(err), elasticache.ErrCodeCacheClusterAlreadyExistsFault) // name is not free until deleted
+	_, err = c.WaitForElastiCacheRedis(ctx, "redis-1")
+	var terr *terminalStatusError
+	is.True(errors.As(err, &terr)) // being deleted, so never available
+	is.Equal(terr.status, "deleting")
+
+	deleting := *now
+	is.NoErr(c.WaitForElastiCacheRedisDeleted(ctx, "redis-1"))
+	is.Equal(now.Sub(deleting), cfg.ClusterDeleteDelay) // waited for the cluster to be deleted
+	is.Equal(errorCode(c.DeleteElastiCacheRedis(ctx, "redis-1", "")), elasticache.ErrCodeCacheClusterNotFoundFault)
+}
+
+func TestFakeClusterTimeout(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	cfg := DefaultFakeConfig()
+	cfg.ClusterCreateDelay = 10 * time.Minute
+	a, now := newTestFakeAccount(cfg)
+	wait := DefaultWaitConfig()
+	wait.DeleteTimeout = time.Minute
+	c, _ := a.New("key", "secret", "eu-west-1", wait)
+	start := *now
+
+	_, err := c.CreateElastiCacheRedis(ctx, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+	is.Equal(errorCode(err), request.WaiterResourceNotReadyErrorCode)
+	is.Equal(now.Sub(start), 5*time.Minute) // gave up after the create timeout
+
+	is.Equal(errorCode(c.DeleteElastiCacheRedis(ctx, "redis-1", "")), elasticache.ErrCodeInvalidCacheClusterStateFault) // still creating
+
+	start = *now
+	is.Equal(errorCode(c.WaitForElastiCacheRedisDeleted(ctx, "redis-1")), request.WaiterResourceNotReadyErrorCode) // not being deleted, so never gone
+	is.Equal(now.Sub(start), time.Minute)                                                                          // gave up after the delete timeout
+}
+
+func TestFakeClusterResume(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	cfg := DefaultFakeConfig()
+	cfg.ClusterCreateDelay = 2 * time.Minute
+	a, _ := newTestFakeAccount(cfg)
+	c, _ := a.New("key", "secret", "eu-west-1", DefaultWaitConfig())
+
+	_, err := c.CreateElastiCacheRedis(cancelled, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+	is.True(errors.Is(err, context.Canceled))
+
+	host, err := c.WaitForElastiCacheRedis(ctx, "redis-1")
+	is.NoErr(err)
+	is.Equal(host, "redis-1.fake.0001.eu-west-1.cache.amazonaws.com")
+
+	_, err = c.WaitForElastiCacheRedis(ctx, "redis-2")
+	is.Equal(errorCode(err), elasticache.ErrCodeCacheClusterNotFoundFault)
+}
+
+func TestFakeClusterCancelled(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cfg := DefaultFakeConfig()
+	cfg.ClusterCreateDelay = 10 * time.Minute
+	a, now := newTestFakeAccount(cfg)
+	c, _ := a.New("key", "secret", "eu-west-1", DefaultWaitConfig())
+	start := *now
+
+	_, err := c.CreateElastiCacheRedis(ctx, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+	is.True(errors.Is(err, context.Canceled))
+	is.Equal(*now, start) // did not wait
+}
+
+func TestFakeClusterQuota(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	cfg := DefaultFakeConfig()
+	cfg.MaxClusters = 1
+	a, _ := newTestFakeAccount(cfg)
+	c, _ := a.New("key", "secret", "eu-west-1", DefaultWaitConfig())
+	other, _ := a.New("key", "secret", "eu-central-1", DefaultWaitConfig())
+
+	_, err := c.CreateElastiCacheRedis(ctx, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+	is.NoErr(err)
+	_, err = c.CreateElastiCacheRedis(ctx, "redis-2", "my-resource", "cache.t3.micro", "eu-west-1a")
+	is.Equal(errorCode(err), elasticache.ErrCodeClusterQuotaForCustomerExceededFault)
+	_, err = other.CreateElastiCacheRedis(ctx, "redis-2", "my-resource", "cache.t3.micro", "eu-central-1a")
+	is.NoErr(err) // quotas are per region
+}
+
+func TestFakeErrors(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	cfg := DefaultFakeConfig()
+	cfg.Errors = map[string]string{"CreateBucket": "InternalError", "DeleteElastiCacheRedis": "InvalidCacheClusterState"}
+	a, _ := newTestFakeAccount(cfg)
+	c, _ := a.New("key", "secret", "eu-west-1", DefaultWaitConfig())
+
+	_, err := c.CreateBucket(ctx, "my-bucket", "my-resource")
+	is.Equal(errorCode(err), "InternalError")
+	is.Equal(errorCode(c.DeleteElastiCacheRedis(ctx, "redis-1", "")), "InvalidCacheClusterState")
+	_, err = c.CreateElastiCacheRedis(ctx, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+	is.NoErr(err) // other operations are not affected
+}
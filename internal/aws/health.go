@@ -0,0 +1,44 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// NewSTSPing returns a function that checks that the STS API in region can be reached, sending requests to endpoint
+// instead of AWS if it is not empty.
+//
+// The driver has no credentials of its own (they are passed with each request) so the check calls
+// GetCallerIdentity anonymously. Any response from AWS, including the expected authentication error, shows that AWS
+// is reachable. Only failures to get a response are reported.
+func NewSTSPing(endpoint, region string) (func(ctx context.Context) error, error) {
+	cfg := &aws.Config{
+		Region:      aws.String(region),
+		Credentials: credentials.AnonymousCredentials,
+		MaxRetries:  aws.Int(0),
+	}
+	if endpoint != "" {
+		cfg.Endpoint = aws.String(endpoint)
+	}
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, fmt.Errorf(`creating aws session: %w`, err)
+	}
+	svc := sts.New(sess)
+	return func(ctx context.Context) error {
+		_, err := svc.GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+		if _, ok := err.(awserr.RequestFailure); ok {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reaching sts: %w", err)
+		}
+		return nil
+	}, nil
+}
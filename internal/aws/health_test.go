@@ -0,0 +1,26 @@
+package aws
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestNewSTSPing(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`<ErrorResponse><Error><Type>Sender</Type><Code>MissingAuthenticationToken</Code><Message>Request is missing Authentication Token</Message></Error><RequestId>1</RequestId></ErrorResponse>`))
+	}))
+
+	ping, err := NewSTSPing(srv.URL, "eu-central-1")
+	is.NoErr(err)
+	is.NoErr(ping(context.Background())) // an error response shows that STS is reachable
+
+	srv.Close()
+	is.True(ping(context.Background()) != nil) // unreachable
+}
@@ -0,0 +1,720 @@
+// Package localaws provides a local HTTP stand-in for the parts of the ElastiCache Query API and the S3 REST API that
+// are used by the driver. It allows the real AWS client code to be exercised without contacting AWS.
+//
+// Both APIs are served from the same endpoint: form-encoded POST requests with an "Action" are treated as ElastiCache
+// requests and everything else as path-style S3 requests. Request signatures are not verified.
+package localaws
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// clusterARNPrefix is prefixed to the ids of clusters to form their ARNs.
+	clusterARNPrefix     = "arn:aws:elasticache:local:000000000000:cluster:"
+	elastiCacheNamespace = "http://elasticache.amazonaws.com/doc/2015-02-02/"
+	s3Namespace          = "http://s3.amazonaws.com/doc/2006-03-01/"
+)
+
+// Bucket describes a bucket held by the server.
+type Bucket struct {
+	Name               string
+	LocationConstraint string
+	CreatedAt          time.Time
+	// Config holds the body of the last PUT to each bucket subresource that is set, keyed by the subresource's query
+	// parameter, e.g. "encryption" or "policy".
+	Config map[string]string
+}
+
+// s3Subresource describes a bucket configuration subresource, such as ?encryption, which is stored as it is PUT.
+type s3Subresource struct {
+	// operation is the suffix of the names of the Put, Get and Delete operations for the subresource.
+	operation string
+	// notFoundCode is returned when getting the subresource before it has been set.
+	notFoundCode string
+	// deleteOperation names the Delete operation if it does not follow the pattern.
+	deleteOperation string
+}
+
+var s3Subresources = map[string]s3Subresource{
+	"cors":              {"BucketCors", "NoSuchCORSConfiguration", ""},
+	"encryption":        {"BucketEncryption", "ServerSideEncryptionConfigurationNotFoundError", ""},
+	"lifecycle":         {"BucketLifecycleConfiguration", "NoSuchLifecycleConfiguration", "DeleteBucketLifecycle"},
+	"ownershipControls": {"BucketOwnershipControls", "OwnershipControlsNotFoundError", ""},
+	"policy":            {"BucketPolicy", "NoSuchBucketPolicy", ""},
+	"publicAccessBlock": {"PublicAccessBlock", "NoSuchPublicAccessBlockConfiguration", ""},
+	"tagging":           {"BucketTagging", "NoSuchTagSet", ""},
+	"versioning":        {"BucketVersioning", "", ""},
+	"website":           {"BucketWebsite", "NoSuchWebsiteConfiguration", ""},
+}
+
+// CacheCluster describes a cache cluster held by the server, including the parameters it was created with.
+type CacheCluster struct {
+	CacheClusterId            string
+	CacheClusterStatus        string
+	CacheNodeType             string
+	CacheSubnetGroupName      string
+	Engine                    string
+	EngineVersion             string
+	NumCacheNodes             int
+	Port                      int
+	PreferredAvailabilityZone string
+	SnapshotRetentionLimit    int
+	AutoMinorVersionUpgrade   bool
+	CreatedAt                 time.Time
+	Tags                      map[string]string
+
+	// transitionAt is when the cluster leaves its current transitional status.
+	transitionAt time.Time
+}
+
+// Server is an http.Handler emulating ElastiCache and S3.
+type Server struct {
+	// ClusterCreateDelay is how long cache clusters stay in the "creating" status.
+	ClusterCreateDelay time.Duration
+
+	// ClusterDeleteDelay is how long cache clusters stay in the "deleting" status.
+	ClusterDeleteDelay time.Duration
+
+	// Errors maps ElastiCache actions (e.g. "CreateCacheCluster") and S3 operations (e.g. "CreateBucket") to the error
+	// code they should fail with.
+	Errors map[string]string
+
+	mu        sync.Mutex
+	now       func() time.Time
+	buckets   map[string]Bucket
+	clusters  map[string]*CacheCluster
+	snapshots map[string]string
+}
+
+// NewServer creates an empty Server.
+func NewServer() *Server {
+	return &Server{
+		Errors:    map[string]string{},
+		now:       time.Now,
+		buckets:   map[string]Bucket{},
+		clusters:  map[string]*CacheCluster{},
+		snapshots: map[string]string{},
+	}
+}
+
+// Bucket returns the bucket with the supplied name, if it exists.
+func (s *Server) Bucket(name string) (Bucket, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, exists := s.buckets[name]
+	config := make(map[string]string, len(b.Config))
+	for k, v := range b.Config {
+		config[k] = v
+	}
+	b.Config = config
+	return b, exists
+}
+
+// CacheCluster returns the cache cluster with the supplied id, if it exists.
+func (s *Server) CacheCluster(id string) (CacheCluster, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refresh()
+	c, exists := s.clusters[id]
+	if !exists {
+		return CacheCluster{}, false
+	}
+	cluster := *c
+	cluster.Tags = make(map[string]string, len(c.Tags))
+	for k, v := range c.Tags {
+		cluster.Tags[k] = v
+	}
+	return cluster, true
+}
+
+// Snapshot returns the id of the cluster a final snapshot with the supplied name was taken of, if it exists.
+func (s *Server) Snapshot(name string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, exists := s.snapshots[name]
+	return id, exists
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Method == http.MethodPost && strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		if err := r.ParseForm(); err != nil {
+			writeElastiCacheError(w, http.StatusBadRequest, "MalformedQueryString", err.Error())
+			return
+		}
+		if action := r.PostForm.Get("Action"); action != "" {
+			log.Printf("localaws: ElastiCache %s", action)
+			s.serveElastiCache(w, action, r)
+			return
+		}
+	}
+	log.Printf("localaws: S3 %s %s", r.Method, r.URL.Path)
+	s.serveS3(w, r)
+}
+
+// refresh moves clusters on from transitional statuses once their time is up. Must be called with the lock held.
+func (s *Server) refresh() {
+	now := s.now()
+	for id, c := range s.clusters {
+		if now.Before(c.transitionAt) {
+			continue
+		}
+		switch c.CacheClusterStatus {
+		case "creating":
+			c.CacheClusterStatus = "available"
+		case "deleting":
+			delete(s.clusters, id)
+		}
+	}
+}
+
+func requestID() string {
+	return uuid.New().String()
+}
+
+//
+// ElastiCache
+//
+
+type elastiCacheError struct {
+	XMLName   xml.Name `xml:"ErrorResponse"`
+	Xmlns     string   `xml:"xmlns,attr"`
+	Type      string   `xml:"Error>Type"`
+	Code      string   `xml:"Error>Code"`
+	Message   string   `xml:"Error>Message"`
+	RequestID string   `xml:"RequestId"`
+}
+
+type xmlEndpoint struct {
+	Address string `xml:"Address"`
+	Port    int    `xml:"Port"`
+}
+
+type xmlCacheNode struct {
+	CacheNodeId     string      `xml:"CacheNodeId"`
+	CacheNodeStatus string      `xml:"CacheNodeStatus"`
+	Endpoint        xmlEndpoint `xml:"Endpoint"`
+}
+
+type xmlCacheCluster struct {
+	ARN                       string         `xml:"ARN"`
+	CacheClusterId            string         `xml:"CacheClusterId"`
+	CacheClusterStatus        string         `xml:"CacheClusterStatus"`
+	CacheNodeType             string         `xml:"CacheNodeType"`
+	Engine                    string         `xml:"Engine"`
+	EngineVersion             string         `xml:"EngineVersion"`
+	NumCacheNodes             int            `xml:"NumCacheNodes"`
+	PreferredAvailabilityZone string         `xml:"PreferredAvailabilityZone"`
+	CacheSubnetGroupName      string         `xml:"CacheSubnetGroupName"`
+	SnapshotRetentionLimit    int            `xml:"SnapshotRetentionLimit"`
+	AutoMinorVersionUpgrade   bool           `xml:"AutoMinorVersionUpgrade"`
+	CacheClusterCreateTime    string         `xml:"CacheClusterCreateTime"`
+	CacheNodes                []xmlCacheNode `xml:"CacheNodes>CacheNode,omitempty"`
+}
+
+type elastiCacheResponse struct {
+	XMLName   xml.Name
+	Xmlns     string      `xml:"xmlns,attr"`
+	Result    interface{} `xml:",omitempty"`
+	RequestID string      `xml:"ResponseMetadata>RequestId"`
+}
+
+type cacheClusterResult struct {
+	XMLName      xml.Name
+	CacheCluster xmlCacheCluster `xml:"CacheCluster"`
+}
+
+type xmlTag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+type tagListResult struct {
+	XMLName xml.Name
+	TagList []xmlTag `xml:"TagList>Tag"`
+}
+
+type cacheClustersResult struct {
+	XMLName       xml.Name
+	CacheClusters []xmlCacheCluster `xml:"CacheClusters>CacheCluster"`
+}
+
+func writeElastiCacheError(w http.ResponseWriter, statusCode int, code, message string) {
+	writeXML(w, statusCode, elastiCacheError{
+		Xmlns:     elastiCacheNamespace,
+		Type:      "Sender",
+		Code:      code,
+		Message:   message,
+		RequestID: requestID(),
+	})
+}
+
+func writeElastiCacheResult(w http.ResponseWriter, action string, result interface{}) {
+	writeXML(w, http.StatusOK, elastiCacheResponse{
+		XMLName:   xml.Name{Local: action + "Response"},
+		Xmlns:     elastiCacheNamespace,
+		Result:    result,
+		RequestID: requestID(),
+	})
+}
+
+func (s *Server) serveElastiCache(w http.ResponseWriter, action string, r *http.Request) {
+	if code, ok := s.Errors[action]; ok {
+		writeElastiCacheError(w, http.StatusBadRequest, code, fmt.Sprintf("injected error for %s", action))
+		return
+	}
+	s.refresh()
+
+	switch action {
+	case "CreateCacheCluster":
+		s.createCacheCluster(w, r)
+	case "DescribeCacheClusters":
+		s.describeCacheClusters(w, r)
+	case "DeleteCacheCluster":
+		s.deleteCacheCluster(w, r)
+	case "AddTagsToResource":
+		s.addTagsToResource(w, r)
+	case "ListTagsForResource":
+		s.listTagsForResource(w, r)
+	default:
+		writeElastiCacheError(w, http.StatusBadRequest, "InvalidAction", fmt.Sprintf("action %s is not supported", action))
+	}
+}
+
+func (s *Server) toXMLCacheCluster(c *CacheCluster, showNodes bool) xmlCacheCluster {
+	x := xmlCacheCluster{
+		ARN:                       clusterARNPrefix + c.CacheClusterId,
+		CacheClusterId:            c.CacheClusterId,
+		CacheClusterStatus:        c.CacheClusterStatus,
+		CacheNodeType:             c.CacheNodeType,
+		Engine:                    c.Engine,
+		EngineVersion:             c.EngineVersion,
+		NumCacheNodes:             c.NumCacheNodes,
+		PreferredAvailabilityZone: c.PreferredAvailabilityZone,
+		CacheSubnetGroupName:      c.CacheSubnetGroupName,
+		SnapshotRetentionLimit:    c.SnapshotRetentionLimit,
+		AutoMinorVersionUpgrade:   c.AutoMinorVersionUpgrade,
+		CacheClusterCreateTime:    c.CreatedAt.Format(time.RFC3339),
+	}
+	if showNodes && c.CacheClusterStatus != "creating" {
+		for i := 1; i <= c.NumCacheNodes; i++ {
+			nodeID := fmt.Sprintf("%04d", i)
+			x.CacheNodes = append(x.CacheNodes, xmlCacheNode{
+				CacheNodeId:     nodeID,
+				CacheNodeStatus: c.CacheClusterStatus,
+				Endpoint: xmlEndpoint{
+					Address: fmt.Sprintf("%s.local.%s.cache.localhost", c.CacheClusterId, nodeID),
+					Port:    c.Port,
+				},
+			})
+		}
+	}
+	return x
+}
+
+func formInt(r *http.Request, name string, defaultValue int) (int, error) {
+	value := r.PostForm.Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func (s *Server) createCacheCluster(w http.ResponseWriter, r *http.Request) {
+	id := strings.ToLower(r.PostForm.Get("CacheClusterId"))
+	if id == "" {
+		writeElastiCacheError(w, http.StatusBadRequest, "InvalidParameterValue", "CacheClusterId is required")
+		return
+	}
+	if _, exists := s.clusters[id]; exists {
+		writeElastiCacheError(w, http.StatusBadRequest, "CacheClusterAlreadyExists", fmt.Sprintf("cache cluster %s already exists", id))
+		return
+	}
+	engine := r.PostForm.Get("Engine")
+	if engine != "redis" && engine != "memcached" {
+		writeElastiCacheError(w, http.StatusBadRequest, "InvalidParameterValue", fmt.Sprintf("engine %s is not supported", engine))
+		return
+	}
+
+	numCacheNodes, err := formInt(r, "NumCacheNodes", 1)
+	if err != nil {
+		writeElastiCacheError(w, http.StatusBadRequest, "InvalidParameterValue", "NumCacheNodes must be an integer")
+		return
+	}
+	port, err := formInt(r, "Port", 6379)
+	if err != nil {
+		writeElastiCacheError(w, http.StatusBadRequest, "InvalidParameterValue", "Port must be an integer")
+		return
+	}
+	snapshotRetentionLimit, err := formInt(r, "SnapshotRetentionLimit", 0)
+	if err != nil {
+		writeElastiCacheError(w, http.StatusBadRequest, "InvalidParameterValue", "SnapshotRetentionLimit must be an integer")
+		return
+	}
+
+	now := s.now()
+	c := &CacheCluster{
+		CacheClusterId:            id,
+		CacheClusterStatus:        "creating",
+		CacheNodeType:             r.PostForm.Get("CacheNodeType"),
+		CacheSubnetGroupName:      r.PostForm.Get("CacheSubnetGroupName"),
+		Engine:                    engine,
+		EngineVersion:             r.PostForm.Get("EngineVersion"),
+		NumCacheNodes:             numCacheNodes,
+		Port:                      port,
+		PreferredAvailabilityZone: r.PostForm.Get("PreferredAvailabilityZone"),
+		SnapshotRetentionLimit:    snapshotRetentionLimit,
+		AutoMinorVersionUpgrade:   r.PostForm.Get("AutoMinorVersionUpgrade") == "true",
+		Tags:                      formTags(r),
+		CreatedAt:                 now,
+		transitionAt:              now.Add(s.ClusterCreateDelay),
+	}
+	s.clusters[id] = c
+
+	writeElastiCacheResult(w, "CreateCacheCluster", cacheClusterResult{
+		XMLName:      xml.Name{Local: "CreateCacheClusterResult"},
+		CacheCluster: s.toXMLCacheCluster(c, false),
+	})
+}
+
+func (s *Server) describeCacheClusters(w http.ResponseWriter, r *http.Request) {
+	showNodes := r.PostForm.Get("ShowCacheNodeInfo") == "true"
+	result := cacheClustersResult{
+		XMLName: xml.Name{Local: "DescribeCacheClustersResult"},
+	}
+	if id := strings.ToLower(r.PostForm.Get("CacheClusterId")); id != "" {
+		c, exists := s.clusters[id]
+		if !exists {
+			writeElastiCacheError(w, http.StatusNotFound, "CacheClusterNotFound", fmt.Sprintf("cache cluster %s not found", id))
+			return
+		}
+		result.CacheClusters = append(result.CacheClusters, s.toXMLCacheCluster(c, showNodes))
+	} else {
+		for _, c := range s.clusters {
+			result.CacheClusters = append(result.CacheClusters, s.toXMLCacheCluster(c, showNodes))
+		}
+	}
+	writeElastiCacheResult(w, "DescribeCacheClusters", result)
+}
+
+func (s *Server) deleteCacheCluster(w http.ResponseWriter, r *http.Request) {
+	id := strings.ToLower(r.PostForm.Get("CacheClusterId"))
+	c, exists := s.clusters[id]
+	if !exists {
+		writeElastiCacheError(w, http.StatusNotFound, "CacheClusterNotFound", fmt.Sprintf("cache cluster %s not found", id))
+		return
+	}
+	if c.CacheClusterStatus != "available" {
+		writeElastiCacheError(w, http.StatusBadRequest, "InvalidCacheClusterState", fmt.Sprintf("cache cluster %s is %s", id, c.CacheClusterStatus))
+		return
+	}
+	if snapshot := r.PostForm.Get("FinalSnapshotIdentifier"); snapshot != "" {
+		if _, exists := s.snapshots[snapshot]; exists {
+			writeElastiCacheError(w, http.StatusBadRequest, "SnapshotAlreadyExistsFault", fmt.Sprintf("snapshot %s already exists", snapshot))
+			return
+		}
+		s.snapshots[snapshot] = id
+	}
+	c.CacheClusterStatus = "deleting"
+	c.transitionAt = s.now().Add(s.ClusterDeleteDelay)
+
+	writeElastiCacheResult(w, "DeleteCacheCluster", cacheClusterResult{
+		XMLName:      xml.Name{Local: "DeleteCacheClusterResult"},
+		CacheCluster: s.toXMLCacheCluster(c, false),
+	})
+}
+
+// formTags returns the tags in the Tags parameter of a request. It is nil if there are none.
+func formTags(r *http.Request) map[string]string {
+	var tags map[string]string
+	for i := 1; r.PostForm.Get(fmt.Sprintf("Tags.Tag.%d.Key", i)) != ""; i++ {
+		if tags == nil {
+			tags = map[string]string{}
+		}
+		tags[r.PostForm.Get(fmt.Sprintf("Tags.Tag.%d.Key", i))] = r.PostForm.Get(fmt.Sprintf("Tags.Tag.%d.Value", i))
+	}
+	return tags
+}
+
+// taggedCluster returns the cluster named by the ARN in the ResourceName parameter of a request. It writes an error if
+// there is no such cluster.
+func (s *Server) taggedCluster(w http.ResponseWriter, r *http.Request) (*CacheCluster, bool) {
+	arn := r.PostForm.Get("ResourceName")
+	c, exists := s.clusters[strings.TrimPrefix(arn, clusterARNPrefix)]
+	if !strings.HasPrefix(arn, clusterARNPrefix) || !exists {
+		writeElastiCacheError(w, http.StatusNotFound, "CacheClusterNotFound", fmt.Sprintf("cache cluster %s not found", arn))
+		return nil, false
+	}
+	return c, true
+}
+
+func writeTagListResult(w http.ResponseWriter, action string, c *CacheCluster) {
+	result := tagListResult{XMLName: xml.Name{Local: action + "Result"}}
+	for k, v := range c.Tags {
+		result.TagList = append(result.TagList, xmlTag{Key: k, Value: v})
+	}
+	writeElastiCacheResult(w, action, result)
+}
+
+func (s *Server) addTagsToResource(w http.ResponseWriter, r *http.Request) {
+	c, ok := s.taggedCluster(w, r)
+	if !ok {
+		return
+	}
+	if c.Tags == nil {
+		c.Tags = map[string]string{}
+	}
+	for k, v := range formTags(r) {
+		c.Tags[k] = v
+	}
+	writeTagListResult(w, "AddTagsToResource", c)
+}
+
+func (s *Server) listTagsForResource(w http.ResponseWriter, r *http.Request) {
+	c, ok := s.taggedCluster(w, r)
+	if !ok {
+		return
+	}
+	writeTagListResult(w, "ListTagsForResource", c)
+}
+
+//
+// S3
+//
+
+type s3Error struct {
+	XMLName    xml.Name `xml:"Error"`
+	Code       string   `xml:"Code"`
+	Message    string   `xml:"Message"`
+	BucketName string   `xml:"BucketName,omitempty"`
+	RequestID  string   `xml:"RequestId"`
+}
+
+type createBucketConfiguration struct {
+	LocationConstraint string `xml:"LocationConstraint"`
+}
+
+type locationConstraint struct {
+	XMLName            xml.Name `xml:"LocationConstraint"`
+	Xmlns              string   `xml:"xmlns,attr"`
+	LocationConstraint string   `xml:",chardata"`
+}
+
+func writeS3Error(w http.ResponseWriter, statusCode int, code, message, bucket string) {
+	writeXML(w, statusCode, s3Error{
+		Code:       code,
+		Message:    message,
+		BucketName: bucket,
+		RequestID:  requestID(),
+	})
+}
+
+// s3Operation names the S3 operation for a request so that errors can be injected.
+func s3Operation(r *http.Request, key string) string {
+	if key != "" {
+		return ""
+	}
+	if name := bucketSubresource(r); name == "location" && r.Method == http.MethodGet {
+		return "GetBucketLocation"
+	} else if name != "" {
+		sub, ok := s3Subresources[name]
+		if !ok {
+			return ""
+		}
+		switch r.Method {
+		case http.MethodPut:
+			return "Put" + sub.operation
+		case http.MethodGet:
+			return "Get" + sub.operation
+		case http.MethodDelete:
+			if sub.deleteOperation != "" {
+				return sub.deleteOperation
+			}
+			return "Delete" + sub.operation
+		}
+		return ""
+	}
+	switch r.Method {
+	case http.MethodPut:
+		return "CreateBucket"
+	case http.MethodDelete:
+		return "DeleteBucket"
+	case http.MethodHead:
+		return "HeadBucket"
+	}
+	return ""
+}
+
+// bucketSubresource returns the subresource named in the query string, e.g. "encryption" for PUT /bucket?encryption.
+func bucketSubresource(r *http.Request) string {
+	for name := range r.URL.Query() {
+		return name
+	}
+	return ""
+}
+
+func (s *Server) serveS3(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	bucket := parts[0]
+	key := ""
+	if len(parts) > 1 {
+		key = parts[1]
+	}
+	if bucket == "" {
+		writeS3Error(w, http.StatusNotImplemented, "NotImplemented", "listing buckets is not supported", "")
+		return
+	}
+
+	operation := s3Operation(r, key)
+	if operation == "" {
+		writeS3Error(w, http.StatusNotImplemented, "NotImplemented", fmt.Sprintf("%s %s is not supported", r.Method, r.URL.Path), bucket)
+		return
+	}
+	if code, ok := s.Errors[operation]; ok {
+		writeS3Error(w, http.StatusBadRequest, code, fmt.Sprintf("injected error for %s", operation), bucket)
+		return
+	}
+
+	if operation == "GetBucketLocation" {
+		s.getBucketLocation(w, bucket)
+		return
+	}
+	if name := bucketSubresource(r); name != "" {
+		s.serveBucketSubresource(w, r, bucket, name)
+		return
+	}
+
+	switch operation {
+	case "CreateBucket":
+		s.createBucket(w, r, bucket)
+	case "DeleteBucket":
+		s.deleteBucket(w, bucket)
+	case "HeadBucket":
+		if _, exists := s.buckets[bucket]; !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) createBucket(w http.ResponseWriter, r *http.Request, bucket string) {
+	if _, exists := s.buckets[bucket]; exists {
+		writeS3Error(w, http.StatusConflict, "BucketAlreadyOwnedByYou", "your previous request to create the named bucket succeeded and you already own it", bucket)
+		return
+	}
+
+	var cfg createBucketConfiguration
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeS3Error(w, http.StatusBadRequest, "IncompleteBody", err.Error(), bucket)
+		return
+	}
+	if len(body) > 0 {
+		if err := xml.Unmarshal(body, &cfg); err != nil {
+			writeS3Error(w, http.StatusBadRequest, "MalformedXML", err.Error(), bucket)
+			return
+		}
+	}
+
+	s.buckets[bucket] = Bucket{
+		Name:               bucket,
+		LocationConstraint: cfg.LocationConstraint,
+		CreatedAt:          s.now(),
+	}
+	w.Header().Set("Location", "/"+bucket)
+	w.WriteHeader(http.StatusOK)
+}
+
+// getBucketLocation returns the bucket's LocationConstraint, which is empty for buckets in us-east-1.
+func (s *Server) getBucketLocation(w http.ResponseWriter, bucket string) {
+	b, exists := s.buckets[bucket]
+	if !exists {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket", "the specified bucket does not exist", bucket)
+		return
+	}
+	writeXML(w, http.StatusOK, locationConstraint{
+		Xmlns:              s3Namespace,
+		LocationConstraint: b.LocationConstraint,
+	})
+}
+
+func (s *Server) deleteBucket(w http.ResponseWriter, bucket string) {
+	if _, exists := s.buckets[bucket]; !exists {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket", "the specified bucket does not exist", bucket)
+		return
+	}
+	delete(s.buckets, bucket)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) serveBucketSubresource(w http.ResponseWriter, r *http.Request, bucket, name string) {
+	b, exists := s.buckets[bucket]
+	if !exists {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket", "the specified bucket does not exist", bucket)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeS3Error(w, http.StatusBadRequest, "IncompleteBody", err.Error(), bucket)
+			return
+		}
+		if b.Config == nil {
+			b.Config = map[string]string{}
+		}
+		b.Config[name] = string(body)
+		s.buckets[bucket] = b
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		config, ok := b.Config[name]
+		if !ok && name == "versioning" {
+			// Buckets that have never been versioned have an empty configuration.
+			config, ok = `<VersioningConfiguration xmlns="`+s3Namespace+`"></VersioningConfiguration>`, true
+		}
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, s3Subresources[name].notFoundCode, fmt.Sprintf("the bucket has no %s configuration", name), bucket)
+			return
+		}
+		if name == "policy" {
+			w.Header().Set("Content-Type", "application/json")
+		} else {
+			w.Header().Set("Content-Type", "text/xml")
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(config))
+	case http.MethodDelete:
+		delete(b.Config, name)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func writeXML(w http.ResponseWriter, statusCode int, obj interface{}) {
+	body, err := xml.Marshal(obj)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(statusCode)
+	w.Write([]byte(xml.Header))
+	w.Write(body)
+}
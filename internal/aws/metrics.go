@@ -0,0 +1,40 @@
+package aws
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/elasticache"
+	"humanitec.io/resources/driver-aws-external/internal/metrics"
+)
+
+// metricsHandler counts every AWS API call made through a session, along with its AWS error code if it failed. It runs
+// once per call, after any retries.
+var metricsHandler = request.NamedHandler{
+	Name: "driver.metrics",
+	Fn: func(r *request.Request) {
+		service := r.ClientInfo.ServiceName
+		operation := r.Operation.Name
+		metrics.AWSAPICalls.WithLabelValues(service, operation).Inc()
+		if r.Error != nil {
+			code := "unknown"
+			var aerr awserr.Error
+			if errors.As(r.Error, &aerr) {
+				code = aerr.Code()
+			}
+			metrics.AWSAPIErrors.WithLabelValues(service, operation, code).Inc()
+		}
+	},
+}
+
+// countPollIterations is a request.Option for DescribeCacheClusters calls made while waiting for a cluster. It counts
+// each call by the status of the cluster that was returned.
+func countPollIterations(r *request.Request) {
+	r.Handlers.Complete.PushBack(func(r *request.Request) {
+		if out, ok := r.Data.(*elasticache.DescribeCacheClustersOutput); ok && r.Error == nil && len(out.CacheClusters) > 0 {
+			metrics.ElastiCachePollIterations.WithLabelValues(aws.StringValue(out.CacheClusters[0].CacheClusterStatus)).Inc()
+		}
+	})
+}
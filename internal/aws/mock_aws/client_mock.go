@@ -5,7 +5,9 @@
 package mock_aws
 
 import (
+	context "context"
 	gomock "github.com/golang/mock/gomock"
+	aws "humanitec.io/resources/driver-aws-external/internal/aws"
 	reflect "reflect"
 )
 
@@ -32,60 +34,190 @@ func (m *MockClient) EXPECT() *MockClientMockRecorder {
 	return m.recorder
 }
 
+// BucketRegion mocks base method
+func (m *MockClient) BucketRegion(arg0 context.Context, arg1 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BucketRegion", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BucketRegion indicates an expected call of BucketRegion
+func (mr *MockClientMockRecorder) BucketRegion(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BucketRegion", reflect.TypeOf((*MockClient)(nil).BucketRegion), arg0, arg1)
+}
+
+// ConfigureBucket mocks base method
+func (m *MockClient) ConfigureBucket(arg0 context.Context, arg1 string, arg2 aws.BucketConfig) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfigureBucket", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ConfigureBucket indicates an expected call of ConfigureBucket
+func (mr *MockClientMockRecorder) ConfigureBucket(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfigureBucket", reflect.TypeOf((*MockClient)(nil).ConfigureBucket), arg0, arg1, arg2)
+}
+
 // CreateBucket mocks base method
-func (m *MockClient) CreateBucket(arg0 string) (string, error) {
+func (m *MockClient) CreateBucket(arg0 context.Context, arg1, arg2 string) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateBucket", arg0)
+	ret := m.ctrl.Call(m, "CreateBucket", arg0, arg1, arg2)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateBucket indicates an expected call of CreateBucket
-func (mr *MockClientMockRecorder) CreateBucket(arg0 interface{}) *gomock.Call {
+func (mr *MockClientMockRecorder) CreateBucket(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBucket", reflect.TypeOf((*MockClient)(nil).CreateBucket), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBucket", reflect.TypeOf((*MockClient)(nil).CreateBucket), arg0, arg1, arg2)
 }
 
 // CreateElastiCacheRedis mocks base method
-func (m *MockClient) CreateElastiCacheRedis(arg0, arg1, arg2 string) (string, error) {
+func (m *MockClient) CreateElastiCacheRedis(arg0 context.Context, arg1, arg2, arg3, arg4 string) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateElastiCacheRedis", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "CreateElastiCacheRedis", arg0, arg1, arg2, arg3, arg4)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateElastiCacheRedis indicates an expected call of CreateElastiCacheRedis
-func (mr *MockClientMockRecorder) CreateElastiCacheRedis(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockClientMockRecorder) CreateElastiCacheRedis(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateElastiCacheRedis", reflect.TypeOf((*MockClient)(nil).CreateElastiCacheRedis), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateElastiCacheRedis", reflect.TypeOf((*MockClient)(nil).CreateElastiCacheRedis), arg0, arg1, arg2, arg3, arg4)
 }
 
 // DeleteBucket mocks base method
-func (m *MockClient) DeleteBucket(arg0 string) error {
+func (m *MockClient) DeleteBucket(arg0 context.Context, arg1 string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteBucket", arg0)
+	ret := m.ctrl.Call(m, "DeleteBucket", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteBucket indicates an expected call of DeleteBucket
-func (mr *MockClientMockRecorder) DeleteBucket(arg0 interface{}) *gomock.Call {
+func (mr *MockClientMockRecorder) DeleteBucket(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBucket", reflect.TypeOf((*MockClient)(nil).DeleteBucket), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBucket", reflect.TypeOf((*MockClient)(nil).DeleteBucket), arg0, arg1)
 }
 
 // DeleteElastiCacheRedis mocks base method
-func (m *MockClient) DeleteElastiCacheRedis(arg0 string) error {
+func (m *MockClient) DeleteElastiCacheRedis(arg0 context.Context, arg1, arg2 string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteElastiCacheRedis", arg0)
+	ret := m.ctrl.Call(m, "DeleteElastiCacheRedis", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteElastiCacheRedis indicates an expected call of DeleteElastiCacheRedis
-func (mr *MockClientMockRecorder) DeleteElastiCacheRedis(arg0 interface{}) *gomock.Call {
+func (mr *MockClientMockRecorder) DeleteElastiCacheRedis(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteElastiCacheRedis", reflect.TypeOf((*MockClient)(nil).DeleteElastiCacheRedis), arg0, arg1, arg2)
+}
+
+// DescribeBucket mocks base method
+func (m *MockClient) DescribeBucket(arg0 context.Context, arg1 string) (aws.Bucket, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DescribeBucket", arg0, arg1)
+	ret0, _ := ret[0].(aws.Bucket)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DescribeBucket indicates an expected call of DescribeBucket
+func (mr *MockClientMockRecorder) DescribeBucket(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeBucket", reflect.TypeOf((*MockClient)(nil).DescribeBucket), arg0, arg1)
+}
+
+// DescribeElastiCacheRedis mocks base method
+func (m *MockClient) DescribeElastiCacheRedis(arg0 context.Context, arg1 string) (aws.CacheCluster, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DescribeElastiCacheRedis", arg0, arg1)
+	ret0, _ := ret[0].(aws.CacheCluster)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DescribeElastiCacheRedis indicates an expected call of DescribeElastiCacheRedis
+func (mr *MockClientMockRecorder) DescribeElastiCacheRedis(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeElastiCacheRedis", reflect.TypeOf((*MockClient)(nil).DescribeElastiCacheRedis), arg0, arg1)
+}
+
+// SecureBucket mocks base method
+func (m *MockClient) SecureBucket(arg0 context.Context, arg1 string, arg2 aws.BucketSecurity) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SecureBucket", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SecureBucket indicates an expected call of SecureBucket
+func (mr *MockClientMockRecorder) SecureBucket(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SecureBucket", reflect.TypeOf((*MockClient)(nil).SecureBucket), arg0, arg1, arg2)
+}
+
+// TagBucket mocks base method
+func (m *MockClient) TagBucket(arg0 context.Context, arg1 string, arg2 map[string]string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TagBucket", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TagBucket indicates an expected call of TagBucket
+func (mr *MockClientMockRecorder) TagBucket(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TagBucket", reflect.TypeOf((*MockClient)(nil).TagBucket), arg0, arg1, arg2)
+}
+
+// TagElastiCacheRedis mocks base method
+func (m *MockClient) TagElastiCacheRedis(arg0 context.Context, arg1 string, arg2 map[string]string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TagElastiCacheRedis", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TagElastiCacheRedis indicates an expected call of TagElastiCacheRedis
+func (mr *MockClientMockRecorder) TagElastiCacheRedis(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TagElastiCacheRedis", reflect.TypeOf((*MockClient)(nil).TagElastiCacheRedis), arg0, arg1, arg2)
+}
+
+// WaitForElastiCacheRedis mocks base method
+func (m *MockClient) WaitForElastiCacheRedis(arg0 context.Context, arg1 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WaitForElastiCacheRedis", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WaitForElastiCacheRedis indicates an expected call of WaitForElastiCacheRedis
+func (mr *MockClientMockRecorder) WaitForElastiCacheRedis(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WaitForElastiCacheRedis", reflect.TypeOf((*MockClient)(nil).WaitForElastiCacheRedis), arg0, arg1)
+}
+
+// WaitForElastiCacheRedisDeleted mocks base method
+func (m *MockClient) WaitForElastiCacheRedisDeleted(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WaitForElastiCacheRedisDeleted", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WaitForElastiCacheRedisDeleted indicates an expected call of WaitForElastiCacheRedisDeleted
+func (mr *MockClientMockRecorder) WaitForElastiCacheRedisDeleted(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteElastiCacheRedis", reflect.TypeOf((*MockClient)(nil).DeleteElastiCacheRedis), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WaitForElastiCacheRedisDeleted", reflect.TypeOf((*MockClient)(nil).WaitForElastiCacheRedisDeleted), arg0, arg1)
 }
@@ -0,0 +1,137 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"humanitec.io/resources/driver-aws-external/internal/logging"
+)
+
+const (
+	// OwnerTag is the tag added to buckets and clusters when they are created. Its value is the ID of the resource they
+	// were created for.
+	OwnerTag = "humanitec:resource-id"
+	// RetainedTag is the tag added to buckets and clusters that are retained when their resource is deleted. Its value is
+	// the ID of the resource.
+	RetainedTag = "humanitec:retained-from"
+)
+
+// ErrNotOwned is returned when creating a bucket or cluster that already exists but was not created for the resource it
+// is created for now.
+var ErrNotOwned = errors.New("already exists and belongs to another resource")
+
+// ownedBy reports whether a bucket or cluster with the supplied tags was created for, and is still managed by, the
+// resource with the supplied ID. Buckets and clusters retained when their resource was deleted are not managed any
+// more, even if a resource with the same ID is created again.
+func ownedBy(tags map[string]string, resourceID string) bool {
+	_, retained := tags[RetainedTag]
+	return tags[OwnerTag] == resourceID && !retained
+}
+
+// ManagedByOther reports whether a bucket or cluster with the supplied tags is managed by the driver as a resource
+// other than the one with the supplied ID. Buckets and clusters retained when their resource was deleted are not managed
+// any more, unless they have been imported as another resource since.
+func ManagedByOther(tags map[string]string, resourceID string) bool {
+	owner, owned := tags[OwnerTag]
+	return owned && owner != resourceID && tags[RetainedTag] != owner
+}
+
+// noSuchTagSet is the error S3 returns when getting the tags of a bucket that has none. The SDK has no constant for it.
+const noSuchTagSet = "NoSuchTagSet"
+
+// sortedKeys returns the keys of tags in order, so that tags are always sent in the same order.
+func sortedKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// TagBucket adds tags to a bucket. S3 replaces the whole tag set of a bucket, so the existing tags are read first and
+// kept unless they are overwritten.
+func (c awsClient) TagBucket(ctx context.Context, bucketName string, tags map[string]string) error {
+	l := logging.FromContext(ctx).WithField("bucket", bucketName)
+	svc := s3.New(c.sess)
+
+	merged, err := c.bucketTags(ctx, bucketName)
+	if err != nil {
+		return err
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+
+	var tagSet []*s3.Tag
+	for _, k := range sortedKeys(merged) {
+		tagSet = append(tagSet, &s3.Tag{Key: aws.String(k), Value: aws.String(merged[k])})
+	}
+	if _, err := svc.PutBucketTaggingWithContext(ctx, &s3.PutBucketTaggingInput{
+		Bucket:  aws.String(bucketName),
+		Tagging: &s3.Tagging{TagSet: tagSet},
+	}); err != nil {
+		l.WithError(err).Error("Error tagging s3 bucket")
+		return fmt.Errorf(`tagging s3 bucket "%s": %w`, bucketName, err)
+	}
+	return nil
+}
+
+// bucketTags returns the tags of a bucket.
+func (c awsClient) bucketTags(ctx context.Context, bucketName string) (map[string]string, error) {
+	svc := s3.New(c.sess)
+	tags := map[string]string{}
+	out, err := svc.GetBucketTaggingWithContext(ctx, &s3.GetBucketTaggingInput{Bucket: aws.String(bucketName)})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == noSuchTagSet {
+		return tags, nil
+	}
+	if err != nil {
+		logging.FromContext(ctx).WithField("bucket", bucketName).WithError(err).Error("Error getting s3 bucket tags")
+		return nil, fmt.Errorf(`getting tags of s3 bucket "%s": %w`, bucketName, err)
+	}
+	for _, t := range out.TagSet {
+		tags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+	return tags, nil
+}
+
+// TagElastiCacheRedis adds tags to a cluster. ElastiCache tags resources by ARN, which is looked up first.
+func (c awsClient) TagElastiCacheRedis(ctx context.Context, clusterId string, tags map[string]string) error {
+	l := logging.FromContext(ctx).WithField("cluster_id", clusterId)
+	cluster, err := c.DescribeElastiCacheRedis(ctx, clusterId)
+	if err != nil {
+		return err
+	}
+
+	input := &elasticache.AddTagsToResourceInput{ResourceName: aws.String(cluster.ARN)}
+	for _, k := range sortedKeys(tags) {
+		input.Tags = append(input.Tags, &elasticache.Tag{Key: aws.String(k), Value: aws.String(tags[k])})
+	}
+	svc := elasticache.New(c.sess)
+	if _, err := svc.AddTagsToResourceWithContext(ctx, input); err != nil {
+		l.WithError(err).Error("Error tagging Elasticache cluster")
+		return fmt.Errorf(`tagging Elasticache cluster "%s": %w`, clusterId, err)
+	}
+	return nil
+}
+
+// elastiCacheTags returns the tags of the cluster with the supplied ARN.
+func (c awsClient) elastiCacheTags(ctx context.Context, clusterId, arn string) (map[string]string, error) {
+	svc := elasticache.New(c.sess)
+	out, err := svc.ListTagsForResourceWithContext(ctx, &elasticache.ListTagsForResourceInput{ResourceName: aws.String(arn)})
+	if err != nil {
+		logging.FromContext(ctx).WithField("cluster_id", clusterId).WithError(err).Error("Error listing Elasticache cluster tags")
+		return nil, fmt.Errorf(`listing tags of Elasticache cluster "%s": %w`, clusterId, err)
+	}
+	tags := map[string]string{}
+	for _, t := range out.TagList {
+		tags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+	return tags, nil
+}
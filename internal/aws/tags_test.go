@@ -0,0 +1,90 @@
+package aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/matryer/is"
+)
+
+func TestTagBucket(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, server := newTestClient(t, 1)
+	_, err := c.CreateBucket(ctx, "my-bucket", "my-resource")
+	is.NoErr(err)
+
+	is.NoErr(c.TagBucket(ctx, "my-bucket", map[string]string{"team": "shop"}))
+	is.NoErr(c.TagBucket(ctx, "my-bucket", map[string]string{"humanitec:retained-from": "my-resource"}))
+
+	bucket, _ := server.Bucket("my-bucket")
+	is.Equal(bucketTagging(t, bucket), map[string]string{ // existing tags are kept
+		"humanitec:resource-id":   "my-resource",
+		"team":                    "shop",
+		"humanitec:retained-from": "my-resource",
+	})
+
+	is.Equal(errorCode(c.TagBucket(ctx, "other-bucket", map[string]string{"team": "shop"})), s3.ErrCodeNoSuchBucket)
+}
+
+func TestManagedByOther(t *testing.T) {
+	for name, tc := range map[string]struct {
+		tags     map[string]string
+		expected bool
+	}{
+		"untagged":                {tags: map[string]string{"team": "platform"}, expected: false},
+		"same resource":           {tags: map[string]string{OwnerTag: "my-resource"}, expected: false},
+		"other resource":          {tags: map[string]string{OwnerTag: "other-resource"}, expected: true},
+		"retained":                {tags: map[string]string{OwnerTag: "other-resource", RetainedTag: "other-resource"}, expected: false},
+		"imported after retained": {tags: map[string]string{OwnerTag: "other-resource", RetainedTag: "old-resource"}, expected: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(ManagedByOther(tc.tags, "my-resource"), tc.expected)
+		})
+	}
+}
+
+func TestTagElastiCacheRedis(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	c, server := newTestClient(t, 1)
+	_, err := c.CreateElastiCacheRedis(ctx, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+	is.NoErr(err)
+
+	is.NoErr(c.TagElastiCacheRedis(ctx, "redis-1", map[string]string{"team": "shop"}))
+	is.NoErr(c.TagElastiCacheRedis(ctx, "redis-1", map[string]string{"humanitec:retained-from": "my-resource"}))
+
+	cluster, _ := server.CacheCluster("redis-1")
+	is.Equal(cluster.Tags, map[string]string{"humanitec:resource-id": "my-resource", "team": "shop", "humanitec:retained-from": "my-resource"})
+
+	is.Equal(errorCode(c.TagElastiCacheRedis(ctx, "redis-2", map[string]string{"team": "shop"})), elasticache.ErrCodeCacheClusterNotFoundFault)
+}
+
+func TestFakeTagsAndSnapshots(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	a, _ := newTestFakeAccount(DefaultFakeConfig())
+	c, _ := a.New("key", "secret", "eu-west-1", DefaultWaitConfig())
+
+	_, err := c.CreateBucket(ctx, "my-bucket", "my-resource")
+	is.NoErr(err)
+	is.NoErr(c.TagBucket(ctx, "my-bucket", map[string]string{"team": "shop"}))
+	is.NoErr(c.TagBucket(ctx, "my-bucket", map[string]string{"humanitec:retained-from": "my-resource"}))
+	is.Equal(a.buckets["my-bucket"].tags, map[string]string{"humanitec:resource-id": "my-resource", "team": "shop", "humanitec:retained-from": "my-resource"})
+
+	_, err = c.CreateElastiCacheRedis(ctx, "redis-1", "my-resource", "cache.t3.micro", "eu-west-1a")
+	is.NoErr(err)
+	is.NoErr(c.TagElastiCacheRedis(ctx, "redis-1", map[string]string{"team": "shop"}))
+	is.Equal(a.clusters[clusterKey("eu-west-1", "redis-1")].tags, map[string]string{"humanitec:resource-id": "my-resource", "team": "shop"})
+
+	is.NoErr(c.DeleteElastiCacheRedis(ctx, "redis-1", "redis-1-final"))
+	is.Equal(a.snapshots[clusterKey("eu-west-1", "redis-1-final")], "redis-1")
+
+	_, err = c.CreateElastiCacheRedis(ctx, "redis-2", "my-resource", "cache.t3.micro", "eu-west-1a")
+	is.NoErr(err)
+	err = c.DeleteElastiCacheRedis(ctx, "redis-2", "redis-1-final")
+	is.Equal(errorCode(err), elasticache.ErrCodeSnapshotAlreadyExistsFault) // snapshot names are unique
+}
@@ -0,0 +1,160 @@
+package aws
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"humanitec.io/resources/driver-aws-external/internal/tracing"
+)
+
+// startSpanHandler starts a span for every AWS API call made through a session. It runs once per call, before any
+// retries, so the span covers all attempts. The span is a child of any span in the context the call was made with.
+var startSpanHandler = request.NamedHandler{
+	Name: "driver.tracing.start",
+	Fn: func(r *request.Request) {
+		ctx, _ := tracing.Tracer().Start(r.Context(), r.ClientInfo.ServiceName+"."+r.Operation.Name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("rpc.system", "aws-api"),
+				attribute.String("rpc.service", r.ClientInfo.ServiceName),
+				attribute.String("rpc.method", r.Operation.Name),
+				attribute.String("aws.region", r.ClientInfo.SigningRegion),
+			),
+		)
+		r.SetContext(ctx)
+	},
+}
+
+// endSpanHandler ends the span started by startSpanHandler once the call, including any retries, is complete.
+var endSpanHandler = request.NamedHandler{
+	Name: "driver.tracing.end",
+	Fn: func(r *request.Request) {
+		span := trace.SpanFromContext(r.Context())
+		span.SetAttributes(
+			attribute.Int("aws.retry_count", r.RetryCount),
+			attribute.String("aws.request_id", r.RequestID),
+		)
+		if r.HTTPResponse != nil {
+			span.SetAttributes(attribute.Int("http.status_code", r.HTTPResponse.StatusCode))
+		}
+		tracing.End(span, r.Error)
+	},
+}
+
+// tracedClient creates a span for every call to the Client it wraps.
+type tracedClient struct {
+	next Client
+}
+
+func startSpan(ctx context.Context, operation string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "aws."+operation, trace.WithAttributes(attributes...))
+}
+
+func (c tracedClient) CreateBucket(ctx context.Context, bucketName, resourceID string) (string, error) {
+	ctx, span := startSpan(ctx, "CreateBucket", attribute.String("aws.s3.bucket", bucketName))
+	region, err := c.next.CreateBucket(ctx, bucketName, resourceID)
+	tracing.End(span, err)
+	return region, err
+}
+
+func (c tracedClient) DeleteBucket(ctx context.Context, bucketName string) error {
+	ctx, span := startSpan(ctx, "DeleteBucket", attribute.String("aws.s3.bucket", bucketName))
+	err := c.next.DeleteBucket(ctx, bucketName)
+	tracing.End(span, err)
+	return err
+}
+
+func (c tracedClient) SecureBucket(ctx context.Context, bucketName string, security BucketSecurity) error {
+	ctx, span := startSpan(ctx, "SecureBucket",
+		attribute.String("aws.s3.bucket", bucketName),
+		attribute.String("aws.s3.sse_algorithm", security.SSEAlgorithm),
+	)
+	err := c.next.SecureBucket(ctx, bucketName, security)
+	tracing.End(span, err)
+	return err
+}
+
+func (c tracedClient) ConfigureBucket(ctx context.Context, bucketName string, config BucketConfig) error {
+	ctx, span := startSpan(ctx, "ConfigureBucket",
+		attribute.String("aws.s3.bucket", bucketName),
+		attribute.String("aws.s3.versioning", config.Versioning),
+		attribute.Int("aws.s3.lifecycle_rules", len(config.LifecycleRules)),
+		attribute.Int("aws.s3.cors_rules", len(config.CORSRules)),
+		attribute.Bool("aws.s3.website", config.Website != nil),
+	)
+	err := c.next.ConfigureBucket(ctx, bucketName, config)
+	tracing.End(span, err)
+	return err
+}
+
+func (c tracedClient) CreateElastiCacheRedis(ctx context.Context, clusterId, resourceID, cacheNodeType, cacheAz string) (string, error) {
+	ctx, span := startSpan(ctx, "CreateElastiCacheRedis",
+		attribute.String("aws.elasticache.cluster_id", clusterId),
+		attribute.String("aws.elasticache.node_type", cacheNodeType),
+		attribute.String("aws.elasticache.az", cacheAz),
+	)
+	host, err := c.next.CreateElastiCacheRedis(ctx, clusterId, resourceID, cacheNodeType, cacheAz)
+	tracing.End(span, err)
+	return host, err
+}
+
+func (c tracedClient) DeleteElastiCacheRedis(ctx context.Context, clusterId, finalSnapshotId string) error {
+	ctx, span := startSpan(ctx, "DeleteElastiCacheRedis",
+		attribute.String("aws.elasticache.cluster_id", clusterId),
+		attribute.String("aws.elasticache.final_snapshot_id", finalSnapshotId),
+	)
+	err := c.next.DeleteElastiCacheRedis(ctx, clusterId, finalSnapshotId)
+	tracing.End(span, err)
+	return err
+}
+
+func (c tracedClient) WaitForElastiCacheRedis(ctx context.Context, clusterId string) (string, error) {
+	ctx, span := startSpan(ctx, "WaitForElastiCacheRedis", attribute.String("aws.elasticache.cluster_id", clusterId))
+	host, err := c.next.WaitForElastiCacheRedis(ctx, clusterId)
+	tracing.End(span, err)
+	return host, err
+}
+
+func (c tracedClient) WaitForElastiCacheRedisDeleted(ctx context.Context, clusterId string) error {
+	ctx, span := startSpan(ctx, "WaitForElastiCacheRedisDeleted", attribute.String("aws.elasticache.cluster_id", clusterId))
+	err := c.next.WaitForElastiCacheRedisDeleted(ctx, clusterId)
+	tracing.End(span, err)
+	return err
+}
+
+func (c tracedClient) BucketRegion(ctx context.Context, bucketName string) (string, error) {
+	ctx, span := startSpan(ctx, "BucketRegion", attribute.String("aws.s3.bucket", bucketName))
+	region, err := c.next.BucketRegion(ctx, bucketName)
+	tracing.End(span, err)
+	return region, err
+}
+
+func (c tracedClient) DescribeBucket(ctx context.Context, bucketName string) (Bucket, error) {
+	ctx, span := startSpan(ctx, "DescribeBucket", attribute.String("aws.s3.bucket", bucketName))
+	bucket, err := c.next.DescribeBucket(ctx, bucketName)
+	tracing.End(span, err)
+	return bucket, err
+}
+
+func (c tracedClient) DescribeElastiCacheRedis(ctx context.Context, clusterId string) (CacheCluster, error) {
+	ctx, span := startSpan(ctx, "DescribeElastiCacheRedis", attribute.String("aws.elasticache.cluster_id", clusterId))
+	cluster, err := c.next.DescribeElastiCacheRedis(ctx, clusterId)
+	tracing.End(span, err)
+	return cluster, err
+}
+
+func (c tracedClient) TagBucket(ctx context.Context, bucketName string, tags map[string]string) error {
+	ctx, span := startSpan(ctx, "TagBucket", attribute.String("aws.s3.bucket", bucketName))
+	err := c.next.TagBucket(ctx, bucketName, tags)
+	tracing.End(span, err)
+	return err
+}
+
+func (c tracedClient) TagElastiCacheRedis(ctx context.Context, clusterId string, tags map[string]string) error {
+	ctx, span := startSpan(ctx, "TagElastiCacheRedis", attribute.String("aws.elasticache.cluster_id", clusterId))
+	err := c.next.TagElastiCacheRedis(ctx, clusterId, tags)
+	tracing.End(span, err)
+	return err
+}
@@ -0,0 +1,40 @@
+package aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/matryer/is"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	c, server := newTestClient(t, 1)
+	server.Errors["DeleteBucket"] = "InternalError"
+
+	_, err := tracedClient{c}.CreateBucket(ctx, "my-bucket", "my-resource")
+	is.NoErr(err)
+	is.True(tracedClient{c}.DeleteBucket(ctx, "my-bucket") != nil)
+
+	spans := recorder.Ended()
+	is.Equal(len(spans), 6)
+	is.Equal(spans[0].Name(), "s3.CreateBucket")
+	is.Equal(spans[1].Name(), "s3.GetBucketTagging")
+	is.Equal(spans[2].Name(), "s3.PutBucketTagging")
+	is.Equal(spans[3].Name(), "aws.CreateBucket")
+	for _, span := range spans[:3] {
+		is.Equal(span.Parent().SpanID(), spans[3].SpanContext().SpanID()) // including the calls that tag the bucket
+	}
+	is.Equal(spans[4].Name(), "s3.DeleteBucket")
+	is.Equal(spans[4].Status().Code, codes.Error)
+	is.Equal(spans[5].Status().Code, codes.Error) // the error is recorded on both spans
+}
@@ -0,0 +1,205 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"humanitec.io/resources/driver-aws-external/internal/logging"
+)
+
+// WaitConfig configures how clients wait for clusters to become available or to be removed.
+type WaitConfig struct {
+	// CreateTimeout limits how long WaitForElastiCacheRedis waits.
+	CreateTimeout time.Duration
+	// DeleteTimeout limits how long WaitForElastiCacheRedisDeleted waits.
+	DeleteTimeout time.Duration
+	// AttemptTimeout limits how long each check may take. It also limits the calls that are made even if the request
+	// they are made for has been cancelled.
+	AttemptTimeout time.Duration
+	// MinDelay is the delay after the first check. It doubles after every check, up to MaxDelay.
+	MinDelay time.Duration
+	MaxDelay time.Duration
+}
+
+// DefaultWaitConfig returns the WaitConfig used unless another is configured.
+func DefaultWaitConfig() WaitConfig {
+	return WaitConfig{
+		CreateTimeout:  5 * time.Minute,
+		DeleteTimeout:  5 * time.Minute,
+		AttemptTimeout: 30 * time.Second,
+		MinDelay:       2 * time.Second,
+		MaxDelay:       30 * time.Second,
+	}
+}
+
+// waiting returns the waitConfig of waiters that give up after timeout.
+func (c WaitConfig) waiting(timeout time.Duration) waitConfig {
+	return waitConfig{
+		timeout:        timeout,
+		attemptTimeout: c.AttemptTimeout,
+		minDelay:       c.MinDelay,
+		maxDelay:       c.MaxDelay,
+	}
+}
+
+// waitConfig configures how often and for how long a waiter checks on a resource.
+type waitConfig struct {
+	// timeout limits how long the waiter keeps checking.
+	timeout time.Duration
+	// attemptTimeout limits how long each check may take, so that a single hanging call does not use up the whole
+	// timeout. Checks that time out are retried.
+	attemptTimeout time.Duration
+	// minDelay is the delay after the first check. It doubles after every check, up to maxDelay.
+	minDelay time.Duration
+	maxDelay time.Duration
+}
+
+// checkFunc checks once on a resource that is waited for. It returns the status of the resource and whether waiting is
+// done. Errors stop the waiter, unless they are throttling or other retryable errors.
+type checkFunc func(ctx context.Context) (status string, done bool, err error)
+
+// waiter checks on a resource until it reaches the status waited for, with exponential backoff plus jitter between
+// checks.
+type waiter struct {
+	waitConfig
+	// name describes what is waited for in errors and logs, e.g. `cluster "redis-1" to become available`.
+	name string
+	// terminal lists the statuses the resource will not leave. Waiting stops with a *terminalStatusError once the
+	// resource reaches one of them.
+	terminal []string
+	// sleep waits for the supplied duration or until ctx is done. Defaults to aws.SleepWithContext.
+	sleep func(ctx context.Context, d time.Duration) error
+	// now defaults to time.Now.
+	now func() time.Time
+}
+
+// terminalStatusError is returned by waiters when the resource waited for reached a status it will not leave.
+type terminalStatusError struct {
+	name   string
+	status string
+}
+
+func (e *terminalStatusError) Error() string {
+	return fmt.Sprintf(`stopped waiting for %s: status is "%s"`, e.name, e.status)
+}
+
+// wait calls check until it reports that waiting is done, the resource reaches a terminal status, check fails with an
+// error that cannot be retried, the timeout passes or ctx is done. Like the waiters of the AWS SDK, it returns an
+// awserr.Error with code request.WaiterResourceNotReadyErrorCode once the timeout has passed and one with code
+// request.CanceledErrorCode once ctx is done.
+func (w waiter) wait(ctx context.Context, check checkFunc) error {
+	l := logging.FromContext(ctx)
+	sleep, now := w.sleep, w.now
+	if sleep == nil {
+		sleep = aws.SleepWithContext
+	}
+	if now == nil {
+		now = time.Now
+	}
+
+	deadline := now().Add(w.timeout)
+	delay := w.minDelay
+	var status string
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		s, done, err := w.check(ctx, check)
+		switch {
+		case err == nil && done:
+			return nil
+		case ctx.Err() != nil:
+			return awserr.New(request.CanceledErrorCode, fmt.Sprintf("stopped waiting for %s", w.name), ctx.Err())
+		case err == nil:
+			status, lastErr = s, nil
+			for _, t := range w.terminal {
+				if status == t {
+					return &terminalStatusError{name: w.name, status: status}
+				}
+			}
+		case isThrottle(err):
+			// Backs off harder, so that waiters do not use up the request rate shared with the rest of the account.
+			l.WithError(err).Warnf("Check %d was throttled. Retrying.", attempt)
+			lastErr = err
+			delay *= 2
+		case isRetryable(ctx, err):
+			l.WithError(err).Warnf("Check %d failed. Retrying.", attempt)
+			lastErr = err
+		default:
+			return err
+		}
+
+		remaining := deadline.Sub(now())
+		if remaining <= 0 {
+			msg := fmt.Sprintf("gave up waiting for %s after %v", w.name, w.timeout)
+			if status != "" {
+				msg += fmt.Sprintf(`, status is "%s"`, status)
+			}
+			return awserr.New(request.WaiterResourceNotReadyErrorCode, msg, lastErr)
+		}
+		if delay > w.maxDelay {
+			delay = w.maxDelay
+		}
+		d := jitter(delay)
+		if d > remaining {
+			// Checks one last time once the timeout has passed.
+			d = remaining
+		}
+		if err := sleep(ctx, d); err != nil {
+			return awserr.New(request.CanceledErrorCode, fmt.Sprintf("stopped waiting for %s", w.name), err)
+		}
+		delay *= 2
+	}
+}
+
+// check calls check with the attempt timeout applied.
+func (w waiter) check(ctx context.Context, check checkFunc) (string, bool, error) {
+	if w.attemptTimeout <= 0 {
+		return check(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, w.attemptTimeout)
+	defer cancel()
+	return check(ctx)
+}
+
+// isThrottle reports whether a check failed with err because AWS is throttling requests.
+func isThrottle(err error) bool {
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && request.IsErrorThrottle(aerr)
+}
+
+// isRetryable reports whether a check that failed with err may succeed when it is retried: the request failed on the
+// way to AWS, AWS failed to handle it or it took longer than the attempt timeout.
+func isRetryable(ctx context.Context, err error) bool {
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return false
+	}
+	if aerr.Code() == request.CanceledErrorCode {
+		// Checks are cancelled when ctx is done too.
+		return ctx.Err() == nil
+	}
+	var rerr awserr.RequestFailure
+	return request.IsErrorRetryable(aerr) || errors.As(err, &rerr) && rerr.StatusCode() >= 500
+}
+
+var (
+	jitterMu   sync.Mutex
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// jitter returns a random duration between half of d and d, so that waiters started together do not keep checking
+// at the same time.
+func jitter(d time.Duration) time.Duration {
+	if d < 2 {
+		return d
+	}
+	jitterMu.Lock()
+	defer jitterMu.Unlock()
+	return d/2 + time.Duration(jitterRand.Int63n(int64(d/2)+1))
+}
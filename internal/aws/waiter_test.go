@@ -0,0 +1,199 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/matryer/is"
+)
+
+// newTestWaiter creates a waiter with a clock that only moves when it sleeps. The delays it slept for are recorded.
+func newTestWaiter() (*waiter, *[]time.Duration) {
+	now := time.Date(2020, 07, 16, 18, 12, 20, 0, time.UTC)
+	var slept []time.Duration
+	w := &waiter{
+		waitConfig: waitConfig{
+			timeout:  5 * time.Minute,
+			minDelay: 2 * time.Second,
+			maxDelay: 30 * time.Second,
+		},
+		name: `cluster "redis-1" to become available`,
+		now:  func() time.Time { return now },
+		sleep: func(ctx context.Context, d time.Duration) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			now = now.Add(d)
+			slept = append(slept, d)
+			return nil
+		},
+	}
+	return w, &slept
+}
+
+// statuses returns a checkFunc that reports the supplied statuses in turn, and is done once it reaches the last one.
+func statuses(s ...string) checkFunc {
+	return func(ctx context.Context) (string, bool, error) {
+		status := s[0]
+		if len(s) > 1 {
+			s = s[1:]
+			return status, false, nil
+		}
+		return status, true, nil
+	}
+}
+
+func TestWaiter_Backoff(t *testing.T) {
+	is := is.New(t)
+	w, slept := newTestWaiter()
+
+	is.NoErr(w.wait(context.Background(), statuses("creating", "creating", "creating", "creating", "creating", "creating", "available")))
+
+	is.Equal(len(*slept), 6)
+	for i, max := range []time.Duration{2, 4, 8, 16, 30, 30} {
+		max *= time.Second
+		d := (*slept)[i]
+		is.True(d >= max/2 && d <= max) // doubles up to maxDelay, with jitter
+	}
+}
+
+func TestWaiter_Timeout(t *testing.T) {
+	is := is.New(t)
+	w, slept := newTestWaiter()
+	checks := 0
+
+	err := w.wait(context.Background(), func(ctx context.Context) (string, bool, error) {
+		checks++
+		return "creating", false, nil
+	})
+
+	is.Equal(errorCode(err), request.WaiterResourceNotReadyErrorCode)
+	is.Equal(err.Error(), `ResourceNotReady: gave up waiting for cluster "redis-1" to become available after 5m0s, status is "creating"`)
+	var total time.Duration
+	for _, d := range *slept {
+		total += d
+	}
+	is.Equal(total, w.timeout)      // checked one last time once the timeout had passed
+	is.Equal(checks, len(*slept)+1) // and gave up then
+}
+
+func TestWaiter_TimeoutWrapsLastError(t *testing.T) {
+	is := is.New(t)
+	w, _ := newTestWaiter()
+	unavailable := awserr.NewRequestFailure(awserr.New("ServiceUnavailable", "service unavailable", nil), 503, "request-1")
+
+	err := w.wait(context.Background(), func(ctx context.Context) (string, bool, error) {
+		return "", false, unavailable
+	})
+
+	var aerr awserr.Error
+	is.True(errors.As(err, &aerr))
+	is.Equal(aerr.Code(), request.WaiterResourceNotReadyErrorCode)
+	is.Equal(aerr.OrigErr(), unavailable)
+}
+
+func TestWaiter_Throttled(t *testing.T) {
+	is := is.New(t)
+	w, slept := newTestWaiter()
+	check := statuses("creating", "available")
+	throttled := 0
+
+	err := w.wait(context.Background(), func(ctx context.Context) (string, bool, error) {
+		if throttled < 2 {
+			throttled++
+			return "", false, awserr.New("Throttling", "rate exceeded", nil)
+		}
+		return check(ctx)
+	})
+
+	is.NoErr(err) // throttled checks are retried
+	is.Equal(len(*slept), 3)
+	is.True((*slept)[0] >= 2*time.Second) // throttled checks back off harder
+	is.True((*slept)[1] >= 8*time.Second)
+}
+
+func TestWaiter_Errors(t *testing.T) {
+	is := is.New(t)
+	w, slept := newTestWaiter()
+	notFound := awserr.New("CacheClusterNotFound", "cache cluster not found", nil)
+
+	err := w.wait(context.Background(), func(ctx context.Context) (string, bool, error) {
+		return "", false, notFound
+	})
+
+	is.Equal(err, notFound) // returned as they are, without retrying
+	is.Equal(len(*slept), 0)
+
+	other := errors.New("not an AWS error")
+	err = w.wait(context.Background(), func(ctx context.Context) (string, bool, error) {
+		return "", false, other
+	})
+
+	is.Equal(err, other)
+	is.Equal(len(*slept), 0)
+}
+
+func TestWaiter_TerminalStatus(t *testing.T) {
+	for _, status := range redisUnavailableStatuses {
+		t.Run(status, func(t *testing.T) {
+			is := is.New(t)
+			w, slept := newTestWaiter()
+			w.terminal = redisUnavailableStatuses
+
+			err := w.wait(context.Background(), statuses("creating", status, "available"))
+
+			var terr *terminalStatusError
+			is.True(errors.As(err, &terr))
+			is.Equal(terr.status, status)
+			is.Equal(len(*slept), 1) // stopped as soon as the status was reached
+		})
+	}
+}
+
+func TestWaiter_AttemptTimeout(t *testing.T) {
+	is := is.New(t)
+	w, _ := newTestWaiter()
+	w.attemptTimeout = 10 * time.Millisecond
+	check := statuses("available")
+	hung := false
+
+	err := w.wait(context.Background(), func(ctx context.Context) (string, bool, error) {
+		if !hung {
+			hung = true
+			<-ctx.Done()
+			return "", false, awserr.New(request.CanceledErrorCode, "request context canceled", ctx.Err())
+		}
+		return check(ctx)
+	})
+
+	is.NoErr(err) // checks that hang are given up on and retried
+}
+
+func TestWaiter_Cancelled(t *testing.T) {
+	is := is.New(t)
+	w, _ := newTestWaiter()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	err := w.wait(ctx, func(ctx context.Context) (string, bool, error) {
+		cancel()
+		return "creating", false, nil
+	})
+
+	is.Equal(errorCode(err), request.CanceledErrorCode)
+	var aerr awserr.Error
+	is.True(errors.As(err, &aerr))
+	is.Equal(aerr.OrigErr(), context.Canceled)
+}
+
+func TestJitter(t *testing.T) {
+	is := is.New(t)
+	for i := 0; i < 100; i++ {
+		d := jitter(10 * time.Second)
+		is.True(d >= 5*time.Second && d <= 10*time.Second)
+	}
+	is.Equal(jitter(0), time.Duration(0))
+}
@@ -0,0 +1,142 @@
+// Package certs provides TLS configuration for the driver's HTTP server.
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"humanitec.io/resources/driver-aws-external/internal/logging"
+)
+
+// checkInterval is how often the certificate files are checked for changes.
+const checkInterval = 10 * time.Second
+
+// Reloader serves a certificate and key read from files, reloading them when the files change so that rotated
+// certificates are picked up without restarting.
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	mu          sync.Mutex
+	cert        *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+	lastCheck   time.Time
+	now         func() time.Time
+}
+
+// NewReloader loads the certificate and key from the supplied PEM files.
+func NewReloader(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile, now: time.Now}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate returns the current certificate. It can be used as tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.now().Sub(r.lastCheck) >= checkInterval {
+		if err := r.reloadIfChanged(); err != nil {
+			// Keep serving the previous certificate. The files may be part-way through being replaced.
+			logging.Base().WithError(err).Error("Unable to reload TLS certificate")
+		}
+	}
+	return r.cert, nil
+}
+
+func (r *Reloader) reloadIfChanged() error {
+	r.lastCheck = r.now()
+	certModTime, keyModTime, err := r.modTimes()
+	if err != nil {
+		return err
+	}
+	if certModTime.Equal(r.certModTime) && keyModTime.Equal(r.keyModTime) {
+		return nil
+	}
+	if err := r.reload(); err != nil {
+		return err
+	}
+	logging.Base().Infof("Reloaded TLS certificate from %s", r.certFile)
+	return nil
+}
+
+func (r *Reloader) reload() error {
+	certModTime, keyModTime, err := r.modTimes()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading certificate: %w", err)
+	}
+	r.cert = &cert
+	r.certModTime = certModTime
+	r.keyModTime = keyModTime
+	r.lastCheck = r.now()
+	return nil
+}
+
+func (r *Reloader) modTimes() (time.Time, time.Time, error) {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("reading certificate: %w", err)
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("reading key: %w", err)
+	}
+	return certInfo.ModTime(), keyInfo.ModTime(), nil
+}
+
+// Client certificate policies for ServerConfig.
+const (
+	// ClientAuthRequire rejects connections without a client certificate signed by the client CA.
+	ClientAuthRequire = "require"
+	// ClientAuthOptional verifies client certificates that are presented but also accepts connections without one,
+	// e.g. from probes.
+	ClientAuthOptional = "optional"
+)
+
+// ServerConfig returns TLS configuration serving the certificate and key in the supplied files, reloading them when
+// they change. If clientCAFile is not empty, client certificates are verified against the CAs it contains, according
+// to clientAuth.
+func ServerConfig(certFile, keyFile, clientCAFile, clientAuth string) (*tls.Config, error) {
+	reloader, err := NewReloader(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if clientCAFile == "" {
+		return cfg, nil
+	}
+
+	pem, err := ioutil.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading client CA: %w", err)
+	}
+	cfg.ClientCAs = x509.NewCertPool()
+	if !cfg.ClientCAs.AppendCertsFromPEM(pem) {
+		return nil, errors.New("client CA file does not contain any PEM encoded certificates")
+	}
+	switch clientAuth {
+	case ClientAuthRequire, "":
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	case ClientAuthOptional:
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	default:
+		return nil, fmt.Errorf(`unknown client auth "%s"`, clientAuth)
+	}
+	return cfg, nil
+}
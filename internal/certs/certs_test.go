@@ -0,0 +1,200 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+type keyPair struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newKeyPair creates a certificate signed by parent, or self-signed if parent is nil.
+func newKeyPair(is *is.I, name string, parent *keyPair) keyPair {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	is.NoErr(err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	is.NoErr(err)
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer := keyPair{template, key}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer = *parent
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer.cert, &key.PublicKey, signer.key)
+	is.NoErr(err)
+	cert, err := x509.ParseCertificate(der)
+	is.NoErr(err)
+	return keyPair{cert, key}
+}
+
+func (k keyPair) write(is *is.I, certFile, keyFile string) {
+	is.NoErr(ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: k.cert.Raw}), 0600))
+	der, err := x509.MarshalECPrivateKey(k.key)
+	is.NoErr(err)
+	is.NoErr(ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600))
+}
+
+func (k keyPair) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{k.cert.Raw}, PrivateKey: k.key}
+}
+
+func tempDir(is *is.I) (string, func()) {
+	dir, err := ioutil.TempDir("", "certs-test")
+	is.NoErr(err)
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestReloader(t *testing.T) {
+	is := is.New(t)
+	dir, cleanup := tempDir(is)
+	defer cleanup()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+
+	first := newKeyPair(is, "first", nil)
+	first.write(is, certFile, keyFile)
+	r, err := NewReloader(certFile, keyFile)
+	is.NoErr(err)
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	cert, err := r.GetCertificate(nil)
+	is.NoErr(err)
+	is.Equal(cert.Certificate[0], first.cert.Raw)
+
+	// Rotate the certificate.
+	second := newKeyPair(is, "second", nil)
+	second.write(is, certFile, keyFile)
+	later := time.Now().Add(time.Minute)
+	is.NoErr(os.Chtimes(certFile, later, later))
+	is.NoErr(os.Chtimes(keyFile, later, later))
+
+	// Changes are only looked for every checkInterval.
+	cert, err = r.GetCertificate(nil)
+	is.NoErr(err)
+	is.Equal(cert.Certificate[0], first.cert.Raw)
+
+	now = now.Add(checkInterval)
+	cert, err = r.GetCertificate(nil)
+	is.NoErr(err)
+	is.Equal(cert.Certificate[0], second.cert.Raw)
+
+	// A broken certificate is not served.
+	is.NoErr(ioutil.WriteFile(certFile, []byte("garbage"), 0600))
+	evenLater := later.Add(time.Minute)
+	is.NoErr(os.Chtimes(certFile, evenLater, evenLater))
+	now = now.Add(checkInterval)
+	cert, err = r.GetCertificate(nil)
+	is.NoErr(err)
+	is.Equal(cert.Certificate[0], second.cert.Raw)
+}
+
+func TestNewReloader_Missing(t *testing.T) {
+	is := is.New(t)
+	_, err := NewReloader("does-not-exist.crt", "does-not-exist.key")
+	is.True(err != nil)
+}
+
+func TestServerConfig_ClientAuth(t *testing.T) {
+	is := is.New(t)
+	dir, cleanup := tempDir(is)
+	defer cleanup()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	caFile := filepath.Join(dir, "ca.crt")
+
+	ca := newKeyPair(is, "ca", nil)
+	ca.write(is, caFile, filepath.Join(dir, "ca.key"))
+	newKeyPair(is, "server", &ca).write(is, certFile, keyFile)
+	client := newKeyPair(is, "client", &ca)
+	stranger := newKeyPair(is, "stranger", nil)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(url string, certs ...tls.Certificate) error {
+		cfg := &tls.Config{RootCAs: roots}
+		if len(certs) > 0 {
+			// Send the certificate even if it is not signed by a CA the server asks for.
+			cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) { return &certs[0], nil }
+		}
+		c := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+		resp, err := c.Get(url)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	for _, tc := range []struct {
+		clientAuth    string
+		noCert        bool
+		strangerCert  bool
+		trustedClient bool
+	}{
+		{clientAuth: ClientAuthRequire, noCert: false, strangerCert: false, trustedClient: true},
+		{clientAuth: ClientAuthOptional, noCert: true, strangerCert: false, trustedClient: true},
+	} {
+		t.Run(tc.clientAuth, func(t *testing.T) {
+			is := is.New(t)
+			cfg, err := ServerConfig(certFile, keyFile, caFile, tc.clientAuth)
+			is.NoErr(err)
+			// httptest.Server.StartTLS would install its own certificate.
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			srv.Listener = tls.NewListener(srv.Listener, cfg)
+			srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+			srv.Start()
+			defer srv.Close()
+			url := strings.Replace(srv.URL, "http://", "https://", 1)
+
+			is.Equal(get(url) == nil, tc.noCert)
+			is.Equal(get(url, stranger.tlsCertificate()) == nil, tc.strangerCert)
+			is.Equal(get(url, client.tlsCertificate()) == nil, tc.trustedClient)
+		})
+	}
+}
+
+func TestServerConfig_Invalid(t *testing.T) {
+	is := is.New(t)
+	dir, cleanup := tempDir(is)
+	defer cleanup()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	newKeyPair(is, "server", nil).write(is, certFile, keyFile)
+
+	cfg, err := ServerConfig(certFile, keyFile, "", "")
+	is.NoErr(err)
+	is.Equal(cfg.ClientAuth, tls.NoClientCert)
+
+	_, err = ServerConfig(certFile, keyFile, keyFile, "") // not a certificate
+	is.True(err != nil)
+
+	_, err = ServerConfig(certFile, keyFile, certFile, "sometimes")
+	is.True(err != nil)
+}
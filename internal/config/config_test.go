@@ -0,0 +1,303 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func writeFile(is *is.I, content string) (string, func()) {
+	dir, err := ioutil.TempDir("", "config-test")
+	is.NoErr(err)
+	path := filepath.Join(dir, "config.yaml")
+	is.NoErr(ioutil.WriteFile(path, []byte(content), 0600))
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+}
+
+// testDefault is Default with the settings that have no default set.
+func testDefault() Config {
+	cfg := Default()
+	cfg.Naming.Prefix = "acme"
+	return cfg
+}
+
+func TestDefault(t *testing.T) {
+	is := is.New(t)
+	cfg := testDefault()
+	cfg.Database.Driver = "memory"
+	is.NoErr(cfg.Validate())
+	is.Equal(cfg.Port, 8080)
+	is.Equal(cfg.Database.Port, 5432)
+}
+
+func TestLoad_FileAndEnv(t *testing.T) {
+	is := is.New(t)
+	path, cleanup := writeFile(is, `
+port: 9090
+timeout_limit: 600
+drain_timeout: 40s
+deletion:
+  grace_period: 24h
+database:
+  host: db.example.com
+  name: metadata
+  user: driver
+  password: from-file
+  sslmode: require
+naming:
+  prefix: acme
+default_region: eu-west-1
+regions:
+  eu-west-1:
+    cache_node_type: cache.t3.micro
+    cache_az: eu-west-1a
+`)
+	defer cleanup()
+
+	cfg := Default()
+	b, err := ioutil.ReadFile(path)
+	is.NoErr(err)
+	is.NoErr(cfg.decodeYAML(b))
+	is.NoErr(cfg.applyEnv(env(map[string]string{
+		"PORT":                     "7070",
+		"DATABASE_PASSWORD":        "from-env",
+		"DATABASE_PORT":            "6543",
+		"DATABASE_USER":            "", // empty variables are ignored
+		"DELETION_CHECK_INTERVAL":  "5m",
+		"DELETION_CREDENTIALS_KEY": testCredentialsKey,
+		"SHUTDOWN_DELAY":           "5s",
+	})))
+	is.NoErr(cfg.Validate())
+
+	is.Equal(cfg.Port, 7070)                         // env overrides file
+	is.Equal(cfg.TimeoutLimit, 600)                  // file overrides default
+	is.Equal(cfg.DrainTimeout, 40*time.Second)       // file overrides default
+	is.Equal(cfg.ShutdownDelay, 5*time.Second)       // env
+	is.Equal(cfg.HealthCheck.Timeout, 2*time.Second) // default
+	is.Equal(cfg.Database.Password, Secret("from-env"))
+	is.Equal(cfg.Database.Port, 6543)
+	is.Equal(cfg.Database.User, "driver")
+	is.Equal(cfg.Database.SSLMode, "require")
+	is.Equal(cfg.Deletion.GracePeriod, 24*time.Hour)
+	is.Equal(cfg.Deletion.CheckInterval, 5*time.Minute)
+	key, err := cfg.Deletion.Key()
+	is.NoErr(err)
+	is.Equal(len(key), 32)
+	is.Equal(cfg.Regions["eu-west-1"], RegionDefaults{CacheNodeType: "cache.t3.micro", CacheAZ: "eu-west-1a"})
+}
+
+func TestLoad_Wait(t *testing.T) {
+	is := is.New(t)
+	path, cleanup := writeFile(is, `
+timeout_limit: 600
+database:
+  driver: memory
+naming:
+  prefix: acme
+aws:
+  wait:
+    delete_timeout: 20m
+    attempt_timeout: 10s
+    min_delay: 1s
+`)
+	defer cleanup()
+
+	cfg, err := Load(path)
+	is.NoErr(err)
+	is.Equal(cfg.AWS.Wait, Wait{
+		CreateTimeout:  10 * time.Minute, // timeout_limit unless set
+		DeleteTimeout:  20 * time.Minute,
+		AttemptTimeout: 10 * time.Second,
+		MinDelay:       time.Second,
+		MaxDelay:       30 * time.Second, // default
+	})
+
+	is.NoErr(cfg.applyEnv(env(map[string]string{
+		"AWS_WAIT_CREATE_TIMEOUT":  "15m",
+		"AWS_WAIT_DELETE_TIMEOUT":  "1h",
+		"AWS_WAIT_ATTEMPT_TIMEOUT": "5s",
+		"AWS_WAIT_MIN_DELAY":       "500ms",
+		"AWS_WAIT_MAX_DELAY":       "1m",
+	})))
+	is.Equal(cfg.AWS.Wait, Wait{
+		CreateTimeout:  15 * time.Minute,
+		DeleteTimeout:  time.Hour,
+		AttemptTimeout: 5 * time.Second,
+		MinDelay:       500 * time.Millisecond,
+		MaxDelay:       time.Minute,
+	})
+
+	cfg.AWS.Wait.MaxDelay = 100 * time.Millisecond
+	cfg.AWS.Wait.AttemptTimeout = 0
+	err = cfg.Validate()
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "aws.wait.max_delay"))
+	is.True(strings.Contains(err.Error(), "aws.wait.attempt_timeout"))
+}
+
+func TestLoad_FakeAccountAndTracing(t *testing.T) {
+	is := is.New(t)
+	cfg := testDefault()
+	is.NoErr(cfg.applyEnv(env(map[string]string{
+		"USE_FAKE_AWS_CLIENT":           "TRUE",
+		"FAKE_AWS_CLUSTER_CREATE_DELAY": "30s",
+		"FAKE_AWS_MAX_BUCKETS":          "5",
+		"FAKE_AWS_ERRORS":               "CreateBucket=InternalError, DeleteElastiCacheRedis=InvalidCacheClusterState",
+		"OTEL_EXPORTER_OTLP_ENDPOINT":   "http://localhost:4318",
+		"OTEL_EXPORTER_OTLP_HEADERS":    "api-key=s3cr3t,tenant=acme%20corp",
+	})))
+	cfg.Database.Driver = "memory"
+	is.NoErr(cfg.Validate())
+
+	is.Equal(cfg.AWS.FakeAccount, FakeAccount{
+		ClusterCreateDelay: 30 * time.Second,
+		MaxBuckets:         5,
+		MaxClusters:        300, // default
+		Errors:             map[string]string{"CreateBucket": "InternalError", "DeleteElastiCacheRedis": "InvalidCacheClusterState"},
+	})
+	is.True(cfg.Tracing.Enabled())
+	is.Equal(cfg.Tracing.Headers, map[string]Secret{"api-key": "s3cr3t", "tenant": "acme corp"})
+	is.Equal(cfg.Tracing.ServiceName, "driver-aws-external") // default
+
+	is.True(cfg.applyEnv(env(map[string]string{"FAKE_AWS_ERRORS": "CreateBucket"})) != nil)
+	is.True(cfg.applyEnv(env(map[string]string{"OTEL_EXPORTER_OTLP_HEADERS": "api-key"})) != nil)
+
+	cfg.AWS.FakeAccount.MaxClusters = -1
+	cfg.Tracing.Endpoint = "localhost:4318"
+	err := cfg.Validate()
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "aws.fake_account.max_clusters"))
+	is.True(strings.Contains(err.Error(), "tracing.endpoint"))
+}
+
+func TestLoad_Errors(t *testing.T) {
+	is := is.New(t)
+
+	_, err := Load("does-not-exist.yaml")
+	is.True(err != nil)
+
+	path, cleanup := writeFile(is, "prot: 9090\n")
+	defer cleanup()
+	_, err = Load(path)
+	is.True(err != nil) // unknown field
+
+	cfg := Default()
+	is.True(cfg.applyEnv(env(map[string]string{"DRAIN_TIMEOUT": "soon"})) != nil)
+	is.True(cfg.applyEnv(env(map[string]string{"DATABASE_PORT": "db"})) != nil)
+}
+
+func TestLoad_EmptyFile(t *testing.T) {
+	is := is.New(t)
+	cfg := Default()
+	is.NoErr(cfg.decodeYAML(nil))
+	is.Equal(cfg, Default())
+}
+
+// testCredentialsKey is a valid deletion.credentials_key.
+const testCredentialsKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+func TestValidate_CredentialsKey(t *testing.T) {
+	is := is.New(t)
+	cfg := testDefault()
+	cfg.Database.Driver = "memory"
+	cfg.Deletion.GracePeriod = time.Hour
+
+	err := cfg.Validate()
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "deletion.credentials_key is required")) // to carry out the deletion later
+
+	cfg.Deletion.CredentialsKey = "c2hvcnQ="
+	err = cfg.Validate()
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "deletion.credentials_key must be 32 bytes"))
+
+	cfg.Deletion.CredentialsKey = testCredentialsKey
+	is.NoErr(cfg.Validate())
+}
+
+func TestValidate(t *testing.T) {
+	is := is.New(t)
+	cfg := Default()
+	cfg.Port = 0
+	cfg.Database.Port = 70000
+	cfg.Database.SSLMode = "sometimes"
+	cfg.TLS.CertFile = "tls.crt"
+	cfg.Naming.Template = "{prefix}-{app}"
+	cfg.Deletion.CheckInterval = 0
+
+	err := cfg.Validate()
+	is.True(err != nil)
+	for _, problem := range []string{"port", "database.host", "database.port", "database.sslmode", "tls.cert_file", "naming.template", "deletion.check_interval"} {
+		is.True(strings.Contains(err.Error(), problem))
+	}
+
+	// A URL replaces the other connection settings.
+	cfg = testDefault()
+	cfg.Database.URL = "postgres://driver@localhost/metadata"
+	is.NoErr(cfg.Validate())
+
+	cfg.Database.MaxOpenConns = 2
+	cfg.Database.MaxIdleConns = 3
+	is.True(cfg.Validate() != nil)
+
+	// Postgres settings are not needed for other drivers.
+	cfg = testDefault()
+	cfg.Database.Driver = "sqlite"
+	is.NoErr(cfg.Validate())
+}
+
+func TestValidate_Naming(t *testing.T) {
+	for name, tc := range map[string]struct {
+		naming Naming
+		valid  bool
+	}{
+		"DefaultWithoutPrefix": {Naming{Template: DefaultNamingTemplate}, false},
+		"DefaultWithPrefix":    {Naming{Template: DefaultNamingTemplate, Prefix: "acme"}, true},
+		"ShortPrefix":          {Naming{Template: DefaultNamingTemplate, Prefix: "a-b"}, false},
+		"LiteralText":          {Naming{Template: "shop-{app}-{short-hash}"}, true},
+		"PrefixNotUsed":        {Naming{Template: "{app}-{short-hash}", Prefix: "acme"}, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			cfg := Default()
+			cfg.Database.Driver = "memory"
+			cfg.Naming = tc.naming
+
+			err := cfg.Validate()
+
+			is.Equal(err == nil, tc.valid)
+			if !tc.valid {
+				is.True(strings.Contains(err.Error(), "naming.prefix"))
+			}
+		})
+	}
+}
+
+func TestYAML_MasksSecrets(t *testing.T) {
+	is := is.New(t)
+	cfg := Default()
+	cfg.Database.Password = "hunter2"
+	cfg.Auth.BearerToken = "s3cr3t"
+	cfg.Tracing.Headers = map[string]Secret{"api-key": "t0k3n"}
+
+	out, err := cfg.YAML()
+	is.NoErr(err)
+	is.True(!strings.Contains(string(out), "hunter2"))
+	is.True(!strings.Contains(string(out), "s3cr3t"))
+	is.True(!strings.Contains(string(out), "t0k3n"))
+	is.True(strings.Contains(string(out), "password: '"+masked+"'"))
+	is.True(strings.Contains(string(out), "hmac_key: \"\"")) // unset secrets are not masked
+	is.True(strings.Contains(string(out), "drain_timeout: 25s"))
+}
@@ -0,0 +1,60 @@
+// Package logging provides the structured, leveled logger used throughout the driver.
+//
+// Request-scoped loggers, carrying fields such as the request ID, are passed around in a context.Context. Code that
+// has a context should always log via FromContext so that its log lines can be correlated with the request.
+package logging
+
+import (
+	"context"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Logger is the type of logger passed around in contexts.
+type Logger = *logrus.Entry
+
+// RequestIDHeader is the HTTP header used to pass request IDs between services.
+const RequestIDHeader = "X-Request-ID"
+
+var base = newBase()
+
+func newBase() *logrus.Logger {
+	l := logrus.New()
+	l.SetOutput(os.Stdout)
+	l.SetFormatter(&logrus.JSONFormatter{})
+	return l
+}
+
+// Setup sets the minimum level that is logged. One of `debug`, `info` (the default), `warn` or `error`.
+func Setup(level string) error {
+	if level == "" {
+		return nil
+	}
+	parsed, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	base.SetLevel(parsed)
+	return nil
+}
+
+// Base returns the logger that is not associated with any request.
+func Base() Logger {
+	return logrus.NewEntry(base)
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the supplied logger.
+func NewContext(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, or the base logger if there is none.
+func FromContext(ctx context.Context) Logger {
+	if l, ok := ctx.Value(contextKey{}).(Logger); ok {
+		return l
+	}
+	return Base()
+}
@@ -34,3 +34,25 @@ type DriverResourceDefinition struct {
 	// Secret parameters passed in from the Dynamic Resource.
 	DriverSecrets map[string]interface{} `json:"driver_secrets,omitempty"`
 }
+
+// ImportRequest asks the driver to take over a resource that already exists in AWS.
+type ImportRequest struct {
+	// the id the resource is managed under from now on
+	// required: true
+	// pattern: ^[a-z0-9][a-z0-9-]+[a-z0-9]$
+	ID string `json:"id"`
+
+	// the type of the resource, e.g. "s3" or "redis".
+	// required: true
+	Type string `json:"type"`
+
+	// The name of the bucket or the id of the ElastiCache cluster.
+	// required: true
+	Identifier string `json:"identifier"`
+
+	// The parameters of the Dynamic Resource, as for DriverResourceDefinition.
+	DriverParams map[string]interface{} `json:"driver_params"`
+
+	// Secret parameters of the Dynamic Resource, as for DriverResourceDefinition.
+	DriverSecrets map[string]interface{} `json:"driver_secrets,omitempty"`
+}
@@ -0,0 +1,86 @@
+// Package metrics holds the Prometheus collectors exposed by the driver on the /metrics endpoint.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "driver"
+
+var (
+	// HTTPRequests counts the requests served by the API.
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of HTTP requests served, by route and status code.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestDuration measures how long requests served by the API take.
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Time taken to serve HTTP requests, by route and status code.",
+		// Creating a cluster can take several minutes.
+		Buckets: []float64{.005, .01, .05, .1, .5, 1, 5, 10, 30, 60, 120, 300, 600},
+	}, []string{"method", "route", "status"})
+
+	// ProvisioningDuration measures how long it takes to provision new resources.
+	ProvisioningDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "provisioning_duration_seconds",
+		Help:      "Time taken to provision a new resource, by resource type and result.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 11),
+	}, []string{"type", "result"})
+
+	// AWSAPICalls counts the calls made to AWS APIs.
+	AWSAPICalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "aws",
+		Name:      "api_calls_total",
+		Help:      "Number of AWS API calls, by service and operation.",
+	}, []string{"service", "operation"})
+
+	// AWSAPIErrors counts the calls made to AWS APIs that failed.
+	AWSAPIErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "aws",
+		Name:      "api_errors_total",
+		Help:      "Number of failed AWS API calls, by service, operation and AWS error code.",
+	}, []string{"service", "operation", "code"})
+
+	// ElastiCachePollIterations counts the times the status of a new cache cluster was polled.
+	ElastiCachePollIterations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "aws",
+		Name:      "elasticache_poll_iterations_total",
+		Help:      "Number of times a new cache cluster was described while waiting for it to become available, by the status it was in.",
+	}, []string{"status"})
+
+	// DBQueryDuration measures how long calls to the model take.
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Time taken by metadata database operations, by operation and result.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation", "result"})
+)
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Result converts an error into the value used for "result" labels.
+func Result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
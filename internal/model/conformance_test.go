@@ -0,0 +1,377 @@
+package model
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"humanitec.io/resources/driver-aws-external/internal/config"
+)
+
+// testModeler runs the conformance tests that every Modeler implementation must pass.
+func testModeler(t *testing.T, db Modeler) {
+	ctx := context.Background()
+
+	t.Run("SelectMissing", func(t *testing.T) {
+		is := is.New(t)
+
+		_, exists, err := db.SelectResourceMetadata(ctx, newTestID())
+
+		is.NoErr(err)
+		is.True(!exists) // unknown ids do not exist
+	})
+
+	t.Run("InsertAndSelect", func(t *testing.T) {
+		is := is.New(t)
+		m := newTestResourceMetadata()
+
+		is.NoErr(db.InsertOrUpdateResourceMetadata(ctx, m))
+		r, exists, err := db.SelectResourceMetadata(ctx, m.ID)
+
+		is.NoErr(err)
+		is.True(exists)
+		is.True(!r.IsDeleted())
+		is.Equal(r.ID, m.ID)
+		is.Equal(r.Type, m.Type)
+		is.Equal(r.Status, StatusReady) // resources are ready unless stated otherwise
+		is.True(r.CreatedAt.Equal(m.CreatedAt))
+		is.True(r.UpdatedAt.Equal(m.UpdatedAt))
+		is.Equal(r.Params, m.Params)
+		is.Equal(r.Data, m.Data)
+		is.Equal(r.Settings, m.Settings)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		is := is.New(t)
+		m := newTestResourceMetadata()
+		is.NoErr(db.InsertOrUpdateResourceMetadata(ctx, m))
+
+		updated := m
+		updated.CreatedAt = m.CreatedAt.Add(time.Hour)
+		updated.UpdatedAt = m.CreatedAt.Add(time.Hour)
+		updated.Data = map[string]interface{}{"bucket": "updated-bucket"}
+		is.NoErr(db.InsertOrUpdateResourceMetadata(ctx, updated))
+		r, exists, err := db.SelectResourceMetadata(ctx, m.ID)
+
+		is.NoErr(err)
+		is.True(exists)
+		is.True(r.CreatedAt.Equal(m.CreatedAt)) // updating keeps the original creation time
+		is.True(r.UpdatedAt.Equal(updated.UpdatedAt))
+		is.Equal(r.Data, updated.Data)
+	})
+
+	t.Run("Settings", func(t *testing.T) {
+		is := is.New(t)
+		m := newTestResourceMetadata()
+		m.Settings = map[string]interface{}{"encryption": "sse-s3", "enforce_tls": true}
+		is.NoErr(db.InsertOrUpdateResourceMetadata(ctx, m))
+
+		r, _, err := db.SelectResourceMetadata(ctx, m.ID)
+		is.NoErr(err)
+		is.Equal(r.Settings, m.Settings)
+	})
+
+	t.Run("Provisioning", func(t *testing.T) {
+		is := is.New(t)
+		m := newTestResourceMetadata()
+		m.Status = StatusProvisioning
+		m.Data = map[string]interface{}{"cluster_id": "redis-1"}
+		is.NoErr(db.InsertOrUpdateResourceMetadata(ctx, m))
+
+		r, _, err := db.SelectResourceMetadata(ctx, m.ID)
+		is.NoErr(err)
+		is.True(r.IsProvisioning())
+		is.Equal(r.Data, m.Data)
+
+		m.Status = StatusReady
+		m.Data = map[string]interface{}{"host": "redis-1.cache.amazonaws.com"}
+		is.NoErr(db.InsertOrUpdateResourceMetadata(ctx, m))
+		r, _, err = db.SelectResourceMetadata(ctx, m.ID)
+		is.NoErr(err)
+		is.True(!r.IsProvisioning()) // provisioning can be completed
+		is.Equal(r.Data, m.Data)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		is := is.New(t)
+		m := newTestResourceMetadata()
+		is.NoErr(db.InsertOrUpdateResourceMetadata(ctx, m))
+		deletedAt := m.CreatedAt.Add(time.Hour)
+
+		is.NoErr(db.DeleteResourceMetadata(ctx, m.ID, deletedAt))
+		r, exists, err := db.SelectResourceMetadata(ctx, m.ID)
+
+		is.NoErr(err)
+		is.True(exists) // deleted resources are still returned
+		is.True(r.IsDeleted())
+		is.True(r.DeletedAt.Time.Equal(deletedAt))
+		is.Equal(r.Data, m.Data)
+
+		is.Equal(db.DeleteResourceMetadata(ctx, m.ID, deletedAt.Add(time.Hour)), ErrNotFound) // already deleted
+		r, _, err = db.SelectResourceMetadata(ctx, m.ID)
+		is.NoErr(err)
+		is.True(r.DeletedAt.Time.Equal(deletedAt)) // deleting again keeps the original deletion time
+	})
+
+	t.Run("PendingDeletion", func(t *testing.T) {
+		is := is.New(t)
+		m := newTestResourceMetadata()
+		is.NoErr(db.InsertOrUpdateResourceMetadata(ctx, m))
+		deleteAfter := m.CreatedAt.Add(time.Hour)
+
+		m.Status = StatusPendingDeletion
+		m.DeleteAfter.Time = deleteAfter
+		m.DeleteAfter.Valid = true
+		m.DeletionRequest = map[string]interface{}{"driver_params": map[string]interface{}{"region": "eu-west-1"}}
+		is.NoErr(db.InsertOrUpdateResourceMetadata(ctx, m))
+		r, _, err := db.SelectResourceMetadata(ctx, m.ID)
+		is.NoErr(err)
+		is.True(r.IsPendingDeletion())
+		is.True(!r.IsDeleted()) // the infrastructure is still there
+		is.True(r.DeleteAfter.Time.Equal(deleteAfter))
+		is.Equal(r.DeletionRequest, m.DeletionRequest)
+
+		due, err := db.SelectResourcesDueForDeletion(ctx, deleteAfter.Add(-time.Second))
+		is.NoErr(err)
+		is.True(!containsID(due, m.ID)) // not due until the grace period ends
+		due, err = db.SelectResourcesDueForDeletion(ctx, deleteAfter)
+		is.NoErr(err)
+		is.True(containsID(due, m.ID))
+
+		m.Status = StatusDeleting
+		is.NoErr(db.InsertOrUpdateResourceMetadata(ctx, m))
+		due, err = db.SelectResourcesDueForDeletion(ctx, deleteAfter)
+		is.NoErr(err)
+		is.True(containsID(due, m.ID)) // still due until its deletion is confirmed
+
+		is.NoErr(db.DeleteResourceMetadata(ctx, m.ID, deleteAfter))
+		r, _, err = db.SelectResourceMetadata(ctx, m.ID)
+		is.NoErr(err)
+		is.True(!r.DeleteAfter.Valid)
+		is.Equal(len(r.DeletionRequest), 0) // the credentials are not kept once deleted
+		due, err = db.SelectResourcesDueForDeletion(ctx, deleteAfter)
+		is.NoErr(err)
+		is.True(!containsID(due, m.ID))
+	})
+
+	t.Run("DeleteMissing", func(t *testing.T) {
+		is := is.New(t)
+
+		is.Equal(db.DeleteResourceMetadata(ctx, newTestID(), time.Now().UTC()), ErrNotFound)
+	})
+
+	t.Run("RecreateDeleted", func(t *testing.T) {
+		is := is.New(t)
+		m := newTestResourceMetadata()
+		is.NoErr(db.InsertOrUpdateResourceMetadata(ctx, m))
+		is.NoErr(db.DeleteResourceMetadata(ctx, m.ID, m.CreatedAt.Add(time.Hour)))
+
+		recreated := m
+		recreated.CreatedAt = m.CreatedAt.Add(2 * time.Hour)
+		recreated.Data = map[string]interface{}{"bucket": "new-bucket"}
+		is.NoErr(db.InsertOrUpdateResourceMetadata(ctx, recreated))
+		r, exists, err := db.SelectResourceMetadata(ctx, m.ID)
+
+		is.NoErr(err)
+		is.True(exists)
+		is.True(!r.IsDeleted())
+		is.True(r.CreatedAt.Equal(recreated.CreatedAt)) // re-creating resets the creation time
+		is.Equal(r.Data, recreated.Data)
+	})
+
+	t.Run("Lock", func(t *testing.T) {
+		is := is.New(t)
+		id := newTestID()
+
+		locked, err := db.LockResource(ctx, id)
+		is.NoErr(err)
+		is.True(locked)
+
+		locked, err = db.LockResource(ctx, id)
+		is.NoErr(err)
+		is.True(!locked) // a held lock cannot be taken again
+
+		otherLocked, err := db.LockResource(ctx, newTestID())
+		is.NoErr(err)
+		is.True(otherLocked) // locks are per resource
+
+		is.NoErr(db.UnlockResource(ctx, id))
+		locked, err = db.LockResource(ctx, id)
+		is.NoErr(err)
+		is.True(locked) // the lock can be taken once released
+		is.NoErr(db.UnlockResource(ctx, id))
+	})
+
+	t.Run("UnlockNotHeld", func(t *testing.T) {
+		is := is.New(t)
+
+		is.Equal(db.UnlockResource(ctx, newTestID()), ErrNotFound)
+	})
+
+	t.Run("UseSignature", func(t *testing.T) {
+		is := is.New(t)
+		signature := newTestID()
+
+		used, err := db.UseSignature(ctx, signature, time.Now().Add(time.Minute))
+		is.NoErr(err)
+		is.True(used)
+
+		used, err = db.UseSignature(ctx, signature, time.Now().Add(time.Minute))
+		is.NoErr(err)
+		is.True(!used) // a signature cannot be used twice
+
+		expired := newTestID()
+		used, err = db.UseSignature(ctx, expired, time.Now().Add(-time.Minute))
+		is.NoErr(err)
+		is.True(used)
+		used, err = db.UseSignature(ctx, expired, time.Now().Add(time.Minute))
+		is.NoErr(err)
+		is.True(used) // expired signatures are forgotten
+	})
+}
+
+func newTestID() string {
+	return "test-" + uuid.New().String()
+}
+
+func containsID(ids []string, id string) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+func newTestResourceMetadata() ResourceMetadata {
+	return ResourceMetadata{
+		ID:        newTestID(),
+		Type:      "s3",
+		CreatedAt: time.Date(2020, 07, 16, 18, 12, 20, 0, time.UTC),
+		UpdatedAt: time.Date(2020, 07, 16, 18, 12, 20, 0, time.UTC),
+		Params: map[string]interface{}{
+			"region": "eu-west-1",
+		},
+		Data: map[string]interface{}{
+			"region": "eu-west-1",
+			"bucket": "my-s3-bucket",
+		},
+	}
+}
+
+func TestMemoryModel(t *testing.T) {
+	testModeler(t, newMemoryModel())
+}
+
+func TestInstrumentedModel(t *testing.T) {
+	testModeler(t, Instrument(newMemoryModel()))
+}
+
+func TestTracedModel(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	testModeler(t, Trace(newMemoryModel()))
+
+	is := is.New(t)
+	spans := map[string]int{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()]++
+	}
+	is.True(spans["model.SelectResourceMetadata"] > 0)
+	is.True(spans["model.InsertOrUpdateResourceMetadata"] > 0)
+	is.True(spans["model.DeleteResourceMetadata"] > 0)
+	is.True(spans["model.LockResource"] > 0)
+	is.True(spans["model.UnlockResource"] > 0)
+	is.True(spans["model.UseSignature"] > 0)
+}
+
+// testLocks checks that held resource locks do not use up the connection pool of a model and that they are released
+// once their lease runs out.
+func testLocks(t *testing.T, db model) {
+	ctx := context.Background()
+	db.SetMaxOpenConns(1)
+
+	t.Run("CappedPool", func(t *testing.T) {
+		is := is.New(t)
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		ids := []string{newTestID(), newTestID(), newTestID()}
+		for _, id := range ids {
+			locked, err := db.LockResource(ctx, id)
+			is.NoErr(err)
+			is.True(locked)
+		}
+
+		m := newTestResourceMetadata()
+		is.NoErr(db.InsertOrUpdateResourceMetadata(ctx, m)) // queries do not wait for the held locks
+		_, exists, err := db.SelectResourceMetadata(ctx, m.ID)
+		is.NoErr(err)
+		is.True(exists)
+		is.NoErr(db.Ping(ctx))
+
+		for _, id := range ids {
+			is.NoErr(db.UnlockResource(ctx, id))
+		}
+	})
+
+	t.Run("LeaseRunsOut", func(t *testing.T) {
+		is := is.New(t)
+		id := newTestID()
+		expired := time.Now().UTC().Add(-time.Minute).Truncate(time.Second)
+		_, err := db.ExecContext(ctx, `INSERT INTO resource_locks (resource_id, owner, expires_at) VALUES ($1, $2, $3)`,
+			id, "replica-that-died", expired)
+		is.NoErr(err)
+
+		locked, err := db.LockResource(ctx, id)
+
+		is.NoErr(err)
+		is.True(locked) // locks whose lease ran out can be taken
+		is.NoErr(db.UnlockResource(ctx, id))
+	})
+}
+
+func TestSQLiteModel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "model-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := openSQLite(filepath.Join(dir, "metadata.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	testModeler(t, db)
+	testLocks(t, db.model)
+}
+
+// TestPostgresModel runs against the database configured via the usual environment variables. E.g. the one started by
+// docker-compose.
+func TestPostgresModel(t *testing.T) {
+	if os.Getenv("DATABASE_HOST") == "" {
+		t.Skip("DATABASE_HOST not set.")
+	}
+
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := setupPostgres(cfg.Database).(model)
+	defer db.Close()
+
+	testModeler(t, db)
+	testLocks(t, db)
+}
@@ -0,0 +1,132 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"humanitec.io/resources/driver-aws-external/internal/logging"
+)
+
+// lockLease is how long a resource lock is held for without being renewed. Held locks are renewed every third of it, so
+// a lock held by a replica that has gone away is released after at most this long.
+const lockLease = 30 * time.Second
+
+// resourceLocks keeps track of the resource locks held by this process.
+//
+// Locks are rows in the resource_locks table, leased to the process that took them. Taking, renewing and releasing a
+// lock are each a single statement, so holding a lock does not keep a connection out of the pool for the time the
+// resource is being worked on.
+type resourceLocks struct {
+	mu    sync.Mutex
+	held  map[string]*heldLock
+	lease time.Duration
+}
+
+// heldLock is a lock held by this process, renewed in the background until stop is closed.
+type heldLock struct {
+	owner string
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+func newResourceLocks() *resourceLocks {
+	return &resourceLocks{held: map[string]*heldLock{}, lease: lockLease}
+}
+
+// expiry returns when a lock taken or renewed at now expires.
+//
+// NOTE: Times are truncated to the second as SQLite compares timestamps as strings, which only sort correctly when they
+// all have the same precision.
+func (l *resourceLocks) expiry(now time.Time) time.Time {
+	return now.Add(l.lease).UTC().Truncate(time.Second)
+}
+
+// LockResource attempts to take an exclusive lock on the resource with the supplied id. It does not block: if the lock
+// is already held, by this or any other replica, it returns false.
+func (db model) LockResource(ctx context.Context, id string) (bool, error) {
+	db.locks.mu.Lock()
+	if _, held := db.locks.held[id]; held {
+		db.locks.mu.Unlock()
+		return false, nil
+	}
+	// Reserves the lock within the process, so that the database is not held up while it is taken.
+	h := &heldLock{owner: uuid.New().String(), stop: make(chan struct{}), done: make(chan struct{})}
+	db.locks.held[id] = h
+	db.locks.mu.Unlock()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	res, err := db.ExecContext(ctx, `INSERT INTO resource_locks (resource_id, owner, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (resource_id) DO UPDATE SET owner = excluded.owner, expires_at = excluded.expires_at
+		WHERE resource_locks.expires_at < $4`, id, h.owner, db.locks.expiry(now), now)
+	var taken int64
+	if err == nil {
+		taken, err = res.RowsAffected()
+	}
+	if err != nil || taken == 0 {
+		db.locks.mu.Lock()
+		delete(db.locks.held, id)
+		db.locks.mu.Unlock()
+	}
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).Errorf("Database error locking resource with id %s.", id)
+		return false, fmt.Errorf("lock resource with id %s: %w", id, err)
+	}
+	if taken == 0 {
+		return false, nil
+	}
+
+	go db.renewLock(id, h)
+	return true, nil
+}
+
+// renewLock extends the lease of a held lock until it is released.
+func (db model) renewLock(id string, h *heldLock) {
+	defer close(h.done)
+	ticker := time.NewTicker(db.locks.lease / 3)
+	defer ticker.Stop()
+	l := logging.Base().WithField("resource_id", id)
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), db.locks.lease/3)
+		res, err := db.ExecContext(ctx, `UPDATE resource_locks SET expires_at = $1 WHERE resource_id = $2 AND owner = $3`,
+			db.locks.expiry(time.Now()), id, h.owner)
+		cancel()
+		if err != nil {
+			l.WithError(err).Error("Database error renewing resource lock. It will be retried.")
+			continue
+		}
+		if renewed, err := res.RowsAffected(); err == nil && renewed == 0 {
+			l.Error("Resource lock was lost as its lease ran out before it could be renewed.")
+			return
+		}
+	}
+}
+
+// UnlockResource releases a lock previously taken with LockResource.
+func (db model) UnlockResource(ctx context.Context, id string) error {
+	db.locks.mu.Lock()
+	h, held := db.locks.held[id]
+	delete(db.locks.held, id)
+	db.locks.mu.Unlock()
+	if !held {
+		return ErrNotFound
+	}
+	close(h.stop)
+	<-h.done
+
+	// The lock must be released even if the request has been cancelled, otherwise it would be held until its lease runs
+	// out.
+	_, err := db.ExecContext(context.Background(), `DELETE FROM resource_locks WHERE resource_id = $1 AND owner = $2`, id, h.owner)
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).Errorf("Database error unlocking resource with id %s.", id)
+		return fmt.Errorf("unlock resource with id %s: %w", id, err)
+	}
+	return nil
+}
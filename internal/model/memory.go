@@ -0,0 +1,190 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryModel is a Modeler that holds all metadata in memory. Nothing is persisted between runs, so it is only useful
+// for local development and testing.
+type memoryModel struct {
+	mu         sync.Mutex
+	resources  map[string]ResourceMetadata
+	locks      *processLocks
+	signatures map[string]time.Time
+}
+
+func newMemoryModel() *memoryModel {
+	return &memoryModel{
+		resources:  map[string]ResourceMetadata{},
+		locks:      newProcessLocks(),
+		signatures: map[string]time.Time{},
+	}
+}
+
+// SelectResourceMetadata fetches the metadata for a resource, including resources that have been deleted.
+func (db *memoryModel) SelectResourceMetadata(ctx context.Context, id string) (ResourceMetadata, bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	r, exists := db.resources[id]
+	if !exists {
+		return ResourceMetadata{}, false, nil
+	}
+	r, err := copyResourceMetadata(r)
+	if err != nil {
+		return ResourceMetadata{}, false, err
+	}
+	return r, true, nil
+}
+
+// InsertOrUpdateResource adds or updates resource metadata. Updating a deleted resource brings it back to life.
+func (db *memoryModel) InsertOrUpdateResourceMetadata(ctx context.Context, m ResourceMetadata) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	r, err := copyResourceMetadata(m)
+	if err != nil {
+		return err
+	}
+	r.Status = m.status()
+	if existing, exists := db.resources[m.ID]; exists && !existing.IsDeleted() {
+		r.CreatedAt = existing.CreatedAt
+	}
+	r.DeletedAt.Valid = false
+	r.DeletedAt.Time = time.Time{}
+	db.resources[m.ID] = r
+	return nil
+}
+
+// DeleteResourceMetadata marks the metadata for a resource as deleted. Returns ErrNotFound if there is no live resource
+// with the id.
+func (db *memoryModel) DeleteResourceMetadata(ctx context.Context, id string, deletedAt time.Time) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	r, exists := db.resources[id]
+	if !exists || r.IsDeleted() {
+		return ErrNotFound
+	}
+	r.DeletedAt.Time = deletedAt
+	r.DeletedAt.Valid = true
+	r.DeleteAfter = sql.NullTime{}
+	r.DeletionRequest = map[string]interface{}{}
+	db.resources[id] = r
+	return nil
+}
+
+// SelectResourcesDueForDeletion returns the ids of resources pending deletion whose grace period ended by now, and of
+// resources being deleted, those due first first.
+func (db *memoryModel) SelectResourcesDueForDeletion(ctx context.Context, now time.Time) ([]string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var due []ResourceMetadata
+	for _, r := range db.resources {
+		if (r.IsPendingDeletion() || r.IsDeleting()) && !r.IsDeleted() && r.DeleteAfter.Valid && !r.DeleteAfter.Time.After(now) {
+			due = append(due, r)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].DeleteAfter.Time.Before(due[j].DeleteAfter.Time) })
+	var ids []string
+	for _, r := range due {
+		ids = append(ids, r.ID)
+	}
+	return ids, nil
+}
+
+// LockResource attempts to take an exclusive lock on the resource with the supplied id.
+func (db *memoryModel) LockResource(ctx context.Context, id string) (bool, error) {
+	return db.locks.lock(id), nil
+}
+
+// UnlockResource releases a lock previously taken with LockResource.
+func (db *memoryModel) UnlockResource(ctx context.Context, id string) error {
+	return db.locks.unlock(id)
+}
+
+// UseSignature records that a request signature has been accepted, until expiresAt.
+func (db *memoryModel) UseSignature(ctx context.Context, signature string, expiresAt time.Time) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	now := time.Now()
+	for s, expires := range db.signatures {
+		if now.After(expires) {
+			delete(db.signatures, s)
+		}
+	}
+	if _, used := db.signatures[signature]; used {
+		return false, nil
+	}
+	db.signatures[signature] = expiresAt
+	return true, nil
+}
+
+// Ping always succeeds as there is no database to reach.
+func (db *memoryModel) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Close does nothing as there is no database to close.
+func (db *memoryModel) Close() error {
+	return nil
+}
+
+// CheckSchema always succeeds as there is no schema to migrate.
+func (db *memoryModel) CheckSchema(ctx context.Context) error {
+	return nil
+}
+
+// copyResourceMetadata makes a deep copy of the metadata by round-tripping Params, Data, Settings and DeletionRequest through JSON, the same way
+// they would be if they were stored in a database.
+func copyResourceMetadata(m ResourceMetadata) (ResourceMetadata, error) {
+	for _, field := range []*map[string]interface{}{&m.Params, &m.Data, &m.Settings, &m.DeletionRequest} {
+		b, err := json.Marshal(*field)
+		if err != nil {
+			return ResourceMetadata{}, err
+		}
+		*field = nil
+		if err := json.Unmarshal(b, field); err != nil {
+			return ResourceMetadata{}, err
+		}
+	}
+	return m, nil
+}
+
+// processLocks provides resource locks for Modelers that are only ever used by a single process.
+type processLocks struct {
+	mu   sync.Mutex
+	held map[string]bool
+}
+
+func newProcessLocks() *processLocks {
+	return &processLocks{held: map[string]bool{}}
+}
+
+func (l *processLocks) lock(id string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.held[id] {
+		return false
+	}
+	l.held[id] = true
+	return true
+}
+
+func (l *processLocks) unlock(id string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.held[id] {
+		return ErrNotFound
+	}
+	delete(l.held, id)
+	return nil
+}
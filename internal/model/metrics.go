@@ -0,0 +1,91 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"humanitec.io/resources/driver-aws-external/internal/metrics"
+)
+
+// instrumentedModel records the latency of every call to the Modeler it wraps.
+type instrumentedModel struct {
+	next Modeler
+}
+
+// Instrument wraps a Modeler so that the duration of each call is recorded in metrics.DBQueryDuration.
+func Instrument(m Modeler) Modeler {
+	return instrumentedModel{m}
+}
+
+func observe(operation string, start time.Time, err error) {
+	metrics.DBQueryDuration.WithLabelValues(operation, metrics.Result(err)).Observe(time.Since(start).Seconds())
+}
+
+func (m instrumentedModel) InsertOrUpdateResourceMetadata(ctx context.Context, r ResourceMetadata) error {
+	start := time.Now()
+	err := m.next.InsertOrUpdateResourceMetadata(ctx, r)
+	observe("InsertOrUpdateResourceMetadata", start, err)
+	return err
+}
+
+func (m instrumentedModel) SelectResourceMetadata(ctx context.Context, id string) (ResourceMetadata, bool, error) {
+	start := time.Now()
+	r, exists, err := m.next.SelectResourceMetadata(ctx, id)
+	observe("SelectResourceMetadata", start, err)
+	return r, exists, err
+}
+
+func (m instrumentedModel) DeleteResourceMetadata(ctx context.Context, id string, deletedAt time.Time) error {
+	start := time.Now()
+	err := m.next.DeleteResourceMetadata(ctx, id, deletedAt)
+	// Deleting a resource that does not exist is not a database failure.
+	if err == ErrNotFound {
+		observe("DeleteResourceMetadata", start, nil)
+	} else {
+		observe("DeleteResourceMetadata", start, err)
+	}
+	return err
+}
+
+func (m instrumentedModel) SelectResourcesDueForDeletion(ctx context.Context, now time.Time) ([]string, error) {
+	start := time.Now()
+	ids, err := m.next.SelectResourcesDueForDeletion(ctx, now)
+	observe("SelectResourcesDueForDeletion", start, err)
+	return ids, err
+}
+
+func (m instrumentedModel) LockResource(ctx context.Context, id string) (bool, error) {
+	start := time.Now()
+	locked, err := m.next.LockResource(ctx, id)
+	observe("LockResource", start, err)
+	return locked, err
+}
+
+func (m instrumentedModel) UnlockResource(ctx context.Context, id string) error {
+	start := time.Now()
+	err := m.next.UnlockResource(ctx, id)
+	observe("UnlockResource", start, err)
+	return err
+}
+
+func (m instrumentedModel) UseSignature(ctx context.Context, signature string, expiresAt time.Time) (bool, error) {
+	start := time.Now()
+	used, err := m.next.UseSignature(ctx, signature, expiresAt)
+	observe("UseSignature", start, err)
+	return used, err
+}
+
+// Ping is not instrumented as it is only called by health checks.
+func (m instrumentedModel) Ping(ctx context.Context) error {
+	return m.next.Ping(ctx)
+}
+
+// Close is not instrumented as it is only called on shutdown.
+func (m instrumentedModel) Close() error {
+	return m.next.Close()
+}
+
+// CheckSchema is not instrumented as it is only called by health checks.
+func (m instrumentedModel) CheckSchema(ctx context.Context) error {
+	return m.next.CheckSchema(ctx)
+}
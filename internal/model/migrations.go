@@ -0,0 +1,107 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"humanitec.io/resources/driver-aws-external/internal/logging"
+)
+
+// migrations bring the resource_metadata table created by initDb (or initSQLiteDb) up to date. They are applied in
+// order and each is applied at most once. The number applied is recorded in the schema_migrations table.
+//
+// NOTE: Migrations must work on both Postgres and SQLite. Only ever append to this list.
+var migrations = []string{
+	// 1: Track resources whose provisioning was interrupted so that it can be resumed.
+	`ALTER TABLE resource_metadata ADD COLUMN status TEXT NOT NULL DEFAULT 'ready'`,
+	// 2: Record the settings applied to the resource, e.g. bucket encryption.
+	`ALTER TABLE resource_metadata ADD COLUMN settings JSONB NOT NULL DEFAULT '{}'`,
+	// 3: Schedule the deletion of resources deleted with a grace period.
+	`ALTER TABLE resource_metadata ADD COLUMN delete_after TIMESTAMP`,
+	// 4: Keep what is needed to delete them once it ends.
+	`ALTER TABLE resource_metadata ADD COLUMN deletion_request JSONB NOT NULL DEFAULT '{}'`,
+	// 5: Lock resources with leased rows rather than advisory locks, which keep a connection checked out while held.
+	`CREATE TABLE resource_locks (
+		resource_id TEXT NOT NULL,
+		owner       TEXT NOT NULL,
+		expires_at  TIMESTAMP NOT NULL,
+		PRIMARY KEY (resource_id)
+	)`,
+	// 6: Record the request signatures accepted by any replica, so that requests cannot be replayed against another.
+	`CREATE TABLE used_signatures (
+		signature  TEXT NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (signature)
+	)`,
+}
+
+// migrate applies any migrations that have not yet been applied, all in a single transaction. lockStmt, if not empty,
+// is run first to stop other replicas migrating at the same time.
+func migrate(db *sql.DB, lockStmt string) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL)`)
+	if err != nil {
+		logging.Base().WithError(err).Error("Unable to create schema_migrations table.")
+		return fmt.Errorf("create schema_migrations table: %w", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	defer tx.Rollback()
+
+	if lockStmt != "" {
+		if _, err := tx.Exec(lockStmt); err != nil {
+			return fmt.Errorf("migrate: locking schema_migrations: %w", err)
+		}
+	}
+	version, err := schemaVersion(context.Background(), tx)
+	if err != nil {
+		return err
+	}
+	for ; version < len(migrations); version++ {
+		logging.Base().Infof("Applying database migration %d.", version+1)
+		if _, err := tx.Exec(migrations[version]); err != nil {
+			logging.Base().WithError(err).Errorf("Unable to apply database migration %d.", version+1)
+			return fmt.Errorf("apply migration %d: %w", version+1, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES ($1)`, version+1); err != nil {
+			return fmt.Errorf("record migration %d: %w", version+1, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// schemaVersion returns the number of migrations that have been applied.
+func schemaVersion(ctx context.Context, q interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}) (int, error) {
+	var version int
+	err := q.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// Ping checks that the database can be reached.
+func (db model) Ping(ctx context.Context) error {
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("ping database: %w", err)
+	}
+	return nil
+}
+
+// CheckSchema returns ErrMigrationsPending if any migrations have not been applied. A schema that is newer than this
+// version of the driver knows about is fine, as happens while a new version is being rolled out.
+func (db model) CheckSchema(ctx context.Context) error {
+	version, err := schemaVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+	if version < len(migrations) {
+		return fmt.Errorf("schema version %d, expected %d: %w", version, len(migrations), ErrMigrationsPending)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestMigrateSQLite(t *testing.T) {
+	is := is.New(t)
+	dir, err := ioutil.TempDir("", "model-test")
+	is.NoErr(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "metadata.db")
+
+	// A database created before any migrations existed.
+	old, err := sql.Open("sqlite3", path)
+	is.NoErr(err)
+	_, err = old.Exec(`CREATE TABLE resource_metadata (
+			id          TEXT NOT NULL,
+			type        TEXT NOT NULL,
+			created_at  TIMESTAMP NOT NULL,
+			updated_at  TIMESTAMP NOT NULL,
+			deleted_at  TIMESTAMP,
+			params      TEXT NOT NULL,
+			data        TEXT NOT NULL,
+			PRIMARY KEY (id)
+	)`)
+	is.NoErr(err)
+	_, err = old.Exec(`INSERT INTO resource_metadata VALUES ('old-resource', 's3', '2020-07-16 18:12:20', '2020-07-16 18:12:20', NULL, '{}', '{}')`)
+	is.NoErr(err)
+	is.NoErr(old.Close())
+
+	db, err := openSQLite(path)
+	is.NoErr(err)
+	r, exists, err := db.SelectResourceMetadata(context.Background(), "old-resource")
+	is.NoErr(err)
+	is.True(exists)
+	is.Equal(r.Status, StatusReady) // existing resources are ready
+	is.NoErr(db.Close())
+
+	db, err = openSQLite(path)
+	is.NoErr(err) // migrations are only applied once
+	defer db.Close()
+	version, err := schemaVersion(context.Background(), db)
+	is.NoErr(err)
+	is.Equal(version, len(migrations))
+	is.NoErr(db.CheckSchema(context.Background()))
+}
+
+func TestCheckSchema_Pending(t *testing.T) {
+	is := is.New(t)
+	dir, err := ioutil.TempDir("", "model-test")
+	is.NoErr(err)
+	defer os.RemoveAll(dir)
+
+	db, err := openSQLite(filepath.Join(dir, "metadata.db"))
+	is.NoErr(err)
+	defer db.Close()
+	_, err = db.Exec(`DELETE FROM schema_migrations`)
+	is.NoErr(err)
+
+	is.True(errors.Is(db.CheckSchema(context.Background()), ErrMigrationsPending))
+}
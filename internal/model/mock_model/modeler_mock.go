@@ -5,6 +5,7 @@
 package mock_model
 
 import (
+	context "context"
 	gomock "github.com/golang/mock/gomock"
 	model "humanitec.io/resources/driver-aws-external/internal/model"
 	reflect "reflect"
@@ -34,38 +35,95 @@ func (m *MockModeler) EXPECT() *MockModelerMockRecorder {
 	return m.recorder
 }
 
+// CheckSchema mocks base method
+func (m *MockModeler) CheckSchema(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckSchema", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckSchema indicates an expected call of CheckSchema
+func (mr *MockModelerMockRecorder) CheckSchema(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckSchema", reflect.TypeOf((*MockModeler)(nil).CheckSchema), arg0)
+}
+
+// Close mocks base method
+func (m *MockModeler) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close
+func (mr *MockModelerMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockModeler)(nil).Close))
+}
+
 // DeleteResourceMetadata mocks base method
-func (m *MockModeler) DeleteResourceMetadata(arg0 string, arg1 time.Time) error {
+func (m *MockModeler) DeleteResourceMetadata(arg0 context.Context, arg1 string, arg2 time.Time) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteResourceMetadata", arg0, arg1)
+	ret := m.ctrl.Call(m, "DeleteResourceMetadata", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteResourceMetadata indicates an expected call of DeleteResourceMetadata
-func (mr *MockModelerMockRecorder) DeleteResourceMetadata(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockModelerMockRecorder) DeleteResourceMetadata(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteResourceMetadata", reflect.TypeOf((*MockModeler)(nil).DeleteResourceMetadata), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteResourceMetadata", reflect.TypeOf((*MockModeler)(nil).DeleteResourceMetadata), arg0, arg1, arg2)
 }
 
 // InsertOrUpdateResourceMetadata mocks base method
-func (m *MockModeler) InsertOrUpdateResourceMetadata(arg0 model.ResourceMetadata) error {
+func (m *MockModeler) InsertOrUpdateResourceMetadata(arg0 context.Context, arg1 model.ResourceMetadata) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "InsertOrUpdateResourceMetadata", arg0)
+	ret := m.ctrl.Call(m, "InsertOrUpdateResourceMetadata", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // InsertOrUpdateResourceMetadata indicates an expected call of InsertOrUpdateResourceMetadata
-func (mr *MockModelerMockRecorder) InsertOrUpdateResourceMetadata(arg0 interface{}) *gomock.Call {
+func (mr *MockModelerMockRecorder) InsertOrUpdateResourceMetadata(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertOrUpdateResourceMetadata", reflect.TypeOf((*MockModeler)(nil).InsertOrUpdateResourceMetadata), arg0, arg1)
+}
+
+// LockResource mocks base method
+func (m *MockModeler) LockResource(arg0 context.Context, arg1 string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockResource", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LockResource indicates an expected call of LockResource
+func (mr *MockModelerMockRecorder) LockResource(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockResource", reflect.TypeOf((*MockModeler)(nil).LockResource), arg0, arg1)
+}
+
+// Ping mocks base method
+func (m *MockModeler) Ping(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping
+func (mr *MockModelerMockRecorder) Ping(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertOrUpdateResourceMetadata", reflect.TypeOf((*MockModeler)(nil).InsertOrUpdateResourceMetadata), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockModeler)(nil).Ping), arg0)
 }
 
 // SelectResourceMetadata mocks base method
-func (m *MockModeler) SelectResourceMetadata(arg0 string) (model.ResourceMetadata, bool, error) {
+func (m *MockModeler) SelectResourceMetadata(arg0 context.Context, arg1 string) (model.ResourceMetadata, bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SelectResourceMetadata", arg0)
+	ret := m.ctrl.Call(m, "SelectResourceMetadata", arg0, arg1)
 	ret0, _ := ret[0].(model.ResourceMetadata)
 	ret1, _ := ret[1].(bool)
 	ret2, _ := ret[2].(error)
@@ -73,7 +131,51 @@ func (m *MockModeler) SelectResourceMetadata(arg0 string) (model.ResourceMetadat
 }
 
 // SelectResourceMetadata indicates an expected call of SelectResourceMetadata
-func (mr *MockModelerMockRecorder) SelectResourceMetadata(arg0 interface{}) *gomock.Call {
+func (mr *MockModelerMockRecorder) SelectResourceMetadata(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectResourceMetadata", reflect.TypeOf((*MockModeler)(nil).SelectResourceMetadata), arg0, arg1)
+}
+
+// SelectResourcesDueForDeletion mocks base method
+func (m *MockModeler) SelectResourcesDueForDeletion(arg0 context.Context, arg1 time.Time) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectResourcesDueForDeletion", arg0, arg1)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectResourcesDueForDeletion indicates an expected call of SelectResourcesDueForDeletion
+func (mr *MockModelerMockRecorder) SelectResourcesDueForDeletion(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectResourcesDueForDeletion", reflect.TypeOf((*MockModeler)(nil).SelectResourcesDueForDeletion), arg0, arg1)
+}
+
+// UnlockResource mocks base method
+func (m *MockModeler) UnlockResource(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnlockResource", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnlockResource indicates an expected call of UnlockResource
+func (mr *MockModelerMockRecorder) UnlockResource(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnlockResource", reflect.TypeOf((*MockModeler)(nil).UnlockResource), arg0, arg1)
+}
+
+// UseSignature mocks base method
+func (m *MockModeler) UseSignature(arg0 context.Context, arg1 string, arg2 time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseSignature", arg0, arg1, arg2)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseSignature indicates an expected call of UseSignature
+func (mr *MockModelerMockRecorder) UseSignature(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectResourceMetadata", reflect.TypeOf((*MockModeler)(nil).SelectResourceMetadata), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseSignature", reflect.TypeOf((*MockModeler)(nil).UseSignature), arg0, arg1, arg2)
 }
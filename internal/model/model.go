@@ -1,70 +1,94 @@
 package model
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
 	"time"
+
+	"humanitec.io/resources/driver-aws-external/internal/logging"
 )
 
-// SelectAllAccessibleDrivers fetches all Drivers that belong to an org or are marked as public.
-func (db model) SelectResourceMetadata(id string) (ResourceMetadata, bool, error) {
-	row := db.QueryRow(`SELECT
+// SelectResourceMetadata fetches the metadata for a resource, including resources that have been deleted. Use
+// ResourceMetadata.IsDeleted to tell them apart.
+func (db model) SelectResourceMetadata(ctx context.Context, id string) (ResourceMetadata, bool, error) {
+	row := db.QueryRowContext(ctx, `SELECT
 		id,
 		type,
+		status,
 		created_at,
 		updated_at,
 		deleted_at,
 		params,
-		data
+		data,
+		settings,
+		delete_after,
+		deletion_request
     FROM resource_metadata
     WHERE id = $1`, id)
 
 	var r ResourceMetadata
-	err := row.Scan(&r.ID, &r.Type, &r.CreatedAt, &r.UpdatedAt, &r.DeletedAt, AsJSON(&r.Params), AsJSON(&r.Data))
+	err := row.Scan(&r.ID, &r.Type, &r.Status, &r.CreatedAt, &r.UpdatedAt, &r.DeletedAt, AsJSON(&r.Params), AsJSON(&r.Data), AsJSON(&r.Settings), &r.DeleteAfter, AsJSON(&r.DeletionRequest))
 	if err == sql.ErrNoRows {
 		return ResourceMetadata{}, false, nil
 	} else if err != nil {
-		log.Printf("Database error fetching resource_metadata with id %s. (%v)", id, err)
+		logging.FromContext(ctx).WithError(err).Errorf("Database error fetching resource_metadata with id %s.", id)
 		return ResourceMetadata{}, false, fmt.Errorf("select resource_metadata with id %s: %w", id, err)
 	}
 
 	return r, true, nil
 }
 
-// InsertOrUpdateResource adds or updates resource metadata.
-func (db model) InsertOrUpdateResourceMetadata(m ResourceMetadata) error {
-	_, err := db.Exec(`INSERT INTO resource_metadata (
+// InsertOrUpdateResource adds or updates resource metadata. Updating a deleted resource brings it back to life. The
+// creation time of a live resource is kept, the time of the update is taken from m.UpdatedAt.
+func (db model) InsertOrUpdateResourceMetadata(ctx context.Context, m ResourceMetadata) error {
+	_, err := db.ExecContext(ctx, `INSERT INTO resource_metadata (
 		id,
 		type,
+		status,
 		created_at,
 		updated_at,
 		deleted_at,
 		params,
-		data
+		data,
+		settings,
+		delete_after,
+		deletion_request
   )
-	VALUES ($1, $2, $3, $3, NULL, $4, $5)
+	VALUES ($1, $2, $3, $4, $5, NULL, $6, $7, $8, $9, $10)
 	ON CONFLICT (id) DO
-		UPDATE SET updated_at = $3, deleted_at = NULL, params = $4, data = $5 WHERE resource_metadata.id = $1
+		UPDATE SET
+			type = $2,
+			status = $3,
+			created_at = CASE WHEN resource_metadata.deleted_at IS NULL THEN resource_metadata.created_at ELSE $4 END,
+			updated_at = $5,
+			deleted_at = NULL,
+			params = $6,
+			data = $7,
+			settings = $8,
+			delete_after = $9,
+			deletion_request = $10
+		WHERE resource_metadata.id = $1
 `,
-		m.ID, m.Type, m.CreatedAt, *AsJSON(&m.Params), *AsJSON(&m.Data))
+		m.ID, m.Type, m.status(), m.CreatedAt, m.UpdatedAt, *AsJSON(&m.Params), *AsJSON(&m.Data), *AsJSON(&m.Settings), m.DeleteAfter, *AsJSON(&m.DeletionRequest))
 	if err != nil {
-		log.Printf("Database error inserting resource_metadata with ID %s. (%v)", m.ID, err)
+		logging.FromContext(ctx).WithError(err).Errorf("Database error inserting resource_metadata with ID %s.", m.ID)
 		return fmt.Errorf("insert resource_metadata with id %s: %w", m.ID, err)
 	}
 	return nil
 }
 
-// DeleteResourceMetadata removes an appenv.Application.  Also removes all associated environments.
-func (db model) DeleteResourceMetadata(id string, deletedAt time.Time) error {
-	result, err := db.Exec(`UPDATE resource_metadata SET deleted_at = $2 WHERE id = $1`, id, deletedAt)
+// DeleteResourceMetadata marks the metadata for a resource as deleted, dropping any deletion request kept for it.
+// Returns ErrNotFound if there is no live resource with the id.
+func (db model) DeleteResourceMetadata(ctx context.Context, id string, deletedAt time.Time) error {
+	result, err := db.ExecContext(ctx, `UPDATE resource_metadata SET deleted_at = $1, delete_after = NULL, deletion_request = '{}' WHERE id = $2 AND deleted_at IS NULL`, deletedAt, id)
 	if err != nil {
-		log.Printf("Database error deleting resource_metadata with id %s. (%v)", id, err)
+		logging.FromContext(ctx).WithError(err).Errorf("Database error deleting resource_metadata with id %s.", id)
 		return fmt.Errorf("delete resource_metadata with id %s: %w", id, err)
 	}
 	rows, err := result.RowsAffected()
 	if err != nil {
-		log.Printf("Database retrieving rows-affected count after deleting resource_metadata with id %s. (%v)", id, err)
+		logging.FromContext(ctx).WithError(err).Errorf("Database retrieving rows-affected count after deleting resource_metadata with id %s.", id)
 		return fmt.Errorf("delete resource_metadata with id %s: %w", id, err)
 	}
 
@@ -73,3 +97,31 @@ func (db model) DeleteResourceMetadata(id string, deletedAt time.Time) error {
 	}
 	return nil
 }
+
+// SelectResourcesDueForDeletion returns the ids of resources pending deletion whose grace period ended by now, and of
+// resources being deleted, those due first first.
+func (db model) SelectResourcesDueForDeletion(ctx context.Context, now time.Time) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT id
+    FROM resource_metadata
+    WHERE status IN ($1, $2) AND deleted_at IS NULL AND delete_after <= $3
+    ORDER BY delete_after`, StatusPendingDeletion, StatusDeleting, now.UTC())
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).Error("Database error fetching resources due for deletion.")
+		return nil, fmt.Errorf("select resources due for deletion: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("select resources due for deletion: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		logging.FromContext(ctx).WithError(err).Error("Database error fetching resources due for deletion.")
+		return nil, fmt.Errorf("select resources due for deletion: %w", err)
+	}
+	return ids, nil
+}
@@ -3,33 +3,56 @@ package model
 import (
 	"database/sql"
 	"fmt"
-	"log"
-	"os"
 	"strings"
 	"time"
 
 	_ "github.com/lib/pq"
+	"humanitec.io/resources/driver-aws-external/internal/config"
+	"humanitec.io/resources/driver-aws-external/internal/logging"
 )
 
-// processDbEnvVar correctly escapes values for the Postgres connection string specified in
+// quoteConnValue quotes a value for the Postgres connection string specified in
 // https://www.postgresql.org/docs/current/libpq-connect.html#LIBPQ-CONNSTRING
-func processDbEnvVar(varName string) string {
-	value := os.Getenv(varName)
-	if value == "" {
-		log.Printf("Variable `%s` not set.", varName)
-	}
-	// The connection string requires that single quotes are escaped
-	return strings.ReplaceAll(value, "'", "\\'")
+func quoteConnValue(value string) string {
+	// The connection string requires that backslashes and single quotes are escaped
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
 }
 
 // buildConnStr
-func buildConnStr() string {
-	dbName := processDbEnvVar("DATABASE_NAME")
-	dbUser := processDbEnvVar("DATABASE_USER")
-	dbPassword := processDbEnvVar("DATABASE_PASSWORD")
-	dbHost := processDbEnvVar("DATABASE_HOST")
+func buildConnStr(cfg config.Database) string {
+	// connect_timeout is in whole seconds.
+	connectTimeout := int(cfg.ConnectTimeout / time.Second)
+	connStr := fmt.Sprintf("dbname=%s user=%s password=%s host=%s port=%d connect_timeout=%d sslmode=%s",
+		quoteConnValue(cfg.Name), quoteConnValue(cfg.User), quoteConnValue(string(cfg.Password)), quoteConnValue(cfg.Host),
+		cfg.Port, connectTimeout, quoteConnValue(cfg.SSLMode))
+	for _, param := range []struct{ name, value string }{
+		{"sslrootcert", cfg.SSLRootCert},
+		{"sslcert", cfg.SSLCert},
+		{"sslkey", cfg.SSLKey},
+	} {
+		if param.value != "" {
+			connStr += fmt.Sprintf(" %s=%s", param.name, quoteConnValue(param.value))
+		}
+	}
+	return connStr
+}
 
-	return fmt.Sprintf("dbname='%s' user='%s' password='%s' host='%s' connect_timeout=1 sslmode=disable", dbName, dbUser, dbPassword, dbHost)
+// openPostgres opens a connection pool for the Postgres database. The connection string is cfg.URL if it is set and
+// is otherwise built from the other settings.
+func openPostgres(cfg config.Database) (*sql.DB, error) {
+	connStr := string(cfg.URL)
+	if connStr == "" {
+		connStr = buildConnStr(cfg)
+	}
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	return db, nil
 }
 
 // twoToPow raises 2 to the power i - i.e. 2**i
@@ -46,7 +69,7 @@ func connectionBackoff(db *sql.DB, maxAttempts int) error {
 
 	_, err := db.Exec("SET timezone = 'utc'")
 	for err != nil && attempt < maxAttempts {
-		log.Printf("Cannot connect to DB, backing off and trying again in %d seconds. (%v)", twoToPow(attempt), err)
+		logging.Base().WithError(err).Warnf("Cannot connect to DB, backing off and trying again in %d seconds.", twoToPow(attempt))
 
 		// Back off doubling the wait time every time. Start with a wait of 2 seconds (2**1)
 		time.Sleep(time.Duration(twoToPow(attempt)) * time.Second)
@@ -54,7 +77,7 @@ func connectionBackoff(db *sql.DB, maxAttempts int) error {
 		_, err = db.Query("SET timezone = 'utc'")
 	}
 	if attempt >= maxAttempts {
-		log.Fatalf("Unable to connect to Database. (%v)", err)
+		logging.Base().WithError(err).Fatal("Unable to connect to Database.")
 	}
 	return err
 }
@@ -73,27 +96,66 @@ func initDb(db *sql.DB) error {
 			PRIMARY KEY (id)
 	)`)
 	if err != nil {
-		log.Println("Unable to create resource_metadata table.")
+		logging.Base().Error("Unable to create resource_metadata table.")
 		return fmt.Errorf("create resource_metadata table: %w", err)
 	}
 
-	return nil
+	return migrate(db, `LOCK TABLE schema_migrations IN EXCLUSIVE MODE`)
+}
+
+// Setup creates the Modeler selected by cfg.Driver and runs any initialization. Calls to the Modeler are instrumented
+// and traced.
+//
+// Supported drivers are `postgres`, `sqlite` which stores metadata in the file named by cfg.Name and `memory` which
+// does not persist anything.
+func Setup(cfg config.Database) Modeler {
+	return Instrument(Trace(setup(cfg)))
 }
 
-// Setup attempts to connect to the database and then run any initialization.
-func Setup() Modeler {
-	log.Println("Connecting to Database.")
-	db, err := sql.Open("postgres", buildConnStr())
+func setup(cfg config.Database) Modeler {
+	switch cfg.Driver {
+	case "postgres":
+		return setupPostgres(cfg)
+	case "sqlite":
+		path := cfg.Name
+		if path == "" {
+			path = "driver_metadata.db"
+		}
+		logging.Base().Infof("Opening SQLite database %s.", path)
+		db, err := openSQLite(path)
+		if err != nil {
+			logging.Base().Fatal(err)
+		}
+		return db
+	case "memory":
+		logging.Base().Warn("Using in-memory database. Metadata will be lost on restart.")
+		return newMemoryModel()
+	default:
+		logging.Base().Fatalf(`Unsupported database driver "%s". Expected one of "postgres", "sqlite" or "memory".`, cfg.Driver)
+		return nil
+	}
+}
+
+// setupPostgres attempts to connect to the Postgres database and then run any initialization.
+func setupPostgres(cfg config.Database) Modeler {
+	if cfg.URL != "" {
+		logging.Base().Info("Connecting to Database using DATABASE_URL.")
+	} else {
+		logging.Base().Infof("Connecting to Database on %s:%d.", cfg.Host, cfg.Port)
+	}
+	db, err := openPostgres(cfg)
 	if err != nil {
-		log.Fatal(err)
+		logging.Base().Fatal(err)
 	}
 
 	// Block executing while we attempt to connect to the database
 	connectionBackoff(db, 6)
 
-	log.Println("Initializing Database.")
+	logging.Base().Info("Initializing Database.")
 	// Run necessary db commands e.g. migrations
-	initDb(db)
+	if err := initDb(db); err != nil {
+		logging.Base().Fatal(err)
+	}
 
-	return model{db}
+	return model{db, newResourceLocks()}
 }
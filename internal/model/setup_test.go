@@ -0,0 +1,46 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"humanitec.io/resources/driver-aws-external/internal/config"
+)
+
+func TestBuildConnStr(t *testing.T) {
+	is := is.New(t)
+	is.Equal(buildConnStr(config.Database{
+		Name:           "metadata",
+		User:           "driver",
+		Password:       `it's a \secret`,
+		Host:           "db.example.com",
+		Port:           6543,
+		SSLMode:        "verify-full",
+		ConnectTimeout: 5 * time.Second,
+	}), `dbname='metadata' user='driver' password='it\'s a \\secret' host='db.example.com' port=6543 connect_timeout=5 sslmode='verify-full'`)
+}
+
+func TestBuildConnStr_Certificates(t *testing.T) {
+	is := is.New(t)
+	cfg := config.Default().Database
+	cfg.Name, cfg.User, cfg.Host = "metadata", "driver", "localhost"
+	cfg.SSLMode = "verify-full"
+	cfg.SSLRootCert = "/etc/db/ca.crt"
+	cfg.SSLCert = "/etc/db/tls.crt"
+	cfg.SSLKey = "/etc/db/tls.key"
+	is.Equal(buildConnStr(cfg), `dbname='metadata' user='driver' password='' host='localhost' port=5432 connect_timeout=1 sslmode='verify-full' sslrootcert='/etc/db/ca.crt' sslcert='/etc/db/tls.crt' sslkey='/etc/db/tls.key'`)
+}
+
+func TestOpenPostgres(t *testing.T) {
+	is := is.New(t)
+	cfg := config.Default().Database
+	cfg.URL = "postgres://driver@localhost:5432/metadata?sslmode=disable"
+	cfg.MaxOpenConns = 7
+
+	// Nothing is connected until the pool is used.
+	db, err := openPostgres(cfg)
+	is.NoErr(err)
+	defer db.Close()
+	is.Equal(db.Stats().MaxOpenConnections, 7)
+}
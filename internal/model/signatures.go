@@ -0,0 +1,36 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"humanitec.io/resources/driver-aws-external/internal/logging"
+)
+
+// UseSignature records that a request signature has been accepted, until expiresAt. It returns false if the signature
+// has already been recorded, by this or any other replica, and has not expired yet.
+//
+// Expired signatures are removed as new ones are recorded, so the table only ever holds the signatures that are still
+// within the allowed clock skew.
+func (db model) UseSignature(ctx context.Context, signature string, expiresAt time.Time) (bool, error) {
+	// NOTE: Times are truncated to the second for the same reason as resource lock expiries, see resourceLocks.expiry.
+	// Expiries are rounded up so that signatures are not forgotten while they would still be accepted.
+	now := time.Now().UTC().Truncate(time.Second)
+	if _, err := db.ExecContext(ctx, `DELETE FROM used_signatures WHERE expires_at < $1`, now); err != nil {
+		logging.FromContext(ctx).WithError(err).Error("Database error removing expired signatures.")
+		return false, fmt.Errorf("use signature: %w", err)
+	}
+
+	res, err := db.ExecContext(ctx, `INSERT INTO used_signatures (signature, expires_at) VALUES ($1, $2)
+		ON CONFLICT (signature) DO NOTHING`, signature, expiresAt.UTC().Add(time.Second-1).Truncate(time.Second))
+	var recorded int64
+	if err == nil {
+		recorded, err = res.RowsAffected()
+	}
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).Error("Database error recording signature.")
+		return false, fmt.Errorf("use signature: %w", err)
+	}
+	return recorded == 1, nil
+}
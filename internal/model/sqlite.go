@@ -0,0 +1,57 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+	"humanitec.io/resources/driver-aws-external/internal/logging"
+)
+
+// sqliteModel is a Modeler that stores metadata in an embedded SQLite database file. The queries are shared with the
+// Postgres model.
+//
+// NOTE: SQLite numbers `$N` parameters in the order they first appear in a query, so shared queries must use them in
+// ascending order.
+type sqliteModel struct {
+	model
+}
+
+// initSQLiteDb creates the SQLite equivalent of the tables created by initDb.
+func initSQLiteDb(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS resource_metadata (
+			id          TEXT NOT NULL,
+			type        TEXT NOT NULL,
+			created_at  TIMESTAMP NOT NULL,
+			updated_at  TIMESTAMP NOT NULL,
+			deleted_at  TIMESTAMP,
+			params      TEXT NOT NULL,
+			data        TEXT NOT NULL,
+			PRIMARY KEY (id)
+	)`)
+	if err != nil {
+		logging.Base().Error("Unable to create resource_metadata table.")
+		return fmt.Errorf("create resource_metadata table: %w", err)
+	}
+
+	// SQLite only allows a single writer, so there is no need to lock.
+	return migrate(db, "")
+}
+
+// openSQLite opens (creating if necessary) the SQLite database file at path.
+func openSQLite(path string) (sqliteModel, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return sqliteModel{}, fmt.Errorf("open sqlite database %s: %w", path, err)
+	}
+
+	// SQLite only supports a single writer at a time.
+	db.SetMaxOpenConns(1)
+
+	if err := initSQLiteDb(db); err != nil {
+		db.Close()
+		return sqliteModel{}, err
+	}
+
+	return sqliteModel{model{db, newResourceLocks()}}, nil
+}
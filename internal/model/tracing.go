@@ -0,0 +1,110 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"humanitec.io/resources/driver-aws-external/internal/tracing"
+)
+
+// tracedModel creates a span for every call to the Modeler it wraps.
+type tracedModel struct {
+	next Modeler
+}
+
+// Trace wraps a Modeler so that each call is recorded as a span, a child of any span in the context passed to it.
+func Trace(m Modeler) Modeler {
+	return tracedModel{m}
+}
+
+func startSpan(ctx context.Context, operation, id string) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "model."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.operation", operation),
+			attribute.String("resource.id", id),
+		),
+	)
+}
+
+func (m tracedModel) InsertOrUpdateResourceMetadata(ctx context.Context, r ResourceMetadata) error {
+	ctx, span := startSpan(ctx, "InsertOrUpdateResourceMetadata", r.ID)
+	err := m.next.InsertOrUpdateResourceMetadata(ctx, r)
+	tracing.End(span, err)
+	return err
+}
+
+func (m tracedModel) SelectResourceMetadata(ctx context.Context, id string) (ResourceMetadata, bool, error) {
+	ctx, span := startSpan(ctx, "SelectResourceMetadata", id)
+	r, exists, err := m.next.SelectResourceMetadata(ctx, id)
+	span.SetAttributes(attribute.Bool("resource.exists", exists))
+	tracing.End(span, err)
+	return r, exists, err
+}
+
+func (m tracedModel) DeleteResourceMetadata(ctx context.Context, id string, deletedAt time.Time) error {
+	ctx, span := startSpan(ctx, "DeleteResourceMetadata", id)
+	err := m.next.DeleteResourceMetadata(ctx, id, deletedAt)
+	// Deleting a resource that does not exist is not a database failure.
+	if err == ErrNotFound {
+		tracing.End(span, nil)
+	} else {
+		tracing.End(span, err)
+	}
+	return err
+}
+
+// SelectResourcesDueForDeletion is not about any one resource, so its span has no resource.id.
+func (m tracedModel) SelectResourcesDueForDeletion(ctx context.Context, now time.Time) ([]string, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "model.SelectResourcesDueForDeletion",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.operation", "SelectResourcesDueForDeletion")),
+	)
+	ids, err := m.next.SelectResourcesDueForDeletion(ctx, now)
+	span.SetAttributes(attribute.Int("resources.due", len(ids)))
+	tracing.End(span, err)
+	return ids, err
+}
+
+func (m tracedModel) LockResource(ctx context.Context, id string) (bool, error) {
+	ctx, span := startSpan(ctx, "LockResource", id)
+	locked, err := m.next.LockResource(ctx, id)
+	span.SetAttributes(attribute.Bool("resource.locked", locked))
+	tracing.End(span, err)
+	return locked, err
+}
+
+func (m tracedModel) UnlockResource(ctx context.Context, id string) error {
+	ctx, span := startSpan(ctx, "UnlockResource", id)
+	err := m.next.UnlockResource(ctx, id)
+	tracing.End(span, err)
+	return err
+}
+
+func (m tracedModel) UseSignature(ctx context.Context, signature string, expiresAt time.Time) (bool, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "model.UseSignature",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.operation", "UseSignature")),
+	)
+	used, err := m.next.UseSignature(ctx, signature, expiresAt)
+	span.SetAttributes(attribute.Bool("signature.accepted", used))
+	tracing.End(span, err)
+	return used, err
+}
+
+// Ping is not traced as it is only called by health checks.
+func (m tracedModel) Ping(ctx context.Context) error {
+	return m.next.Ping(ctx)
+}
+
+// Close is not traced as it is only called on shutdown.
+func (m tracedModel) Close() error {
+	return m.next.Close()
+}
+
+// CheckSchema is not traced as it is only called by health checks.
+func (m tracedModel) CheckSchema(ctx context.Context) error {
+	return m.next.CheckSchema(ctx)
+}
@@ -3,6 +3,7 @@
 package model
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
 	"encoding/json"
@@ -13,27 +14,98 @@ import (
 // ErrNotFound indicates that the resource could not be found.
 var ErrNotFound = errors.New("not found")
 
+// ErrMigrationsPending indicates that the database schema has not been brought up to date.
+var ErrMigrationsPending = errors.New("migrations pending")
+
 // Model is the underlying type for the entire model.
 type model struct {
 	*sql.DB
+	locks *resourceLocks
 }
 
 // Modeler provides an interface which can be used to mock the model
 type Modeler interface {
-	InsertOrUpdateResourceMetadata(m ResourceMetadata) error
-	SelectResourceMetadata(id string) (ResourceMetadata, bool, error)
-	DeleteResourceMetadata(id string, deletedAt time.Time) error
+	InsertOrUpdateResourceMetadata(ctx context.Context, m ResourceMetadata) error
+	SelectResourceMetadata(ctx context.Context, id string) (ResourceMetadata, bool, error)
+	DeleteResourceMetadata(ctx context.Context, id string, deletedAt time.Time) error
+	// SelectResourcesDueForDeletion returns the ids of resources pending deletion whose grace period ended by now, and
+	// of resources still being deleted.
+	SelectResourcesDueForDeletion(ctx context.Context, now time.Time) ([]string, error)
+	LockResource(ctx context.Context, id string) (bool, error)
+	UnlockResource(ctx context.Context, id string) error
+	// UseSignature records that a request signature has been accepted, until expiresAt. It returns false if the
+	// signature has already been recorded and has not expired yet.
+	UseSignature(ctx context.Context, signature string, expiresAt time.Time) (bool, error)
+	// Ping checks that the database can be reached.
+	Ping(ctx context.Context) error
+	// CheckSchema returns ErrMigrationsPending if any migrations have not been applied.
+	CheckSchema(ctx context.Context) error
+	// Close closes the database. The Modeler must not be used afterwards.
+	Close() error
 }
 
+// Statuses of a resource.
+const (
+	// StatusReady resources have been fully provisioned.
+	StatusReady = "ready"
+	// StatusProvisioning resources were still being provisioned when the request handling them was interrupted. Data
+	// holds what is needed to resume provisioning.
+	StatusProvisioning = "provisioning"
+	// StatusPendingDeletion resources have been deleted but their infrastructure is kept until DeleteAfter, so that the
+	// deletion can still be cancelled.
+	StatusPendingDeletion = "pending_deletion"
+	// StatusDeleting resources have infrastructure that AWS has accepted to delete but not yet removed. DeletionRequest
+	// holds what is needed to check on it.
+	StatusDeleting = "deleting"
+)
+
 // ResourceMetadata is metadata held of a resource
 type ResourceMetadata struct {
 	ID        string
 	Type      string
+	Status    string
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	DeletedAt sql.NullTime
 	Params    map[string]interface{}
 	Data      map[string]interface{}
+	// Settings records how the resource's infrastructure was configured, e.g. the encryption applied to a bucket.
+	Settings map[string]interface{}
+	// DeleteAfter is when the infrastructure of a resource pending deletion is deleted. Resources being deleted are
+	// checked on from then.
+	DeleteAfter sql.NullTime
+	// DeletionRequest holds the driver_params and encrypted AWS credentials of the request that deleted a resource
+	// pending deletion or being deleted. They are needed to delete its infrastructure later.
+	DeletionRequest map[string]interface{}
+}
+
+// IsDeleted reports whether the resource has been deleted. Deleted resources are retained for reference but no longer
+// have any infrastructure associated with them.
+func (m ResourceMetadata) IsDeleted() bool {
+	return m.DeletedAt.Valid
+}
+
+// IsProvisioning reports whether provisioning of the resource was interrupted before it completed.
+func (m ResourceMetadata) IsProvisioning() bool {
+	return m.Status == StatusProvisioning
+}
+
+// IsPendingDeletion reports whether the resource is waiting for its grace period to end before being deleted.
+func (m ResourceMetadata) IsPendingDeletion() bool {
+	return m.Status == StatusPendingDeletion
+}
+
+// IsDeleting reports whether the infrastructure of the resource is being deleted.
+func (m ResourceMetadata) IsDeleting() bool {
+	return m.Status == StatusDeleting
+}
+
+// status returns the status to persist. Resources without one are ready.
+func (m ResourceMetadata) status() string {
+	if m.Status == "" {
+		return StatusReady
+	}
+	return m.Status
 }
 
 func AsJSON(obj interface{}) *persisableJSON {
@@ -52,10 +124,11 @@ func (j persisableJSON) Value() (driver.Value, error) {
 
 // Provide a way for arbitrary objects to implement the sql.Scanner interface.
 func (j *persisableJSON) Scan(value interface{}) error {
-	b, ok := value.([]byte)
-	if !ok {
-		return errors.New("type assertion to []byte failed")
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, &j.value)
+	case string:
+		return json.Unmarshal([]byte(v), &j.value)
 	}
-
-	return json.Unmarshal(b, &j.value)
+	return errors.New("type assertion to []byte failed")
 }
@@ -0,0 +1,104 @@
+// Package tracing configures OpenTelemetry tracing for the driver.
+//
+// Spans are exported over OTLP/HTTP when an endpoint is configured. Otherwise tracing is disabled and creating spans is
+// a no-op. W3C trace context is always propagated so that the driver does not break traces passing through it.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"humanitec.io/resources/driver-aws-external/internal/config"
+)
+
+const instrumentationName = "humanitec.io/resources/driver-aws-external"
+
+// Setup installs the W3C trace context propagator and, if cfg is Enabled, a tracer provider exporting spans over
+// OTLP/HTTP. The returned function flushes any buffered spans and must be called before the process exits.
+func Setup(ctx context.Context, cfg config.Tracing) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if !cfg.Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts, err := exporterOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating otlp exporter: %w", err)
+	}
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+		resource.WithAttributes(semconv.ServiceNameKey.String(cfg.ServiceName)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating tracing resource: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// exporterOptions sends spans to the configured endpoint. Other settings of the exporter, e.g. its timeout, are still
+// read by it from the standard environment variables.
+func exporterOptions(cfg config.Tracing) ([]otlptracehttp.Option, error) {
+	endpoint := cfg.TracesEndpoint
+	if endpoint == "" {
+		endpoint = cfg.Endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parsing otlp endpoint: %w", err)
+	}
+	path := u.Path
+	if cfg.TracesEndpoint == "" {
+		// Endpoint is the base URL of the collector, which receives traces on a fixed path.
+		path = strings.TrimSuffix(path, "/") + "/v1/traces"
+	} else if path == "" {
+		path = "/"
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(u.Host), otlptracehttp.WithURLPath(path)}
+	if u.Scheme == "http" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if len(cfg.Headers) > 0 {
+		headers := make(map[string]string, len(cfg.Headers))
+		for k, v := range cfg.Headers {
+			headers[k] = string(v)
+		}
+		opts = append(opts, otlptracehttp.WithHeaders(headers))
+	}
+	return opts, nil
+}
+
+// Tracer returns the tracer used for all spans created by the driver.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// End records err, if any, on span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}